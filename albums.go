@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"sync"
+)
+
+// Albums
+//
+// A user's albums, optionally with the photos in each one nested underneath, e.g. "?include=photos" for a gallery
+// page. Every album's photos cost their own request to Cool Vendor, so those are fetched through a bounded pool of
+// workers, and only the first few photos of each album are kept so that a single huge album can't blow up the
+// response.
+
+// Optional behaviors for getAlbumsByUserId. The zero value returns just the user's albums.
+type userAlbumsOptions struct {
+	// Nest the photos for each album under "albums[].photos".
+	IncludePhotos bool
+
+	// Most photos kept per album. Zero keeps every photo.
+	MaxPhotosPerAlbum int
+
+	// Most albums that have their photos fetched at once.
+	Concurrency int
+
+	// Leave out the photos if any of them fail rather than failing the whole request. See partial.go.
+	AllowPartial bool
+}
+
+// Represents every album for a single user.
+type userAlbums struct {
+	UserID int     `json:"userId"`
+	Albums []album `json:"albums"`
+
+	// Any sub-resources that had to be left out, in which case they're omitted. See partial.go.
+	Warnings []partialWarning `json:"warnings,omitempty"`
+}
+
+// Fetch every album for a given user, along with their photos if asked for.
+//
+// Returns a notFoundError if the user doesn't exist, since Cool Vendor just returns an empty list of albums for them.
+func (userPostService userPostService) getAlbumsByUserId(ctx context.Context, userId int, options userAlbumsOptions) (userAlbums, error) {
+	var userErr error
+	var albums []album
+	var albumsErr error
+
+	// Same as a user's posts, the user and their albums don't depend on each other.
+	waitGroup := sync.WaitGroup{}
+	waitGroup.Add(1)
+	go func() {
+		_, userErr = userPostService.getUserById(ctx, userId)
+		waitGroup.Done()
+	}()
+	waitGroup.Add(1)
+	go func() {
+		albums, albumsErr = userPostService.Source.getAlbumsByUserId(ctx, userId)
+		waitGroup.Done()
+	}()
+	waitGroup.Wait()
+
+	if userErr != nil {
+		return userAlbums{}, userErr
+	}
+	if albumsErr != nil {
+		return userAlbums{}, albumsErr
+	}
+
+	var warnings []partialWarning
+	if options.IncludePhotos {
+		if err := userPostService.attachPhotos(ctx, albums, options); err != nil {
+			if !canRespondPartially(options.AllowPartial, err) {
+				return userAlbums{}, err
+			}
+			// Drop every photo since a partial set of photos would be misleading to the consumer.
+			for i := range albums {
+				albums[i].Photos = nil
+				albums[i].PhotoCount = nil
+			}
+			warnings = append(warnings, partialWarning{Resource: partialResourcePhotos, err: err})
+		}
+	}
+	return userAlbums{UserID: userId, Albums: albums, Warnings: warnings}, nil
+}
+
+// Fetch the photos for each album and attach them in place, keeping at most options.MaxPhotosPerAlbum of them.
+//
+// The requests run concurrently, but are bounded by options.Concurrency. If any fetch fails, then the first error
+// encountered is returned.
+func (userPostService userPostService) attachPhotos(ctx context.Context, albums []album, options userAlbumsOptions) error {
+	errs := make([]error, len(albums))
+	forEachConcurrently(len(albums), options.Concurrency, func(i int) {
+		// Don't bother going upstream if the consumer has already gone away or run out of time.
+		if err := ctx.Err(); err != nil {
+			errs[i] = err
+			return
+		}
+
+		// Each worker only ever writes to its own index, so there is no need for a lock here.
+		photos, err := userPostService.Source.getPhotosByAlbumId(ctx, albums[i].ID)
+		if err != nil {
+			errs[i] = err
+			return
+		}
+		photoCount := len(photos)
+		if options.MaxPhotosPerAlbum > 0 && len(photos) > options.MaxPhotosPerAlbum {
+			photos = photos[:options.MaxPhotosPerAlbum]
+		}
+		albums[i].Photos = &photos
+		albums[i].PhotoCount = &photoCount
+	})
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// userPostService.getAlbumsByUserId
+
+func TestUserPostServiceGetAlbumsByUserId(t *testing.T) {
+	userPostService := newTestFakeVendorService(t, nil)
+
+	userAlbumsResp, err := userPostService.getAlbumsByUserId(context.Background(), 1, userAlbumsOptions{Concurrency: 2})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, userAlbumsResp.UserID)
+	assert.Len(t, userAlbumsResp.Albums, 10)
+	assert.Equal(t, album{ID: 1, Title: "dolore praesentium nihil aliquam"}, userAlbumsResp.Albums[0])
+	assert.Empty(t, userAlbumsResp.Warnings)
+}
+
+func TestUserPostServiceGetAlbumsByUserIdWithPhotos(t *testing.T) {
+	userPostService := newTestFakeVendorService(t, nil)
+
+	userAlbumsResp, err := userPostService.getAlbumsByUserId(context.Background(), 1, userAlbumsOptions{IncludePhotos: true, MaxPhotosPerAlbum: 3, Concurrency: 2})
+
+	assert.Nil(t, err)
+	assert.Len(t, userAlbumsResp.Albums, 10)
+	for _, album := range userAlbumsResp.Albums {
+		assert.Len(t, *album.Photos, 3)
+		assert.Equal(t, 10, *album.PhotoCount)
+	}
+	assert.Equal(t, photo{
+		ID:           1,
+		Title:        "quas harum numquam quam voluptatem praesentium quam amet",
+		URL:          "https://via.placeholder.com/600/13b3fc",
+		ThumbnailURL: "https://via.placeholder.com/150/13b3fc",
+	}, (*userAlbumsResp.Albums[0].Photos)[0])
+}
+
+func TestUserPostServiceGetAlbumsByUserIdBoundedConcurrency(t *testing.T) {
+	fakeVendor := newTestFakeVendor(t, nil)
+	var mutex sync.Mutex
+	inFlight, maxInFlight := 0, 0
+	mockHTTPClientDo = func(r *http.Request) (*http.Response, error) {
+		if strings.HasPrefix(r.URL.Path, "/photos") {
+			mutex.Lock()
+			inFlight++
+			if inFlight > maxInFlight {
+				maxInFlight = inFlight
+			}
+			mutex.Unlock()
+			time.Sleep(5 * time.Millisecond)
+			mutex.Lock()
+			inFlight--
+			mutex.Unlock()
+		}
+		w := httptest.NewRecorder()
+		fakeVendor.ServeHTTP(w, r)
+		return w.Result(), nil
+	}
+	userPostService := userPostService{Source: typicodeClient{Client: &mockHTTPClient{}, BaseUrl: mockBaseURL}}
+
+	_, err := userPostService.getAlbumsByUserId(context.Background(), 1, userAlbumsOptions{IncludePhotos: true, Concurrency: 3})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 3, maxInFlight)
+}
+
+func TestUserPostServiceGetAlbumsByUserIdNotFound(t *testing.T) {
+	userPostService := newTestFakeVendorService(t, nil)
+
+	_, err := userPostService.getAlbumsByUserId(context.Background(), 123456, userAlbumsOptions{Concurrency: 2})
+
+	var notFoundErr *notFoundError
+	assert.True(t, errors.As(err, &notFoundErr))
+	assert.EqualError(t, err, "Could not find userId=123456")
+}
+
+func TestUserPostServiceGetAlbumsByUserIdAlbumsFailure(t *testing.T) {
+	userPostService := newTestFakeVendorService(t, fakeVendorFaults{"albums": {Status: http.StatusInternalServerError}})
+
+	_, err := userPostService.getAlbumsByUserId(context.Background(), 1, userAlbumsOptions{Concurrency: 2, AllowPartial: true})
+
+	// The albums are the whole point, so there's nothing to fall back to.
+	assert.EqualError(t, err, "Unexpected server error occurred trying to fetch albums for userId=1 from Cool Vendor: status=500")
+}
+
+func TestUserPostServiceGetAlbumsByUserIdPhotosFailure(t *testing.T) {
+	userPostService := newTestFakeVendorService(t, fakeVendorFaults{"photos": {Status: http.StatusInternalServerError}})
+
+	_, err := userPostService.getAlbumsByUserId(context.Background(), 1, userAlbumsOptions{IncludePhotos: true, Concurrency: 2})
+
+	var unavailableErr *upstreamUnavailableError
+	assert.True(t, errors.As(err, &unavailableErr))
+}
+
+func TestUserPostServiceGetAlbumsByUserIdPartialPhotos(t *testing.T) {
+	userPostService := newTestFakeVendorService(t, fakeVendorFaults{"photos": {Status: http.StatusInternalServerError}})
+
+	userAlbumsResp, err := userPostService.getAlbumsByUserId(context.Background(), 1, userAlbumsOptions{IncludePhotos: true, Concurrency: 2, AllowPartial: true})
+
+	assert.Nil(t, err)
+	assert.Len(t, userAlbumsResp.Albums, 10)
+	for _, album := range userAlbumsResp.Albums {
+		assert.Nil(t, album.Photos)
+		assert.Nil(t, album.PhotoCount)
+	}
+	assert.Len(t, userAlbumsResp.Warnings, 1)
+	assert.Equal(t, partialResourcePhotos, userAlbumsResp.Warnings[0].Resource)
+}
+
+// typicodeClient.getAlbumsByUserId
+
+func TestTypicodeClientGetAlbumsByUserIdSuccess(t *testing.T) {
+	typicodeClient := typicodeClient{
+		Client:  &mockHTTPClient{},
+		BaseUrl: mockBaseURL,
+	}
+	mockHTTPClientDo = func(r *http.Request) (*http.Response, error) {
+		assert.Equal(t, mockBaseURL+"/albums?userId=1", r.URL.String())
+		return &http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(strings.NewReader(`[{"userId":1,"id":1,"title":"quidem molestiae enim"}]`)),
+		}, nil
+	}
+
+	resp, respErr := typicodeClient.getAlbumsByUserId(context.Background(), 1)
+	assert.Nil(t, respErr)
+	assert.Equal(t, []album{{ID: 1, Title: "quidem molestiae enim"}}, resp)
+}
+
+func TestTypicodeClientGetAlbumsByUserIdBadJson(t *testing.T) {
+	typicodeClient := typicodeClient{
+		Client:  &mockHTTPClient{},
+		BaseUrl: mockBaseURL,
+	}
+	mockHTTPClientDo = func(*http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(strings.NewReader(`{"id":"im-a-string"}`)),
+		}, nil
+	}
+
+	resp, respErr := typicodeClient.getAlbumsByUserId(context.Background(), 1)
+	assert.Equal(t, []album{}, resp)
+	assert.NotNil(t, respErr)
+	assert.Contains(t, respErr.Error(), "Unable to parse response body as '[]album' JSON for Cool Vendor's Get Albums API: error=")
+}
+
+func TestTypicodeClientGetAlbumsByUserId500(t *testing.T) {
+	typicodeClient := typicodeClient{
+		Client:  &mockHTTPClient{},
+		BaseUrl: mockBaseURL,
+	}
+	mockHTTPClientDo = func(*http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: 500,
+			Body:       ioutil.NopCloser(strings.NewReader(errMsg500)),
+		}, nil
+	}
+
+	resp, respErr := typicodeClient.getAlbumsByUserId(context.Background(), 1)
+	assert.Equal(t, []album{}, resp)
+	assert.EqualError(t, respErr, "Unexpected server error occurred trying to fetch albums for userId=1 from Cool Vendor: status=500")
+}
+
+// typicodeClient.getPhotosByAlbumId
+
+func TestTypicodeClientGetPhotosByAlbumIdSuccess(t *testing.T) {
+	typicodeClient := typicodeClient{
+		Client:  &mockHTTPClient{},
+		BaseUrl: mockBaseURL,
+	}
+	mockHTTPClientDo = func(r *http.Request) (*http.Response, error) {
+		assert.Equal(t, mockBaseURL+"/photos?albumId=1", r.URL.String())
+		return &http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(strings.NewReader(`[{"albumId":1,"id":1,"title":"accusamus","url":"https://via.placeholder.com/600/92c952","thumbnailUrl":"https://via.placeholder.com/150/92c952"}]`)),
+		}, nil
+	}
+
+	resp, respErr := typicodeClient.getPhotosByAlbumId(context.Background(), 1)
+	assert.Nil(t, respErr)
+	assert.Equal(t, []photo{{ID: 1, Title: "accusamus", URL: "https://via.placeholder.com/600/92c952", ThumbnailURL: "https://via.placeholder.com/150/92c952"}}, resp)
+}
+
+func TestTypicodeClientGetPhotosByAlbumIdBadJson(t *testing.T) {
+	typicodeClient := typicodeClient{
+		Client:  &mockHTTPClient{},
+		BaseUrl: mockBaseURL,
+	}
+	mockHTTPClientDo = func(*http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(strings.NewReader(`{"id":"im-a-string"}`)),
+		}, nil
+	}
+
+	resp, respErr := typicodeClient.getPhotosByAlbumId(context.Background(), 1)
+	assert.Equal(t, []photo{}, resp)
+	assert.NotNil(t, respErr)
+	assert.Contains(t, respErr.Error(), "Unable to parse response body as '[]photo' JSON for Cool Vendor's Get Photos API: error=")
+}
+
+func TestTypicodeClientGetPhotosByAlbumId500(t *testing.T) {
+	typicodeClient := typicodeClient{
+		Client:  &mockHTTPClient{},
+		BaseUrl: mockBaseURL,
+	}
+	mockHTTPClientDo = func(*http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: 500,
+			Body:       ioutil.NopCloser(strings.NewReader(errMsg500)),
+		}, nil
+	}
+
+	resp, respErr := typicodeClient.getPhotosByAlbumId(context.Background(), 1)
+	assert.Equal(t, []photo{}, resp)
+	assert.EqualError(t, respErr, "Unexpected server error occurred trying to fetch photos for albumId=1 from Cool Vendor: status=500")
+}
+
+// Controller - getAlbumsByUserId
+
+func TestGetAlbumsByUserId(t *testing.T) {
+	initializeTestFakeVendor(t, nil)
+	albumsMaxPhotosPerAlbum = 2
+	t.Cleanup(func() { albumsMaxPhotosPerAlbum = defaultAppConfig.Albums.MaxPhotosPerAlbum })
+
+	w := httptest.NewRecorder()
+	setupRouter().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/users/1/albums?include=photos", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var userAlbumsResp userAlbums
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(&userAlbumsResp))
+	assert.Equal(t, 1, userAlbumsResp.UserID)
+	assert.Len(t, userAlbumsResp.Albums, 10)
+	for _, album := range userAlbumsResp.Albums {
+		assert.Len(t, *album.Photos, 2)
+		assert.Equal(t, 10, *album.PhotoCount)
+	}
+}
+
+func TestGetAlbumsByUserIdWithoutPhotos(t *testing.T) {
+	initializeTestFakeVendor(t, nil)
+
+	w := httptest.NewRecorder()
+	setupRouter().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/users/1/albums", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "photos")
+	assert.NotContains(t, w.Body.String(), "photoCount")
+}
+
+func TestGetAlbumsByUserIdWithPhotosEmpty(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "source.json")
+	assert.Nil(t, ioutil.WriteFile(path, []byte(`{"users": [{"id": 1}], "albums": [{"userId": 1, "id": 1, "title": "Empty"}]}`), 0644))
+	source, err := newJSONFileSource(path)
+	assert.Nil(t, err)
+	userPostServiceImpl = userPostService{Source: source}
+	t.Cleanup(func() { userPostServiceImpl = userPostService{} })
+
+	w := httptest.NewRecorder()
+	setupRouter().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/users/1/albums?include=photos", nil))
+
+	// An album without any photos should still say so, rather than look like photos weren't requested.
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"photos": []`)
+	assert.Contains(t, w.Body.String(), `"photoCount": 0`)
+}
+
+func TestGetAlbumsByUserIdNotFound404(t *testing.T) {
+	initializeTestFakeVendor(t, nil)
+
+	w := httptest.NewRecorder()
+	setupRouter().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/users/123456/albums", nil))
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assertProblem(t, w, "not_found", "Could not find userId=123456")
+}
+
+func TestGetAlbumsByUserIdInvalidId400(t *testing.T) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/v1/users/abc/albums", nil)
+	c.Params = gin.Params{{Key: "userId", Value: "abc"}}
+
+	getAlbumsByUserId(c)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assertProblem(t, w, "invalid_input", "Expected ID in integer format, but got 'abc' instead")
+}
+
+func TestGetAlbumsByUserIdInvalidInclude400(t *testing.T) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/v1/users/1/albums?include=comments", nil)
+	c.Params = gin.Params{{Key: "userId", Value: "1"}}
+
+	getAlbumsByUserId(c)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assertProblem(t, w, "invalid_input", "Unsupported include value 'comments', expected one of: photos")
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Batching
+//
+// Lets consumers fetch the posts for many users in a single request with "/v1/user-posts?ids=1,2,3", rather than
+// making one request per user. Small batches fetch each user exactly like "/v1/user-posts/:userId", just fanned out
+// across a bounded pool of workers. Bigger ones fetch every user and their posts with a handful of bulk requests to
+// Cool Vendor instead. Either way, every user gets their own result so that one bad ID or one failed fetch never
+// fails the whole batch.
+
+// The outcome of fetching a single user's posts as part of a batch. Exactly one of UserPosts or Err is set.
+type userPostsBatchItem struct {
+	UserID    int
+	UserPosts userPosts
+	Err       error
+}
+
+// Tuning knobs for getUserPostsByUserIds.
+type batchOptions struct {
+	// Most users processed at once.
+	Concurrency int
+
+	// Batches with at least this many users fetch them and their posts with Cool Vendor's bulk filters instead of
+	// one user at a time. Zero always fetches one user at a time.
+	BulkThreshold int
+}
+
+// Fetch the posts for every given user. Results are in the same order as userIds.
+//
+// Small batches fetch each user exactly like getUserPostsByUserId does. Past batchOptions.BulkThreshold, that turns
+// into a lot of round trips to Cool Vendor for what's really just two lists, so every user and their posts are
+// fetched in bulk instead, and then grouped in memory.
+func (userPostService userPostService) getUserPostsByUserIds(ctx context.Context, userIds []int, options userPostsOptions, batch batchOptions) []userPostsBatchItem {
+	if batch.BulkThreshold > 0 && len(userIds) >= batch.BulkThreshold {
+		return userPostService.getUserPostsByUserIdsInBulk(ctx, userIds, options, batch)
+	}
+
+	items := make([]userPostsBatchItem, len(userIds))
+	forEachConcurrently(len(userIds), batch.Concurrency, func(i int) {
+		items[i].UserID = userIds[i]
+		items[i].UserPosts, items[i].Err = userPostService.getUserPostsByUserId(ctx, userIds[i], options)
+	})
+	return items
+}
+
+func (userPostService userPostService) getUserPostsByUserIdsInBulk(ctx context.Context, userIds []int, options userPostsOptions, batch batchOptions) []userPostsBatchItem {
+	var usersById map[int]user
+	var usersErr error
+	var postsByUserId map[int][]postSummary
+	var postsErr error
+
+	// Same as a single user, the users and their posts don't depend on each other.
+	waitGroup := sync.WaitGroup{}
+	waitGroup.Add(1)
+	go func() {
+		usersById, usersErr = userPostService.Source.getUsersByIds(ctx, userIds)
+		waitGroup.Done()
+	}()
+	waitGroup.Add(1)
+	go func() {
+		postsByUserId, postsErr = userPostService.Source.getPostsByUserIds(ctx, userIds)
+		waitGroup.Done()
+	}()
+	waitGroup.Wait()
+
+	items := make([]userPostsBatchItem, len(userIds))
+	for i, userId := range userIds {
+		items[i].UserID = userId
+	}
+
+	// Every user shares the same bulk requests, so they all fail together. Same as a single user, there's nothing
+	// to return without the user, but a user without their posts can still be a partial response.
+	err := usersErr
+	if err == nil && postsErr != nil && !canRespondPartially(options.AllowPartial, postsErr) {
+		err = postsErr
+	}
+	if err != nil {
+		for i := range items {
+			items[i].Err = err
+		}
+		return items
+	}
+
+	// Anything left to fetch per user, i.e. comments, still goes through the same bounded pool.
+	forEachConcurrently(len(userIds), batch.Concurrency, func(i int) {
+		userResp, ok := usersById[userIds[i]]
+		if !ok {
+			items[i].Err = &notFoundError{domainErrorDetails{Message: fmt.Sprint("Could not find userId=", userIds[i])}}
+			return
+		}
+		if postsErr != nil {
+			items[i].UserPosts = newPartialUserPosts(userResp, postsErr)
+			return
+		}
+		items[i].UserPosts, items[i].Err = userPostService.assembleUserPosts(ctx, userResp, postsByUserId[userIds[i]], options)
+	})
+	return items
+}
+
+// Call do once for every index below n, with at most concurrency calls running at once.
+func forEachConcurrently(n int, concurrency int, do func(i int)) {
+	if concurrency > n {
+		concurrency = n
+	}
+
+	indexes := make(chan int)
+	waitGroup := sync.WaitGroup{}
+	for worker := 0; worker < concurrency; worker++ {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			for i := range indexes {
+				do(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		indexes <- i
+	}
+	close(indexes)
+	waitGroup.Wait()
+}
+
+// Parse a comma-separated list of user IDs, e.g. "?ids=1,2,3", dropping any duplicates while keeping their order.
+func parseUserIds(ids string, maxSize int) ([]int, error) {
+	if strings.TrimSpace(ids) == "" {
+		return nil, newInvalidInputError("Expected 'ids' to be a comma-separated list of user IDs, e.g. 'ids=1,2,3'")
+	}
+
+	userIds := []int{}
+	seen := map[int]bool{}
+	for _, id := range strings.Split(ids, ",") {
+		id = strings.TrimSpace(id)
+		userId, err := strconv.Atoi(id)
+		if err != nil {
+			return nil, newInvalidInputError("Expected every ID in 'ids' to be in integer format, but got '" + id + "' instead")
+		}
+		if !seen[userId] {
+			seen[userId] = true
+			userIds = append(userIds, userId)
+		}
+	}
+
+	if len(userIds) > maxSize {
+		return nil, newInvalidInputError("Expected at most " + strconv.Itoa(maxSize) + " IDs in 'ids', but got " + strconv.Itoa(len(userIds)))
+	}
+	return userIds, nil
+}
+
+// Represents the posts for many users, fetched in one request.
+type userPostsBatch struct {
+	Results []userPostsBatchResult `json:"results"`
+
+	// How many of the results succeeded, were only partial responses, and failed, so that consumers can tell at a
+	// glance whether anything needs a closer look.
+	Succeeded int `json:"succeeded"`
+	Partial   int `json:"partial"`
+	Failed    int `json:"failed"`
+}
+
+// The result for a single user in "userPostsBatch". "status" is what "/v1/user-posts/:userId" would have returned for
+// the same user, along with either their posts or the same problem details that it would have returned.
+type userPostsBatchResult struct {
+	UserID    int             `json:"userId"`
+	Status    int             `json:"status"`
+	UserPosts *userPosts      `json:"userPosts,omitempty"`
+	Error     *problemDetails `json:"error,omitempty"`
+}
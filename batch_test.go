@@ -0,0 +1,299 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// parseUserIds
+
+func TestParseUserIds(t *testing.T) {
+	userIds, err := parseUserIds(" 3, 1,3 ,2", 3)
+	assert.Nil(t, err)
+	// Duplicates don't count towards the limit.
+	assert.Equal(t, []int{3, 1, 2}, userIds)
+}
+
+func TestParseUserIdsInvalid(t *testing.T) {
+	tests := map[string]string{
+		"":        "Expected 'ids' to be a comma-separated list of user IDs, e.g. 'ids=1,2,3'",
+		" ":       "Expected 'ids' to be a comma-separated list of user IDs, e.g. 'ids=1,2,3'",
+		"1,two":   "Expected every ID in 'ids' to be in integer format, but got 'two' instead",
+		"1,,2":    "Expected every ID in 'ids' to be in integer format, but got '' instead",
+		"1,2,3,4": "Expected at most 3 IDs in 'ids', but got 4",
+	}
+	for ids, expectedErr := range tests {
+		_, err := parseUserIds(ids, 3)
+		assert.EqualError(t, err, expectedErr, ids)
+		var invalidInputErr *invalidInputError
+		assert.True(t, errors.As(err, &invalidInputErr))
+	}
+}
+
+// userPostService.getUserPostsByUserIds
+
+func TestUserPostServiceGetUserPostsByUserIds(t *testing.T) {
+	userPostService := newTestFakeVendorService(t, nil)
+
+	items := userPostService.getUserPostsByUserIds(context.Background(), []int{2, 123456, 1}, userPostsOptions{}, batchOptions{Concurrency: 2})
+
+	assert.Len(t, items, 3)
+	assert.Equal(t, 2, items[0].UserID)
+	assert.Nil(t, items[0].Err)
+	assert.Equal(t, "Ervin Howell", items[0].UserPosts.UserInfo.Name)
+	assert.Equal(t, 123456, items[1].UserID)
+	var notFoundErr *notFoundError
+	assert.True(t, errors.As(items[1].Err, &notFoundErr))
+	assert.Equal(t, 1, items[2].UserID)
+	assert.Nil(t, items[2].Err)
+	assert.Len(t, items[2].UserPosts.Posts, 10)
+}
+
+func TestUserPostServiceGetUserPostsByUserIdsBoundedConcurrency(t *testing.T) {
+	fakeVendor := newTestFakeVendor(t, nil)
+	var mutex sync.Mutex
+	inFlight, maxInFlight := 0, 0
+	mockHTTPClientDo = func(r *http.Request) (*http.Response, error) {
+		// Only count one of the two requests per user so that we're counting users rather than requests.
+		if strings.HasPrefix(r.URL.Path, "/users") {
+			mutex.Lock()
+			inFlight++
+			if inFlight > maxInFlight {
+				maxInFlight = inFlight
+			}
+			mutex.Unlock()
+			time.Sleep(5 * time.Millisecond)
+			mutex.Lock()
+			inFlight--
+			mutex.Unlock()
+		}
+		w := httptest.NewRecorder()
+		fakeVendor.ServeHTTP(w, r)
+		return w.Result(), nil
+	}
+	userPostService := userPostService{Source: typicodeClient{Client: &mockHTTPClient{}, BaseUrl: mockBaseURL}}
+
+	items := userPostService.getUserPostsByUserIds(context.Background(), []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, userPostsOptions{}, batchOptions{Concurrency: 3})
+
+	assert.Len(t, items, 10)
+	for i, item := range items {
+		assert.Equal(t, i+1, item.UserID)
+		assert.Nil(t, item.Err)
+	}
+	assert.Equal(t, 3, maxInFlight)
+}
+
+func TestUserPostServiceGetUserPostsByUserIdsEmpty(t *testing.T) {
+	userPostService := newTestFakeVendorService(t, nil)
+
+	assert.Empty(t, userPostService.getUserPostsByUserIds(context.Background(), []int{}, userPostsOptions{}, batchOptions{Concurrency: 3, BulkThreshold: 1}))
+}
+
+func TestUserPostServiceGetUserPostsByUserIdsInBulk(t *testing.T) {
+	fakeVendor := newTestFakeVendor(t, nil)
+	var requests []string
+	var mutex sync.Mutex
+	mockHTTPClientDo = func(r *http.Request) (*http.Response, error) {
+		mutex.Lock()
+		requests = append(requests, r.URL.Path)
+		mutex.Unlock()
+		w := httptest.NewRecorder()
+		fakeVendor.ServeHTTP(w, r)
+		return w.Result(), nil
+	}
+	userPostService := userPostService{Source: typicodeClient{Client: &mockHTTPClient{}, BaseUrl: mockBaseURL}}
+
+	items := userPostService.getUserPostsByUserIds(context.Background(), []int{3, 1, 123456, 2}, userPostsOptions{Sort: sortByIDDesc}, batchOptions{Concurrency: 2, BulkThreshold: 4})
+
+	// Just one request for every user, and one for all of their posts.
+	assert.ElementsMatch(t, []string{"/users", "/posts"}, requests)
+	assert.Equal(t, []int{3, 1, 123456, 2}, []int{items[0].UserID, items[1].UserID, items[2].UserID, items[3].UserID})
+	assert.Equal(t, "Clementine Bauch", items[0].UserPosts.UserInfo.Name)
+	assert.Equal(t, []int{30, 29, 28, 27, 26, 25, 24, 23, 22, 21}, postIDs(items[0].UserPosts.Posts))
+	assert.Equal(t, "Leanne Graham", items[1].UserPosts.UserInfo.Name)
+	assert.Len(t, items[1].UserPosts.Posts, 10)
+	assert.EqualError(t, items[2].Err, "Could not find userId=123456")
+	var notFoundErr *notFoundError
+	assert.True(t, errors.As(items[2].Err, &notFoundErr))
+	assert.Nil(t, items[3].Err)
+
+	// Exactly the same as fetching them one at a time.
+	for _, item := range items {
+		single, singleErr := userPostService.getUserPostsByUserId(context.Background(), item.UserID, userPostsOptions{Sort: sortByIDDesc})
+		assert.Equal(t, single, item.UserPosts)
+		assert.Equal(t, singleErr, item.Err)
+	}
+}
+
+func TestUserPostServiceGetUserPostsByUserIdsInBulkWithComments(t *testing.T) {
+	userPostService := newTestFakeVendorService(t, nil)
+
+	items := userPostService.getUserPostsByUserIds(context.Background(), []int{1, 2}, userPostsOptions{IncludeComments: true}, batchOptions{Concurrency: 2, BulkThreshold: 1})
+
+	for _, item := range items {
+		assert.Nil(t, item.Err)
+		for _, post := range item.UserPosts.Posts {
+			assert.Len(t, *post.Comments, 5)
+		}
+	}
+}
+
+func TestUserPostServiceGetUserPostsByUserIdsInBulkUpstreamFailure(t *testing.T) {
+	userPostService := newTestFakeVendorService(t, fakeVendorFaults{"posts": {Status: http.StatusInternalServerError}})
+
+	items := userPostService.getUserPostsByUserIds(context.Background(), []int{1, 2}, userPostsOptions{}, batchOptions{Concurrency: 2, BulkThreshold: 1})
+
+	// Both users share the same failed request.
+	for _, item := range items {
+		assert.EqualError(t, item.Err, "Unexpected server error occurred trying to fetch posts for userId=1,2 from Cool Vendor: status=500")
+		var unavailableErr *upstreamUnavailableError
+		assert.True(t, errors.As(item.Err, &unavailableErr))
+	}
+}
+
+// typicodeClient.getPostsByUserIds
+
+func TestTypicodeClientGetPostsByUserIdsChunked(t *testing.T) {
+	var queries []string
+	mockHTTPClientDo = func(r *http.Request) (*http.Response, error) {
+		queries = append(queries, r.URL.RawQuery)
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(strings.NewReader(`[{"userId": 1, "id": 1, "title": "foo", "body": "bar"}]`)),
+		}, nil
+	}
+	typicodeClient := typicodeClient{Client: &mockHTTPClient{}, BaseUrl: mockBaseURL}
+	userIds := make([]int, maxIdsPerBulkRequest+1)
+	for i := range userIds {
+		userIds[i] = i + 1
+	}
+
+	postsByUserId, err := typicodeClient.getPostsByUserIds(context.Background(), userIds)
+
+	assert.Nil(t, err)
+	assert.Len(t, queries, 2)
+	assert.Equal(t, "userId=51", queries[1])
+	// Every user gets an entry, even without any posts.
+	assert.Len(t, postsByUserId, maxIdsPerBulkRequest+1)
+	assert.Equal(t, []postSummary{}, postsByUserId[2])
+	// Posts from every chunk are grouped together.
+	assert.Equal(t, []postSummary{{ID: 1, Title: "foo", Body: "bar"}, {ID: 1, Title: "foo", Body: "bar"}}, postsByUserId[1])
+}
+
+// Controller - getUserPostsByUserIds
+
+func TestGetUserPostsByUserIds(t *testing.T) {
+	initializeTestFakeVendor(t, nil)
+
+	w := httptest.NewRecorder()
+	setupRouter().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/user-posts?ids=1,123456,2&titleContains=qui", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var batchResp userPostsBatch
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(&batchResp))
+	assert.Equal(t, 2, batchResp.Succeeded)
+	assert.Equal(t, 1, batchResp.Failed)
+	assert.Len(t, batchResp.Results, 3)
+
+	assert.Equal(t, 1, batchResp.Results[0].UserID)
+	assert.Equal(t, http.StatusOK, batchResp.Results[0].Status)
+	assert.Equal(t, "Leanne Graham", batchResp.Results[0].UserPosts.UserInfo.Name)
+	assert.Nil(t, batchResp.Results[0].Error)
+	// Options apply to every user.
+	for _, post := range batchResp.Results[0].UserPosts.Posts {
+		assert.Contains(t, post.Title, "qui")
+	}
+
+	assert.Equal(t, 123456, batchResp.Results[1].UserID)
+	assert.Equal(t, http.StatusNotFound, batchResp.Results[1].Status)
+	assert.Nil(t, batchResp.Results[1].UserPosts)
+	assert.Equal(t, "not_found", batchResp.Results[1].Error.Code)
+	assert.Equal(t, "Could not find userId=123456", batchResp.Results[1].Error.Detail)
+	assert.Equal(t, "/v1/user-posts/123456", batchResp.Results[1].Error.Instance)
+	assert.Equal(t, w.Header().Get(correlationIDHeader), batchResp.Results[1].Error.CorrelationID)
+
+	assert.Equal(t, 2, batchResp.Results[2].UserID)
+	assert.Equal(t, http.StatusOK, batchResp.Results[2].Status)
+}
+
+func TestGetUserPostsByUserIdsUpstreamFailure(t *testing.T) {
+	initializeTestFakeVendor(t, fakeVendorFaults{"posts": {Status: http.StatusInternalServerError}})
+
+	w := httptest.NewRecorder()
+	setupRouter().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/user-posts?ids=1,2&strict=true", nil))
+
+	// Still a 200 since the request itself was fine, it's just that every user failed.
+	assert.Equal(t, http.StatusOK, w.Code)
+	var batchResp userPostsBatch
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(&batchResp))
+	assert.Equal(t, 0, batchResp.Succeeded)
+	assert.Equal(t, 2, batchResp.Failed)
+	for _, result := range batchResp.Results {
+		assert.Equal(t, http.StatusServiceUnavailable, result.Status)
+		assert.Equal(t, "upstream_unavailable", result.Error.Code)
+	}
+}
+
+func TestGetUserPostsByUserIdsTooMany400(t *testing.T) {
+	batchMaxSize = 2
+	t.Cleanup(func() { batchMaxSize = defaultAppConfig.Batch.MaxSize })
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/v1/user-posts?ids=1,2,3", nil)
+
+	getUserPostsByUserIds(c)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assertProblem(t, w, "invalid_input", "Expected at most 2 IDs in 'ids', but got 3")
+}
+
+func TestGetUserPostsByUserIdsMissingIds400(t *testing.T) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/v1/user-posts", nil)
+
+	getUserPostsByUserIds(c)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assertProblem(t, w, "invalid_input", "Expected 'ids' to be a comma-separated list of user IDs, e.g. 'ids=1,2,3'")
+}
+
+func TestGetUserPostsByUserIdsCanceled(t *testing.T) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c.Request = httptest.NewRequest(http.MethodGet, "/v1/user-posts?ids=1", nil).WithContext(ctx)
+	mockHTTPClientDo = func(r *http.Request) (*http.Response, error) {
+		return nil, r.Context().Err()
+	}
+	userPostServiceImpl = userPostService{Source: typicodeClient{Client: &mockHTTPClient{}, BaseUrl: mockBaseURL}}
+
+	getUserPostsByUserIds(c)
+
+	assert.Equal(t, 499, w.Code)
+}
+
+// Test Helpers
+
+// Build a service backed directly by a fake vendor, without any of the HTTP client layers in between.
+func newTestFakeVendorService(t *testing.T, faults fakeVendorFaults) userPostService {
+	fakeVendor := newTestFakeVendor(t, faults)
+	mockHTTPClientDo = func(r *http.Request) (*http.Response, error) {
+		w := httptest.NewRecorder()
+		fakeVendor.ServeHTTP(w, r)
+		return w.Result(), nil
+	}
+	return userPostService{Source: typicodeClient{Client: &mockHTTPClient{}, BaseUrl: mockBaseURL}}
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Clients - circuitBreakingHTTPClient
+//
+// Stops sending requests to Cool Vendor while they're struggling so that we fail fast instead of making every
+// consumer wait out the full failure path, and so that we don't pile even more load onto them while they recover.
+//
+// Each upstream endpoint (i.e. "users", "posts", etc.) gets its own breaker since one of their APIs can be down
+// while the others are perfectly healthy:
+//   - closed: Requests flow through as usual while the failure rate is tracked over a rolling window.
+//   - open: The failure rate crossed the threshold, so every request is rejected until the cool-down elapses.
+//   - half-open: The cool-down elapsed, so a single probe request is let through. If it succeeds, the breaker
+//     closes again. Otherwise, it goes right back to open for another cool-down.
+
+type circuitState string
+
+const (
+	circuitClosed   circuitState = "closed"
+	circuitOpen     circuitState = "open"
+	circuitHalfOpen circuitState = "half-open"
+)
+
+// Tuning knobs for circuitBreakingHTTPClient.
+type breakerOptions struct {
+	// Fraction of failed requests, between 0 and 1, within the rolling window that trips the breaker.
+	FailureRateThreshold float64
+
+	// Minimum number of requests in the rolling window before the failure rate is even considered, so that a
+	// single failure right after startup doesn't trip the breaker.
+	MinRequests int
+
+	// Number of most recent requests that the failure rate is calculated over.
+	WindowSize int
+
+	// How long the breaker stays open before letting a probe request through.
+	CoolDown time.Duration
+}
+
+// Returned instead of making a request while a breaker is open.
+type circuitOpenError struct {
+	Endpoint   string
+	RetryAfter time.Duration
+}
+
+func (err *circuitOpenError) Error() string {
+	return fmt.Sprint("Circuit breaker for Cool Vendor's '", err.Endpoint, "' endpoint is open, retry after ", err.RetryAfter.Round(time.Second))
+}
+
+// Point-in-time view of a single breaker for diagnostics.
+type circuitBreakerStats struct {
+	State             circuitState `json:"state"`
+	Requests          int          `json:"requests"`
+	Failures          int          `json:"failures"`
+	FailureRate       float64      `json:"failureRate"`
+	RetryAfterSeconds int          `json:"retryAfterSeconds,omitempty"`
+}
+
+type circuitBreakingHTTPClient struct {
+	Client  httpClient
+	Options breakerOptions
+
+	// Overridable for unit tests so that we don't need to actually wait out the cool-down.
+	now func() time.Time
+
+	mutex    sync.Mutex
+	breakers map[string]*circuitBreaker
+}
+
+// State for a single upstream endpoint. Only ever accessed while holding circuitBreakingHTTPClient.mutex.
+type circuitBreaker struct {
+	state    circuitState
+	openedAt time.Time
+
+	// Whether the single half-open probe request is currently in flight.
+	probing bool
+
+	// Ring buffer of the most recent outcomes, where true means the request failed.
+	outcomes []bool
+	next     int
+	requests int
+	failures int
+}
+
+func newCircuitBreakingHTTPClient(client httpClient, options breakerOptions) *circuitBreakingHTTPClient {
+	return &circuitBreakingHTTPClient{
+		Client:   client,
+		Options:  options,
+		now:      time.Now,
+		breakers: map[string]*circuitBreaker{},
+	}
+}
+
+func (circuitBreakingHTTPClient *circuitBreakingHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	permit, err := circuitBreakingHTTPClient.allow(upstreamEndpoint(req))
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := circuitBreakingHTTPClient.Client.Do(req)
+
+	// A consumer hanging up on us says nothing about Cool Vendor's health, so don't count it either way.
+	if req.Context().Err() != nil {
+		circuitBreakingHTTPClient.release(permit)
+	} else {
+		circuitBreakingHTTPClient.record(permit, isTransient(resp, err))
+	}
+	return resp, err
+}
+
+// Snapshot of every breaker that has seen at least one request, keyed by endpoint.
+func (circuitBreakingHTTPClient *circuitBreakingHTTPClient) Stats() map[string]circuitBreakerStats {
+	circuitBreakingHTTPClient.mutex.Lock()
+	defer circuitBreakingHTTPClient.mutex.Unlock()
+
+	now := circuitBreakingHTTPClient.now()
+	stats := map[string]circuitBreakerStats{}
+	for endpoint, breaker := range circuitBreakingHTTPClient.breakers {
+		breakerStats := circuitBreakerStats{
+			State:    breaker.state,
+			Requests: breaker.requests,
+			Failures: breaker.failures,
+		}
+		if breaker.requests > 0 {
+			breakerStats.FailureRate = float64(breaker.failures) / float64(breaker.requests)
+		}
+		if breaker.state == circuitOpen {
+			breakerStats.RetryAfterSeconds = retryAfterSeconds(breaker.openedAt.Add(circuitBreakingHTTPClient.Options.CoolDown).Sub(now))
+		}
+		stats[endpoint] = breakerStats
+	}
+	return stats
+}
+
+func (circuitBreakingHTTPClient *circuitBreakingHTTPClient) breaker(endpoint string) *circuitBreaker {
+	breaker, ok := circuitBreakingHTTPClient.breakers[endpoint]
+	if !ok {
+		breaker = &circuitBreaker{
+			state:    circuitClosed,
+			outcomes: make([]bool, circuitBreakingHTTPClient.Options.WindowSize),
+		}
+		circuitBreakingHTTPClient.breakers[endpoint] = breaker
+	}
+	return breaker
+}
+
+// Handed out by allow for every request that may go through, and handed back along with how it went.
+type circuitPermit struct {
+	endpoint string
+
+	// Whether this request is the half-open probe, rather than one that was let through while the breaker was
+	// closed. Only the probe's outcome decides whether a half-open breaker closes again.
+	probe bool
+}
+
+// Decide whether a request to the given endpoint may go through, transitioning from open to half-open if the
+// cool-down has elapsed.
+func (circuitBreakingHTTPClient *circuitBreakingHTTPClient) allow(endpoint string) (circuitPermit, error) {
+	circuitBreakingHTTPClient.mutex.Lock()
+	defer circuitBreakingHTTPClient.mutex.Unlock()
+
+	breaker := circuitBreakingHTTPClient.breaker(endpoint)
+	switch breaker.state {
+	case circuitOpen:
+		remaining := breaker.openedAt.Add(circuitBreakingHTTPClient.Options.CoolDown).Sub(circuitBreakingHTTPClient.now())
+		if remaining > 0 {
+			return circuitPermit{}, &circuitOpenError{Endpoint: endpoint, RetryAfter: remaining}
+		}
+		breaker.state = circuitHalfOpen
+		breaker.probing = true
+		return circuitPermit{endpoint: endpoint, probe: true}, nil
+	case circuitHalfOpen:
+		// Only a single probe is allowed at a time. Everyone else should check back shortly.
+		if breaker.probing {
+			return circuitPermit{}, &circuitOpenError{Endpoint: endpoint, RetryAfter: time.Second}
+		}
+		breaker.probing = true
+		return circuitPermit{endpoint: endpoint, probe: true}, nil
+	default:
+		return circuitPermit{endpoint: endpoint}, nil
+	}
+}
+
+func (circuitBreakingHTTPClient *circuitBreakingHTTPClient) record(permit circuitPermit, failed bool) {
+	circuitBreakingHTTPClient.mutex.Lock()
+	defer circuitBreakingHTTPClient.mutex.Unlock()
+
+	breaker := circuitBreakingHTTPClient.breaker(permit.endpoint)
+	if permit.probe {
+		breaker.probing = false
+		if failed {
+			breaker.trip(circuitBreakingHTTPClient.now())
+		} else {
+			breaker.reset(circuitClosed)
+		}
+		return
+	}
+	// A request let through while closed can finish long after the breaker tripped, by which point its outcome is
+	// about a window that's already been judged.
+	if breaker.state == circuitClosed {
+		breaker.push(failed)
+		options := circuitBreakingHTTPClient.Options
+		if breaker.requests >= options.MinRequests && float64(breaker.failures)/float64(breaker.requests) >= options.FailureRateThreshold {
+			breaker.trip(circuitBreakingHTTPClient.now())
+		}
+	}
+}
+
+// Give up the half-open probe slot without counting the outcome, if permit holds it.
+func (circuitBreakingHTTPClient *circuitBreakingHTTPClient) release(permit circuitPermit) {
+	if !permit.probe {
+		return
+	}
+	circuitBreakingHTTPClient.mutex.Lock()
+	defer circuitBreakingHTTPClient.mutex.Unlock()
+	circuitBreakingHTTPClient.breaker(permit.endpoint).probing = false
+}
+
+func (breaker *circuitBreaker) push(failed bool) {
+	if len(breaker.outcomes) == 0 {
+		return
+	}
+	if breaker.requests == len(breaker.outcomes) {
+		// The window is full, so the oldest outcome falls out of it.
+		if breaker.outcomes[breaker.next] {
+			breaker.failures--
+		}
+	} else {
+		breaker.requests++
+	}
+	breaker.outcomes[breaker.next] = failed
+	if failed {
+		breaker.failures++
+	}
+	breaker.next = (breaker.next + 1) % len(breaker.outcomes)
+}
+
+func (breaker *circuitBreaker) trip(now time.Time) {
+	breaker.reset(circuitOpen)
+	breaker.openedAt = now
+}
+
+// Start over with a clean window in the given state.
+func (breaker *circuitBreaker) reset(state circuitState) {
+	breaker.state = state
+	breaker.requests = 0
+	breaker.failures = 0
+	breaker.next = 0
+	for i := range breaker.outcomes {
+		breaker.outcomes[i] = false
+	}
+}
+
+// The first path segment of a request to Cool Vendor, e.g. "users" for "/users/1" or "posts" for "/posts?userId=1".
+func upstreamEndpoint(req *http.Request) string {
+	return strings.SplitN(strings.TrimPrefix(req.URL.Path, "/"), "/", 2)[0]
+}
+
+// Round a duration up to whole seconds for a "Retry-After" header, which doesn't support anything more granular.
+func retryAfterSeconds(duration time.Duration) int {
+	seconds := int((duration + time.Second - 1) / time.Second)
+	if seconds < 1 {
+		return 1
+	}
+	return seconds
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// circuitBreakingHTTPClient.Do
+
+func TestCircuitBreakingHTTPClientTrips(t *testing.T) {
+	breaker, doCount := newTestCircuitBreakingHTTPClient(500)
+
+	for i := 0; i < testBreakerOptions.MinRequests; i++ {
+		resp, respErr := breaker.Do(newTestGetRequest(t, "/users/1"))
+		assert.Nil(t, respErr)
+		assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	}
+	assert.Equal(t, circuitOpen, breaker.Stats()["users"].State)
+
+	// Now that the breaker is open, requests should fail fast without ever reaching Cool Vendor.
+	resp, respErr := breaker.Do(newTestGetRequest(t, "/users/1"))
+	assert.Nil(t, resp)
+	var circuitErr *circuitOpenError
+	assert.True(t, errors.As(respErr, &circuitErr))
+	assert.Equal(t, "users", circuitErr.Endpoint)
+	assert.Equal(t, testBreakerOptions.CoolDown, circuitErr.RetryAfter)
+	assert.Equal(t, testBreakerOptions.MinRequests, *doCount)
+}
+
+func TestCircuitBreakingHTTPClientBelowThreshold(t *testing.T) {
+	breaker, _ := newTestCircuitBreakingHTTPClient(200)
+	doCount := 0
+	mockHTTPClientDo = func(*http.Request) (*http.Response, error) {
+		doCount++
+		// Fail every third request, which is below the 50% threshold.
+		if doCount%3 == 0 {
+			return nil, errFoo
+		}
+		return &http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(strings.NewReader("[]")),
+		}, nil
+	}
+
+	for i := 0; i < 3*testBreakerOptions.WindowSize; i++ {
+		breaker.Do(newTestGetRequest(t, "/posts?userId=1"))
+	}
+
+	stats := breaker.Stats()["posts"]
+	assert.Equal(t, circuitClosed, stats.State)
+	assert.Equal(t, testBreakerOptions.WindowSize, stats.Requests)
+}
+
+func TestCircuitBreakingHTTPClientIgnores404(t *testing.T) {
+	breaker, _ := newTestCircuitBreakingHTTPClient(404)
+
+	for i := 0; i < 2*testBreakerOptions.MinRequests; i++ {
+		breaker.Do(newTestGetRequest(t, "/users/123456"))
+	}
+
+	assert.Equal(t, circuitClosed, breaker.Stats()["users"].State)
+	assert.Equal(t, 0, breaker.Stats()["users"].Failures)
+}
+
+func TestCircuitBreakingHTTPClientPerEndpoint(t *testing.T) {
+	breaker, _ := newTestCircuitBreakingHTTPClient(200)
+	mockHTTPClientDo = func(r *http.Request) (*http.Response, error) {
+		if strings.HasPrefix(r.URL.Path, "/posts") {
+			return nil, errFoo
+		}
+		return &http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(strings.NewReader("{}")),
+		}, nil
+	}
+
+	for i := 0; i < testBreakerOptions.MinRequests; i++ {
+		breaker.Do(newTestGetRequest(t, "/posts?userId=1"))
+		breaker.Do(newTestGetRequest(t, "/users/1"))
+	}
+
+	assert.Equal(t, circuitOpen, breaker.Stats()["posts"].State)
+	assert.Equal(t, circuitClosed, breaker.Stats()["users"].State)
+	_, respErr := breaker.Do(newTestGetRequest(t, "/users/1"))
+	assert.Nil(t, respErr)
+}
+
+func TestCircuitBreakingHTTPClientHalfOpenRecovers(t *testing.T) {
+	breaker, doCount := newTestCircuitBreakingHTTPClient(500)
+	for i := 0; i < testBreakerOptions.MinRequests; i++ {
+		breaker.Do(newTestGetRequest(t, "/users/1"))
+	}
+	assert.Equal(t, circuitOpen, breaker.Stats()["users"].State)
+
+	// Once the cool-down elapses, a single probe is let through. Any concurrent request is still rejected.
+	breaker.now = func() time.Time { return time.Now().Add(testBreakerOptions.CoolDown) }
+	release := make(chan struct{})
+	probeDone := make(chan struct{})
+	mockHTTPClientDo = func(*http.Request) (*http.Response, error) {
+		*doCount++
+		<-release
+		return &http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(strings.NewReader("{}")),
+		}, nil
+	}
+	go func() {
+		resp, respErr := breaker.Do(newTestGetRequest(t, "/users/1"))
+		assert.Nil(t, respErr)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		close(probeDone)
+	}()
+	waitForBreakerState(t, breaker, "users", circuitHalfOpen)
+	_, respErr := breaker.Do(newTestGetRequest(t, "/users/1"))
+	assert.NotNil(t, respErr)
+	close(release)
+	<-probeDone
+
+	assert.Equal(t, circuitClosed, breaker.Stats()["users"].State)
+	assert.Equal(t, testBreakerOptions.MinRequests+1, *doCount)
+}
+
+func TestCircuitBreakingHTTPClientHalfOpenFails(t *testing.T) {
+	breaker, _ := newTestCircuitBreakingHTTPClient(500)
+	for i := 0; i < testBreakerOptions.MinRequests; i++ {
+		breaker.Do(newTestGetRequest(t, "/users/1"))
+	}
+
+	coolDownOver := time.Now().Add(testBreakerOptions.CoolDown)
+	breaker.now = func() time.Time { return coolDownOver }
+	resp, respErr := breaker.Do(newTestGetRequest(t, "/users/1"))
+	assert.Nil(t, respErr)
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+
+	// The failed probe should restart the cool-down from scratch.
+	stats := breaker.Stats()["users"]
+	assert.Equal(t, circuitOpen, stats.State)
+	assert.Equal(t, int(testBreakerOptions.CoolDown/time.Second), stats.RetryAfterSeconds)
+}
+
+// circuitBreakingHTTPClient.record and release
+
+func TestCircuitBreakingHTTPClientReleaseKeepsProbe(t *testing.T) {
+	breaker := newTestHalfOpenBreaker(t)
+	// Let through before the breaker tripped, and still in flight.
+	closedPermit := circuitPermit{endpoint: "users"}
+	probePermit, err := breaker.allow("users")
+	assert.Nil(t, err)
+	assert.True(t, probePermit.probe)
+
+	// Cancelling the older request shouldn't free up the probe slot for a second probe.
+	breaker.release(closedPermit)
+	_, err = breaker.allow("users")
+	var circuitErr *circuitOpenError
+	assert.True(t, errors.As(err, &circuitErr))
+
+	// Whereas cancelling the probe itself should.
+	breaker.release(probePermit)
+	probePermit, err = breaker.allow("users")
+	assert.Nil(t, err)
+	assert.True(t, probePermit.probe)
+}
+
+func TestCircuitBreakingHTTPClientRecordIgnoresLateResults(t *testing.T) {
+	breaker := newTestHalfOpenBreaker(t)
+	closedPermit := circuitPermit{endpoint: "users"}
+	probePermit, err := breaker.allow("users")
+	assert.Nil(t, err)
+
+	// An older request succeeding late says nothing about whether Cool Vendor recovered since the breaker tripped.
+	breaker.record(closedPermit, false)
+	assert.Equal(t, circuitHalfOpen, breaker.Stats()["users"].State)
+	_, err = breaker.allow("users")
+	assert.NotNil(t, err)
+
+	breaker.record(probePermit, false)
+	assert.Equal(t, circuitClosed, breaker.Stats()["users"].State)
+	assert.Equal(t, 0, breaker.Stats()["users"].Requests)
+}
+
+// Test Helpers
+
+// Build a circuitBreakingHTTPClient on top of mockHTTPClient that always responds with the given status code.
+func newTestCircuitBreakingHTTPClient(statusCode int) (*circuitBreakingHTTPClient, *int) {
+	doCount := 0
+	mockHTTPClientDo = func(*http.Request) (*http.Response, error) {
+		doCount++
+		return &http.Response{
+			StatusCode: statusCode,
+			Body:       ioutil.NopCloser(strings.NewReader("{}")),
+		}, nil
+	}
+	breaker := newCircuitBreakingHTTPClient(&mockHTTPClient{}, testBreakerOptions)
+	now := time.Now()
+	breaker.now = func() time.Time { return now }
+	return breaker, &doCount
+}
+
+// A breaker for the "users" endpoint that tripped, and whose cool-down has just elapsed.
+func newTestHalfOpenBreaker(t *testing.T) *circuitBreakingHTTPClient {
+	breaker, _ := newTestCircuitBreakingHTTPClient(500)
+	for i := 0; i < testBreakerOptions.MinRequests; i++ {
+		breaker.Do(newTestGetRequest(t, "/users/1"))
+	}
+	assert.Equal(t, circuitOpen, breaker.Stats()["users"].State)
+	coolDownOver := time.Now().Add(testBreakerOptions.CoolDown)
+	breaker.now = func() time.Time { return coolDownOver }
+	return breaker
+}
+
+func waitForBreakerState(t *testing.T, breaker *circuitBreakingHTTPClient, endpoint string, state circuitState) {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if breaker.Stats()[endpoint].State == state {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("Timed out waiting for the '%s' breaker to be %s", endpoint, state)
+}
+
+// Test Variables
+
+var testBreakerOptions = breakerOptions{
+	FailureRateThreshold: 0.5,
+	MinRequests:          4,
+	WindowSize:           10,
+	CoolDown:             30 * time.Second,
+}
@@ -0,0 +1,403 @@
+package main
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Clients - cachingHTTPClient
+//
+// In-memory response cache that sits in front of any httpClient, which lets us cache Cool Vendor's responses
+// without having to touch each individual typicodeClient method. Only successful GETs and 404s are cached since
+// those are the only responses Cool Vendor guarantees are safe to reuse. Everything else always goes upstream.
+//
+// Expired responses aren't thrown away right away. Same as "stale-while-revalidate" and "stale-if-error" from RFC 5861,
+// a response that only just expired is still served as-is while it's refreshed in the background, and one that
+// expired a while ago is still served if going upstream for a fresh one fails. Either way, every response served from
+// the cache says how old it is with an "Age" header, and stale ones also get a "Warning" header saying so.
+//
+// @see https://www.rfc-editor.org/rfc/rfc5861
+
+// Tuning knobs for cachingHTTPClient.
+type cacheOptions struct {
+	// How long a 200 Ok response is served from the cache before going back upstream.
+	TTL time.Duration
+
+	// How long a 404 Not Found response is served from the cache. This is tracked separately from TTL because
+	// a user that doesn't exist yet may be created at any time, so we generally want to hold onto these for less
+	// time. Zero disables caching of 404s entirely.
+	NotFoundTTL time.Duration
+
+	// Maximum number of responses held at once. The least recently used response is evicted once this is exceeded.
+	MaxEntries int
+
+	// How long after expiring a response is still served as-is, while a fresh one is fetched in the background.
+	// Zero always waits on a fresh response instead.
+	StaleWhileRevalidate time.Duration
+
+	// How long after expiring a response is still served when fetching a fresh one fails, i.e. Cool Vendor timed out,
+	// couldn't be reached, or responded with a 5xx or 429. Zero returns the failure instead.
+	StaleIfError time.Duration
+
+	// Upper bound on how long a background refresh can take, since there's no consumer request to bound it.
+	RevalidateTimeout time.Duration
+}
+
+// Warnings on stale responses, as defined by RFC 7234. "Warning" has since been deprecated, but it's still the most
+// widely understood way of telling consumers why they got a stale response.
+//
+// @see https://www.rfc-editor.org/rfc/rfc7234#section-5.5
+const (
+	warningResponseIsStale    = `110 - "Response is Stale"`
+	warningRevalidationFailed = `111 - "Revalidation Failed"`
+)
+
+// Hit/miss counters for cachingHTTPClient, mostly useful for tuning the TTLs.
+type cacheStats struct {
+	Hits         uint64 `json:"hits"`
+	NotFoundHits uint64 `json:"notFoundHits"`
+	Misses       uint64 `json:"misses"`
+	Bypasses     uint64 `json:"bypasses"`
+	Evictions    uint64 `json:"evictions"`
+	Entries      int    `json:"entries"`
+
+	// Stale responses served while they were refreshed in the background, and because going upstream failed.
+	StaleHits        uint64 `json:"staleHits"`
+	StaleIfErrorHits uint64 `json:"staleIfErrorHits"`
+
+	// Background refreshes of stale responses, and how many of those failed.
+	Revalidations        uint64 `json:"revalidations"`
+	RevalidationFailures uint64 `json:"revalidationFailures"`
+}
+
+type cachingHTTPClient struct {
+	Client  httpClient
+	Options cacheOptions
+
+	// Overridable for unit tests so that we don't need to actually sleep to expire entries.
+	now func() time.Time
+
+	mutex   sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+	stats   cacheStats
+
+	// Keys with a background refresh in flight, so that a burst of requests for the same stale response only
+	// refreshes it once.
+	revalidating map[string]bool
+
+	// Tracks background refreshes so that unit tests can wait on them.
+	revalidations sync.WaitGroup
+}
+
+// A single cached response. The body is fully buffered so that it can be replayed any number of times, which also
+// makes it handy for anything else that needs to share one response across multiple callers.
+type cacheEntry struct {
+	key        string
+	statusCode int
+	header     http.Header
+	body       []byte
+	expiresAt  time.Time
+
+	// When the response was stored, and how old it already was according to its own "Age" header, e.g. from a CDN in
+	// front of Cool Vendor. Together, these make up its current age.
+	storedAt  time.Time
+	storedAge time.Duration
+}
+
+// How usable a cached response is at a given point in time.
+type cacheFreshness int
+
+const (
+	// Nothing usable is cached.
+	cacheMiss cacheFreshness = iota
+
+	// Served as-is.
+	cacheFresh
+
+	// Served as-is, but also refreshed in the background.
+	cacheStaleWhileRevalidate
+
+	// Only served if fetching a fresh response fails.
+	cacheStaleIfError
+)
+
+func newCachingHTTPClient(client httpClient, options cacheOptions) *cachingHTTPClient {
+	return &cachingHTTPClient{
+		Client:  client,
+		Options: options,
+		now:     time.Now,
+		entries: map[string]*list.Element{},
+		lru:     list.New(),
+
+		revalidating: map[string]bool{},
+	}
+}
+
+func (cachingHTTPClient *cachingHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		return cachingHTTPClient.Client.Do(req)
+	}
+
+	key := req.URL.String()
+
+	// Honor "Cache-Control: no-cache" by skipping the lookup, but still store the fresh response so that the next
+	// caller benefits from it. Such callers asked for fresh data, so they never get a stale response either.
+	var stale *cacheEntry
+	if isNoCache(req.Header) {
+		cachingHTTPClient.mutex.Lock()
+		cachingHTTPClient.stats.Bypasses++
+		cachingHTTPClient.mutex.Unlock()
+	} else {
+		entry, freshness := cachingHTTPClient.get(key)
+		switch freshness {
+		case cacheFresh:
+			return cachingHTTPClient.toCachedResponse(entry, req), nil
+		case cacheStaleWhileRevalidate:
+			cachingHTTPClient.revalidate(req)
+			return cachingHTTPClient.toCachedResponse(entry, req, warningResponseIsStale), nil
+		case cacheStaleIfError:
+			stale = entry
+		}
+	}
+
+	resp, err := cachingHTTPClient.Client.Do(req)
+
+	// Nobody's waiting on a cancelled request, so there's no point in handing them a stale response either.
+	if stale != nil && isUpstreamFailure(resp, err) && !errors.Is(err, context.Canceled) {
+		if resp != nil {
+			resp.Body.Close()
+		}
+		cachingHTTPClient.mutex.Lock()
+		cachingHTTPClient.stats.StaleIfErrorHits++
+		cachingHTTPClient.mutex.Unlock()
+		return cachingHTTPClient.toCachedResponse(stale, req, warningResponseIsStale, warningRevalidationFailed), nil
+	}
+	if err != nil {
+		return resp, err
+	}
+	return cachingHTTPClient.store(key, req, resp)
+}
+
+// Cache the response, if it's cacheable, and return it to the current caller.
+func (cachingHTTPClient *cachingHTTPClient) store(key string, req *http.Request, resp *http.Response) (*http.Response, error) {
+	ttl := cachingHTTPClient.ttlFor(resp.StatusCode)
+	if ttl <= 0 {
+		return resp, nil
+	}
+
+	// Buffer the body so that it can be both cached and returned to the current caller.
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	now := cachingHTTPClient.now()
+	entry := &cacheEntry{
+		key:        key,
+		statusCode: resp.StatusCode,
+		header:     resp.Header.Clone(),
+		body:       body,
+		expiresAt:  now.Add(ttl),
+		storedAt:   now,
+		storedAge:  parseAge(resp.Header),
+	}
+	cachingHTTPClient.put(entry)
+	return entry.toResponse(req), nil
+}
+
+// Fetch a fresh copy of a stale response in the background, unless that's already happening. The refresh is detached
+// from the caller's request since the whole point is that they don't wait on it.
+func (cachingHTTPClient *cachingHTTPClient) revalidate(req *http.Request) {
+	key := req.URL.String()
+	cachingHTTPClient.mutex.Lock()
+	if cachingHTTPClient.revalidating[key] {
+		cachingHTTPClient.mutex.Unlock()
+		return
+	}
+	cachingHTTPClient.revalidating[key] = true
+	cachingHTTPClient.stats.Revalidations++
+	cachingHTTPClient.mutex.Unlock()
+
+	cachingHTTPClient.revalidations.Add(1)
+	go func() {
+		defer cachingHTTPClient.revalidations.Done()
+
+		ctx := context.Background()
+		if cachingHTTPClient.Options.RevalidateTimeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, cachingHTTPClient.Options.RevalidateTimeout)
+			defer cancel()
+		}
+
+		// A failed refresh leaves the stale response as-is, which stays usable until its stale windows run out.
+		resp, err := cachingHTTPClient.Client.Do(req.Clone(ctx))
+		if err == nil && !isUpstreamFailure(resp, nil) {
+			resp, err = cachingHTTPClient.store(key, req, resp)
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+
+		cachingHTTPClient.mutex.Lock()
+		delete(cachingHTTPClient.revalidating, key)
+		if err != nil || isUpstreamFailure(resp, nil) {
+			cachingHTTPClient.stats.RevalidationFailures++
+		}
+		cachingHTTPClient.mutex.Unlock()
+	}()
+}
+
+// Build a response for a cached entry that says how old it is, along with any warnings.
+func (cachingHTTPClient *cachingHTTPClient) toCachedResponse(entry *cacheEntry, req *http.Request, warnings ...string) *http.Response {
+	resp := entry.toResponse(req)
+	if resp.Header == nil {
+		resp.Header = http.Header{}
+	}
+	resp.Header.Set("Age", strconv.Itoa(int(entry.age(cachingHTTPClient.now()).Seconds())))
+	for _, warning := range warnings {
+		resp.Header.Add("Warning", warning)
+	}
+	return resp
+}
+
+// Snapshot of the current counters.
+func (cachingHTTPClient *cachingHTTPClient) Stats() cacheStats {
+	cachingHTTPClient.mutex.Lock()
+	defer cachingHTTPClient.mutex.Unlock()
+	stats := cachingHTTPClient.stats
+	stats.Entries = cachingHTTPClient.lru.Len()
+	return stats
+}
+
+func (cachingHTTPClient *cachingHTTPClient) ttlFor(statusCode int) time.Duration {
+	switch statusCode {
+	case http.StatusOK:
+		return cachingHTTPClient.Options.TTL
+	case http.StatusNotFound:
+		return cachingHTTPClient.Options.NotFoundTTL
+	default:
+		return 0
+	}
+}
+
+func (cachingHTTPClient *cachingHTTPClient) get(key string) (*cacheEntry, cacheFreshness) {
+	cachingHTTPClient.mutex.Lock()
+	defer cachingHTTPClient.mutex.Unlock()
+
+	element, ok := cachingHTTPClient.entries[key]
+	if !ok {
+		cachingHTTPClient.stats.Misses++
+		return nil, cacheMiss
+	}
+
+	entry := element.Value.(*cacheEntry)
+	freshness := cachingHTTPClient.freshnessOf(entry)
+	switch freshness {
+	case cacheMiss:
+		cachingHTTPClient.removeElement(element)
+		cachingHTTPClient.stats.Misses++
+		return nil, cacheMiss
+	case cacheStaleIfError:
+		// Still counts as a miss since it's only a fallback for going upstream.
+		cachingHTTPClient.lru.MoveToFront(element)
+		cachingHTTPClient.stats.Misses++
+		return entry, cacheStaleIfError
+	}
+
+	cachingHTTPClient.lru.MoveToFront(element)
+	if freshness == cacheStaleWhileRevalidate {
+		cachingHTTPClient.stats.StaleHits++
+	} else if entry.statusCode == http.StatusNotFound {
+		cachingHTTPClient.stats.NotFoundHits++
+	} else {
+		cachingHTTPClient.stats.Hits++
+	}
+	return entry, freshness
+}
+
+func (cachingHTTPClient *cachingHTTPClient) freshnessOf(entry *cacheEntry) cacheFreshness {
+	expiredFor := cachingHTTPClient.now().Sub(entry.expiresAt)
+	switch {
+	case expiredFor < 0:
+		return cacheFresh
+	case expiredFor < cachingHTTPClient.Options.StaleWhileRevalidate:
+		return cacheStaleWhileRevalidate
+	case expiredFor < cachingHTTPClient.Options.StaleIfError:
+		return cacheStaleIfError
+	default:
+		return cacheMiss
+	}
+}
+
+func (cachingHTTPClient *cachingHTTPClient) put(entry *cacheEntry) {
+	cachingHTTPClient.mutex.Lock()
+	defer cachingHTTPClient.mutex.Unlock()
+
+	if element, ok := cachingHTTPClient.entries[entry.key]; ok {
+		element.Value = entry
+		cachingHTTPClient.lru.MoveToFront(element)
+	} else {
+		cachingHTTPClient.entries[entry.key] = cachingHTTPClient.lru.PushFront(entry)
+	}
+
+	for cachingHTTPClient.Options.MaxEntries > 0 && cachingHTTPClient.lru.Len() > cachingHTTPClient.Options.MaxEntries {
+		cachingHTTPClient.removeElement(cachingHTTPClient.lru.Back())
+		cachingHTTPClient.stats.Evictions++
+	}
+}
+
+// Must be called while holding the mutex.
+func (cachingHTTPClient *cachingHTTPClient) removeElement(element *list.Element) {
+	cachingHTTPClient.lru.Remove(element)
+	delete(cachingHTTPClient.entries, element.Value.(*cacheEntry).key)
+}
+
+// How old the response is as of now, including however old it already was when it was stored.
+func (entry *cacheEntry) age(now time.Time) time.Duration {
+	return entry.storedAge + now.Sub(entry.storedAt)
+}
+
+// Build a fresh response for every caller since response bodies can only be read once.
+func (entry *cacheEntry) toResponse(req *http.Request) *http.Response {
+	return &http.Response{
+		StatusCode: entry.statusCode,
+		Header:     entry.header.Clone(),
+		Body:       ioutil.NopCloser(bytes.NewReader(entry.body)),
+		Request:    req,
+	}
+}
+
+func isNoCache(header http.Header) bool {
+	for _, directive := range strings.Split(header.Get("Cache-Control"), ",") {
+		if strings.EqualFold(strings.TrimSpace(directive), "no-cache") {
+			return true
+		}
+	}
+	return false
+}
+
+// Whether a fresh response couldn't be fetched, such that a stale one is better than nothing.
+func isUpstreamFailure(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests
+}
+
+// Parse the "Age" header, which is in whole seconds. Anything missing or malformed counts as brand new.
+func parseAge(header http.Header) time.Duration {
+	seconds, err := strconv.Atoi(header.Get("Age"))
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
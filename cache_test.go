@@ -0,0 +1,441 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// cachingHTTPClient.Do
+
+func TestCachingHTTPClientHit(t *testing.T) {
+	doCount := 0
+	mockHTTPClientDo = func(r *http.Request) (*http.Response, error) {
+		doCount++
+		return &http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(strings.NewReader("hello")),
+		}, nil
+	}
+	cache := newCachingHTTPClient(&mockHTTPClient{}, testCacheOptions)
+
+	for i := 0; i < 3; i++ {
+		resp, respErr := cache.Do(newTestGetRequest(t, "/users/1"))
+		assert.Nil(t, respErr)
+		body, bodyErr := ioutil.ReadAll(resp.Body)
+		assert.Nil(t, bodyErr)
+		assert.Equal(t, "hello", string(body))
+	}
+
+	assert.Equal(t, 1, doCount)
+	assert.Equal(t, cacheStats{Hits: 2, Misses: 1, Entries: 1}, cache.Stats())
+}
+
+func TestCachingHTTPClientNotFound(t *testing.T) {
+	doCount := 0
+	mockHTTPClientDo = func(r *http.Request) (*http.Response, error) {
+		doCount++
+		return &http.Response{
+			StatusCode: 404,
+			Body:       ioutil.NopCloser(strings.NewReader("{}")),
+		}, nil
+	}
+	cache := newCachingHTTPClient(&mockHTTPClient{}, testCacheOptions)
+
+	cache.Do(newTestGetRequest(t, "/users/123456"))
+	resp, respErr := cache.Do(newTestGetRequest(t, "/users/123456"))
+	assert.Nil(t, respErr)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Equal(t, 1, doCount)
+	assert.Equal(t, cacheStats{NotFoundHits: 1, Misses: 1, Entries: 1}, cache.Stats())
+
+	// 404s expire on their own, shorter TTL.
+	cache.now = func() time.Time { return time.Now().Add(testCacheOptions.NotFoundTTL) }
+	cache.Do(newTestGetRequest(t, "/users/123456"))
+	assert.Equal(t, 2, doCount)
+}
+
+func TestCachingHTTPClientNotFoundDisabled(t *testing.T) {
+	doCount := 0
+	mockHTTPClientDo = func(r *http.Request) (*http.Response, error) {
+		doCount++
+		return &http.Response{
+			StatusCode: 404,
+			Body:       ioutil.NopCloser(strings.NewReader("{}")),
+		}, nil
+	}
+	cache := newCachingHTTPClient(&mockHTTPClient{}, cacheOptions{TTL: time.Minute, MaxEntries: 10})
+
+	cache.Do(newTestGetRequest(t, "/users/123456"))
+	cache.Do(newTestGetRequest(t, "/users/123456"))
+	assert.Equal(t, 2, doCount)
+}
+
+func TestCachingHTTPClientExpired(t *testing.T) {
+	doCount := 0
+	mockHTTPClientDo = func(r *http.Request) (*http.Response, error) {
+		doCount++
+		return &http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(strings.NewReader("hello")),
+		}, nil
+	}
+	cache := newCachingHTTPClient(&mockHTTPClient{}, testCacheOptions)
+
+	cache.Do(newTestGetRequest(t, "/users/1"))
+	cache.now = func() time.Time { return time.Now().Add(testCacheOptions.TTL) }
+	cache.Do(newTestGetRequest(t, "/users/1"))
+
+	assert.Equal(t, 2, doCount)
+	assert.Equal(t, uint64(2), cache.Stats().Misses)
+}
+
+func TestCachingHTTPClientNoCache(t *testing.T) {
+	doCount := 0
+	mockHTTPClientDo = func(r *http.Request) (*http.Response, error) {
+		doCount++
+		return &http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(strings.NewReader(fmt.Sprint("hello #", doCount))),
+		}, nil
+	}
+	cache := newCachingHTTPClient(&mockHTTPClient{}, testCacheOptions)
+
+	cache.Do(newTestGetRequest(t, "/users/1"))
+	req := newTestGetRequest(t, "/users/1")
+	req.Header.Set("Cache-Control", "no-cache")
+	cache.Do(req)
+
+	// The bypassed response should still refresh the cache for everyone else.
+	resp, respErr := cache.Do(newTestGetRequest(t, "/users/1"))
+	assert.Nil(t, respErr)
+	body, bodyErr := ioutil.ReadAll(resp.Body)
+	assert.Nil(t, bodyErr)
+	assert.Equal(t, "hello #2", string(body))
+	assert.Equal(t, 2, doCount)
+	assert.Equal(t, cacheStats{Hits: 1, Misses: 1, Bypasses: 1, Entries: 1}, cache.Stats())
+}
+
+func TestCachingHTTPClientLRUEviction(t *testing.T) {
+	doCount := 0
+	mockHTTPClientDo = func(r *http.Request) (*http.Response, error) {
+		doCount++
+		return &http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(strings.NewReader("hello")),
+		}, nil
+	}
+	cache := newCachingHTTPClient(&mockHTTPClient{}, cacheOptions{TTL: time.Minute, MaxEntries: 2})
+
+	cache.Do(newTestGetRequest(t, "/users/1"))
+	cache.Do(newTestGetRequest(t, "/users/2"))
+	// Touch user 1 so that user 2 becomes the least recently used.
+	cache.Do(newTestGetRequest(t, "/users/1"))
+	cache.Do(newTestGetRequest(t, "/users/3"))
+	assert.Equal(t, 3, doCount)
+
+	cache.Do(newTestGetRequest(t, "/users/1"))
+	assert.Equal(t, 3, doCount)
+	cache.Do(newTestGetRequest(t, "/users/2"))
+	assert.Equal(t, 4, doCount)
+	assert.Equal(t, 2, cache.Stats().Entries)
+}
+
+func TestCachingHTTPClientServerErrorNotCached(t *testing.T) {
+	doCount := 0
+	mockHTTPClientDo = func(r *http.Request) (*http.Response, error) {
+		doCount++
+		return &http.Response{
+			StatusCode: 500,
+			Body:       ioutil.NopCloser(strings.NewReader(errMsg500)),
+		}, nil
+	}
+	cache := newCachingHTTPClient(&mockHTTPClient{}, testCacheOptions)
+
+	cache.Do(newTestGetRequest(t, "/users/1"))
+	resp, respErr := cache.Do(newTestGetRequest(t, "/users/1"))
+	assert.Nil(t, respErr)
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	assert.Equal(t, 2, doCount)
+	assert.Equal(t, 0, cache.Stats().Entries)
+}
+
+func TestCachingHTTPClientExecutionErr(t *testing.T) {
+	mockHTTPClientDo = func(r *http.Request) (*http.Response, error) {
+		return nil, errFoo
+	}
+	cache := newCachingHTTPClient(&mockHTTPClient{}, testCacheOptions)
+
+	resp, respErr := cache.Do(newTestGetRequest(t, "/users/1"))
+	assert.Nil(t, resp)
+	assert.Equal(t, errFoo, respErr)
+}
+
+func TestCachingHTTPClientAge(t *testing.T) {
+	mockHTTPClientDo = func(r *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: 200,
+			Header:     http.Header{"Age": []string{"3"}},
+			Body:       ioutil.NopCloser(strings.NewReader("hello")),
+		}, nil
+	}
+	cache := newCachingHTTPClient(&mockHTTPClient{}, testCacheOptions)
+
+	// Whatever Cool Vendor says is passed along as-is...
+	resp, _ := cache.Do(newTestGetRequest(t, "/users/1"))
+	assert.Equal(t, "3", resp.Header.Get("Age"))
+
+	// ...and then keeps aging while it's cached.
+	storedAt := time.Now()
+	cache.now = func() time.Time { return storedAt.Add(5 * time.Second) }
+	resp, _ = cache.Do(newTestGetRequest(t, "/users/1"))
+	assert.Equal(t, "8", resp.Header.Get("Age"))
+	assert.Empty(t, resp.Header.Values("Warning"))
+}
+
+func TestCachingHTTPClientStaleWhileRevalidate(t *testing.T) {
+	doCount := 0
+	mockHTTPClientDo = func(r *http.Request) (*http.Response, error) {
+		doCount++
+		return &http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(strings.NewReader(fmt.Sprint("v", doCount))),
+		}, nil
+	}
+	cache := newCachingHTTPClient(&mockHTTPClient{}, cacheOptions{TTL: time.Minute, MaxEntries: 10, StaleWhileRevalidate: time.Minute})
+
+	cache.Do(newTestGetRequest(t, "/users/1"))
+	storedAt := time.Now()
+	cache.now = func() time.Time { return storedAt.Add(90 * time.Second) }
+
+	// The stale response is served right away, while a fresh one is fetched in the background.
+	resp, respErr := cache.Do(newTestGetRequest(t, "/users/1"))
+	assert.Nil(t, respErr)
+	assert.Equal(t, "v1", readTestBody(t, resp))
+	assert.Equal(t, "90", resp.Header.Get("Age"))
+	assert.Equal(t, []string{warningResponseIsStale}, resp.Header.Values("Warning"))
+	cache.revalidations.Wait()
+
+	resp, _ = cache.Do(newTestGetRequest(t, "/users/1"))
+	assert.Equal(t, "v2", readTestBody(t, resp))
+	assert.Empty(t, resp.Header.Values("Warning"))
+	assert.Equal(t, 2, doCount)
+	assert.Equal(t, cacheStats{Hits: 1, Misses: 1, StaleHits: 1, Revalidations: 1, Entries: 1}, cache.Stats())
+}
+
+func TestCachingHTTPClientStaleWhileRevalidateOnce(t *testing.T) {
+	var doCount int32
+	release := make(chan struct{})
+	mockHTTPClientDo = func(r *http.Request) (*http.Response, error) {
+		if atomic.AddInt32(&doCount, 1) > 1 {
+			<-release
+		}
+		return &http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(strings.NewReader("hello")),
+		}, nil
+	}
+	cache := newCachingHTTPClient(&mockHTTPClient{}, cacheOptions{TTL: time.Minute, MaxEntries: 10, StaleWhileRevalidate: time.Minute})
+
+	cache.Do(newTestGetRequest(t, "/users/1"))
+	storedAt := time.Now()
+	cache.now = func() time.Time { return storedAt.Add(90 * time.Second) }
+	for i := 0; i < 3; i++ {
+		cache.Do(newTestGetRequest(t, "/users/1"))
+	}
+	close(release)
+	cache.revalidations.Wait()
+
+	assert.Equal(t, int32(2), atomic.LoadInt32(&doCount))
+	assert.Equal(t, uint64(3), cache.Stats().StaleHits)
+	assert.Equal(t, uint64(1), cache.Stats().Revalidations)
+}
+
+func TestCachingHTTPClientStaleWhileRevalidateFailure(t *testing.T) {
+	doCount := 0
+	mockHTTPClientDo = func(r *http.Request) (*http.Response, error) {
+		doCount++
+		if doCount > 1 {
+			return &http.Response{
+				StatusCode: 500,
+				Body:       ioutil.NopCloser(strings.NewReader(errMsg500)),
+			}, nil
+		}
+		return &http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(strings.NewReader("hello")),
+		}, nil
+	}
+	cache := newCachingHTTPClient(&mockHTTPClient{}, cacheOptions{TTL: time.Minute, MaxEntries: 10, StaleWhileRevalidate: time.Minute})
+
+	cache.Do(newTestGetRequest(t, "/users/1"))
+	storedAt := time.Now()
+	cache.now = func() time.Time { return storedAt.Add(90 * time.Second) }
+	cache.Do(newTestGetRequest(t, "/users/1"))
+	cache.revalidations.Wait()
+
+	// The stale response is left as-is for the next caller.
+	resp, _ := cache.Do(newTestGetRequest(t, "/users/1"))
+	assert.Equal(t, "hello", readTestBody(t, resp))
+	assert.Equal(t, uint64(2), cache.Stats().Revalidations)
+	assert.Equal(t, uint64(1), cache.Stats().RevalidationFailures)
+	cache.revalidations.Wait()
+}
+
+func TestCachingHTTPClientStaleIfError(t *testing.T) {
+	for name, fail := range map[string]func() (*http.Response, error){
+		"error": func() (*http.Response, error) { return nil, errFoo },
+		"503": func() (*http.Response, error) {
+			return &http.Response{StatusCode: 503, Body: ioutil.NopCloser(strings.NewReader(errMsg500))}, nil
+		},
+		"429": func() (*http.Response, error) {
+			return &http.Response{StatusCode: 429, Body: ioutil.NopCloser(strings.NewReader(errMsg500))}, nil
+		},
+	} {
+		doCount := 0
+		mockHTTPClientDo = func(r *http.Request) (*http.Response, error) {
+			doCount++
+			if doCount > 1 {
+				return fail()
+			}
+			return &http.Response{
+				StatusCode: 200,
+				Body:       ioutil.NopCloser(strings.NewReader("hello")),
+			}, nil
+		}
+		cache := newCachingHTTPClient(&mockHTTPClient{}, cacheOptions{TTL: time.Minute, MaxEntries: 10, StaleIfError: time.Hour})
+
+		cache.Do(newTestGetRequest(t, "/users/1"))
+		storedAt := time.Now()
+		cache.now = func() time.Time { return storedAt.Add(30 * time.Minute) }
+		resp, respErr := cache.Do(newTestGetRequest(t, "/users/1"))
+
+		assert.Nil(t, respErr, name)
+		assert.Equal(t, http.StatusOK, resp.StatusCode, name)
+		assert.Equal(t, "hello", readTestBody(t, resp), name)
+		assert.Equal(t, "1800", resp.Header.Get("Age"), name)
+		assert.Equal(t, []string{warningResponseIsStale, warningRevalidationFailed}, resp.Header.Values("Warning"), name)
+		assert.Equal(t, cacheStats{Misses: 2, StaleIfErrorHits: 1, Entries: 1}, cache.Stats(), name)
+	}
+}
+
+func TestCachingHTTPClientStaleIfErrorRefreshed(t *testing.T) {
+	doCount := 0
+	mockHTTPClientDo = func(r *http.Request) (*http.Response, error) {
+		doCount++
+		return &http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(strings.NewReader(fmt.Sprint("v", doCount))),
+		}, nil
+	}
+	cache := newCachingHTTPClient(&mockHTTPClient{}, cacheOptions{TTL: time.Minute, MaxEntries: 10, StaleIfError: time.Hour})
+
+	cache.Do(newTestGetRequest(t, "/users/1"))
+	storedAt := time.Now()
+	cache.now = func() time.Time { return storedAt.Add(30 * time.Minute) }
+
+	// Going upstream worked, so there's no need for the stale response.
+	resp, _ := cache.Do(newTestGetRequest(t, "/users/1"))
+	assert.Equal(t, "v2", readTestBody(t, resp))
+	assert.Empty(t, resp.Header.Get("Age"))
+}
+
+func TestCachingHTTPClientStaleIfErrorExpired(t *testing.T) {
+	doCount := 0
+	mockHTTPClientDo = func(r *http.Request) (*http.Response, error) {
+		doCount++
+		if doCount > 1 {
+			return nil, errFoo
+		}
+		return &http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(strings.NewReader("hello")),
+		}, nil
+	}
+	cache := newCachingHTTPClient(&mockHTTPClient{}, cacheOptions{TTL: time.Minute, MaxEntries: 10, StaleIfError: time.Hour})
+
+	cache.Do(newTestGetRequest(t, "/users/1"))
+	storedAt := time.Now()
+	cache.now = func() time.Time { return storedAt.Add(2 * time.Hour) }
+	resp, respErr := cache.Do(newTestGetRequest(t, "/users/1"))
+
+	assert.Nil(t, resp)
+	assert.Equal(t, errFoo, respErr)
+	assert.Equal(t, 0, cache.Stats().Entries)
+}
+
+func TestCachingHTTPClientStaleIfErrorCanceled(t *testing.T) {
+	doCount := 0
+	mockHTTPClientDo = func(r *http.Request) (*http.Response, error) {
+		doCount++
+		if doCount > 1 {
+			return nil, context.Canceled
+		}
+		return &http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(strings.NewReader("hello")),
+		}, nil
+	}
+	cache := newCachingHTTPClient(&mockHTTPClient{}, cacheOptions{TTL: time.Minute, MaxEntries: 10, StaleIfError: time.Hour})
+
+	cache.Do(newTestGetRequest(t, "/users/1"))
+	storedAt := time.Now()
+	cache.now = func() time.Time { return storedAt.Add(30 * time.Minute) }
+	_, respErr := cache.Do(newTestGetRequest(t, "/users/1"))
+
+	assert.Equal(t, context.Canceled, respErr)
+}
+
+func TestCachingHTTPClientStaleNoCache(t *testing.T) {
+	doCount := 0
+	mockHTTPClientDo = func(r *http.Request) (*http.Response, error) {
+		doCount++
+		if doCount > 1 {
+			return nil, errFoo
+		}
+		return &http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(strings.NewReader("hello")),
+		}, nil
+	}
+	cache := newCachingHTTPClient(&mockHTTPClient{}, cacheOptions{TTL: time.Minute, MaxEntries: 10, StaleWhileRevalidate: time.Hour, StaleIfError: time.Hour})
+
+	cache.Do(newTestGetRequest(t, "/users/1"))
+	storedAt := time.Now()
+	cache.now = func() time.Time { return storedAt.Add(30 * time.Minute) }
+	req := newTestGetRequest(t, "/users/1")
+	req.Header.Set("Cache-Control", "no-cache")
+	_, respErr := cache.Do(req)
+
+	// Asking for fresh data means never getting stale data instead.
+	assert.Equal(t, errFoo, respErr)
+}
+
+// Test Helpers
+
+func readTestBody(t *testing.T, resp *http.Response) string {
+	body, err := ioutil.ReadAll(resp.Body)
+	assert.Nil(t, err)
+	return string(body)
+}
+
+func newTestGetRequest(t *testing.T, path string) *http.Request {
+	req, err := http.NewRequest(http.MethodGet, mockBaseURL+path, nil)
+	assert.Nil(t, err)
+	return req
+}
+
+// Test Variables
+
+var testCacheOptions = cacheOptions{
+	TTL:         time.Minute,
+	NotFoundTTL: 10 * time.Second,
+	MaxEntries:  10,
+}
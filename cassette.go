@@ -0,0 +1,238 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Clients - cassetteHTTPClient
+//
+// Records real request/response pairs with Cool Vendor to a "cassette" file, and replays them later without any
+// network access, so that tests and local runs can work off of Cool Vendor's actual payloads rather than
+// hand-written mocks that slowly drift from the real thing.
+//
+// Requests are matched on their method, path, and query. The query is compared regardless of parameter order, and
+// the host is ignored entirely so that a cassette recorded against one base URL can be replayed against any other.
+
+type cassetteMode string
+
+const (
+	// Go straight to the underlying client without recording or replaying anything.
+	cassettePassthrough cassetteMode = "passthrough"
+
+	// Go to the underlying client and save every interaction to the cassette.
+	cassetteRecord cassetteMode = "record"
+
+	// Serve every request from the cassette without ever touching the underlying client.
+	cassetteReplay cassetteMode = "replay"
+)
+
+var cassetteModes = []cassetteMode{cassettePassthrough, cassetteRecord, cassetteReplay}
+
+func isCassetteMode(mode cassetteMode) bool {
+	for _, cassetteMode := range cassetteModes {
+		if mode == cassetteMode {
+			return true
+		}
+	}
+	return false
+}
+
+// On-disk format of a cassette file.
+type cassette struct {
+	Interactions []cassetteInteraction `json:"interactions"`
+}
+
+type cassetteInteraction struct {
+	Request  cassetteRequest  `json:"request"`
+	Response cassetteResponse `json:"response"`
+}
+
+type cassetteRequest struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+
+	// Encoded with its parameters sorted by key, so that the same query always matches no matter its order.
+	Query string `json:"query,omitempty"`
+}
+
+type cassetteResponse struct {
+	StatusCode int         `json:"statusCode"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body"`
+}
+
+// Returned in replay mode when a request was never recorded, which most likely means the cassette needs to be
+// re-recorded.
+type cassetteMissError struct {
+	Request  cassetteRequest
+	Cassette string
+}
+
+func (err *cassetteMissError) Error() string {
+	return fmt.Sprintf("No recorded interaction in cassette '%s' matches %s, so it needs to be re-recorded in '%s' mode to include it",
+		err.Cassette, err.Request.String(), cassetteRecord)
+}
+
+type cassetteHTTPClient struct {
+	Client httpClient
+	Mode   cassetteMode
+
+	// Path to the cassette file.
+	Path string
+
+	mutex        sync.Mutex
+	interactions []cassetteInteraction
+}
+
+// Create a client in the given mode. Replay mode loads the cassette right away so that a missing or malformed
+// cassette is reported up front rather than on the first request. Record mode always starts a fresh cassette.
+func newCassetteHTTPClient(client httpClient, mode cassetteMode, path string) (*cassetteHTTPClient, error) {
+	if !isCassetteMode(mode) {
+		return nil, fmt.Errorf("Unsupported cassette mode '%s'", mode)
+	}
+
+	cassetteHTTPClient := &cassetteHTTPClient{
+		Client: client,
+		Mode:   mode,
+		Path:   path,
+	}
+	if mode == cassetteReplay {
+		contents, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read cassette '%s': error=%w", path, err)
+		}
+		var cassetteFile cassette
+		if err := json.Unmarshal(contents, &cassetteFile); err != nil {
+			return nil, fmt.Errorf("Unable to parse cassette '%s': error=%w", path, err)
+		}
+		cassetteHTTPClient.interactions = cassetteFile.Interactions
+	}
+	return cassetteHTTPClient, nil
+}
+
+func (cassetteHTTPClient *cassetteHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	switch cassetteHTTPClient.Mode {
+	case cassetteReplay:
+		return cassetteHTTPClient.replay(req)
+	case cassetteRecord:
+		return cassetteHTTPClient.record(req)
+	default:
+		return cassetteHTTPClient.Client.Do(req)
+	}
+}
+
+func (cassetteHTTPClient *cassetteHTTPClient) replay(req *http.Request) (*http.Response, error) {
+	key := newCassetteRequest(req)
+
+	cassetteHTTPClient.mutex.Lock()
+	defer cassetteHTTPClient.mutex.Unlock()
+	for _, interaction := range cassetteHTTPClient.interactions {
+		if interaction.Request == key {
+			return interaction.Response.toResponse(req), nil
+		}
+	}
+	return nil, &cassetteMissError{Request: key, Cassette: cassetteHTTPClient.Path}
+}
+
+func (cassetteHTTPClient *cassetteHTTPClient) record(req *http.Request) (*http.Response, error) {
+	resp, err := cassetteHTTPClient.Client.Do(req)
+	if err != nil {
+		// There's no response to record, and communication errors aren't worth replaying anyways.
+		return resp, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	interaction := cassetteInteraction{
+		Request: newCassetteRequest(req),
+		Response: cassetteResponse{
+			StatusCode: resp.StatusCode,
+			Header:     resp.Header,
+			Body:       string(body),
+		},
+	}
+
+	cassetteHTTPClient.mutex.Lock()
+	defer cassetteHTTPClient.mutex.Unlock()
+
+	// Only keep the latest response for any given request so that the cassette stays a simple lookup table.
+	replaced := false
+	for i := range cassetteHTTPClient.interactions {
+		if cassetteHTTPClient.interactions[i].Request == interaction.Request {
+			cassetteHTTPClient.interactions[i] = interaction
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		cassetteHTTPClient.interactions = append(cassetteHTTPClient.interactions, interaction)
+	}
+
+	// Save after every interaction so that a recording session can be stopped at any point without losing anything.
+	if err := cassetteHTTPClient.save(); err != nil {
+		return nil, err
+	}
+	return interaction.Response.toResponse(req), nil
+}
+
+// Write the cassette to a temporary file first and then swap it in, so that the cassette is never left half-written.
+func (cassetteHTTPClient *cassetteHTTPClient) save() error {
+	contents, err := json.MarshalIndent(cassette{Interactions: cassetteHTTPClient.interactions}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Unable to serialize cassette '%s': error=%w", cassetteHTTPClient.Path, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(cassetteHTTPClient.Path), 0755); err != nil {
+		return fmt.Errorf("Unable to create directory for cassette '%s': error=%w", cassetteHTTPClient.Path, err)
+	}
+	tempPath := cassetteHTTPClient.Path + ".tmp"
+	if err := ioutil.WriteFile(tempPath, append(contents, '\n'), 0644); err != nil {
+		return fmt.Errorf("Unable to write cassette '%s': error=%w", cassetteHTTPClient.Path, err)
+	}
+	if err := os.Rename(tempPath, cassetteHTTPClient.Path); err != nil {
+		return fmt.Errorf("Unable to write cassette '%s': error=%w", cassetteHTTPClient.Path, err)
+	}
+	return nil
+}
+
+func newCassetteRequest(req *http.Request) cassetteRequest {
+	return cassetteRequest{
+		Method: req.Method,
+		Path:   req.URL.Path,
+		Query:  req.URL.Query().Encode(),
+	}
+}
+
+func (request cassetteRequest) String() string {
+	if request.Query == "" {
+		return request.Method + " " + request.Path
+	}
+	return request.Method + " " + request.Path + "?" + request.Query
+}
+
+func (response cassetteResponse) toResponse(req *http.Request) *http.Response {
+	header := http.Header{}
+	for key, values := range response.Header {
+		header[key] = append([]string(nil), values...)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", response.StatusCode, http.StatusText(response.StatusCode)),
+		StatusCode:    response.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(strings.NewReader(response.Body)),
+		ContentLength: int64(len(response.Body)),
+		Request:       req,
+	}
+}
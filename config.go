@@ -0,0 +1,458 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Configuration
+//
+// Every setting can come from up to four places. In order of precedence, from lowest to highest:
+//   1. The defaults in defaultAppConfig.
+//   2. A YAML or JSON config file, given by "-config" or BTT_CONFIG_FILE.
+//   3. Environment variables, e.g. BTT_UPSTREAM_BASE_URL.
+//   4. Command-line flags, e.g. -upstream-base-url.
+//
+// Each setting's flag and environment variable names are derived from the same name, e.g. the "port" setting is
+// "-port" as a flag and BTT_PORT as an environment variable, so the two can never drift apart.
+
+// Prefix for every environment variable read by loadConfig.
+const configEnvPrefix = "BTT_"
+
+type appConfig struct {
+	Server           serverConfig           `yaml:"server"`
+	Upstream         upstreamConfig         `yaml:"upstream"`
+	Cache            cacheConfig            `yaml:"cache"`
+	Retry            retryConfig            `yaml:"retry"`
+	CircuitBreaker   breakerConfig          `yaml:"circuitBreaker"`
+	FakeVendor       fakeVendorConfig       `yaml:"fakeVendor"`
+	Cassette         cassetteConfig         `yaml:"cassette"`
+	Search           searchConfig           `yaml:"search"`
+	Batch            batchConfig            `yaml:"batch"`
+	PartialResponses partialResponsesConfig `yaml:"partialResponses"`
+	Albums           albumsConfig           `yaml:"albums"`
+	Source           sourceConfig           `yaml:"source"`
+	Mirror           mirrorConfig           `yaml:"mirror"`
+}
+
+// Settings for how we talk to Cool Vendor.
+type upstreamConfig struct {
+	BaseUrl string `yaml:"baseUrl"`
+
+	// Overall limit for a single request to Cool Vendor, including reading the response body. This is separate
+	// from requestTimeout, which covers everything a single API request needs from Cool Vendor, including retries.
+	Timeout               time.Duration `yaml:"timeout"`
+	DialTimeout           time.Duration `yaml:"dialTimeout"`
+	TLSHandshakeTimeout   time.Duration `yaml:"tlsHandshakeTimeout"`
+	ResponseHeaderTimeout time.Duration `yaml:"responseHeaderTimeout"`
+
+	// Connection pool sizing. Zero means no limit for MaxIdleConns and MaxConnsPerHost.
+	MaxIdleConns        int           `yaml:"maxIdleConns"`
+	MaxIdleConnsPerHost int           `yaml:"maxIdleConnsPerHost"`
+	MaxConnsPerHost     int           `yaml:"maxConnsPerHost"`
+	IdleConnTimeout     time.Duration `yaml:"idleConnTimeout"`
+}
+
+type cacheConfig struct {
+	Enabled     bool          `yaml:"enabled"`
+	TTL         time.Duration `yaml:"ttl"`
+	NotFoundTTL time.Duration `yaml:"notFoundTtl"`
+	MaxEntries  int           `yaml:"maxEntries"`
+
+	// How long after expiring a response can still be served while it's refreshed in the background, and when
+	// refreshing it fails. See cacheOptions.
+	StaleWhileRevalidate time.Duration `yaml:"staleWhileRevalidate"`
+	StaleIfError         time.Duration `yaml:"staleIfError"`
+}
+
+type retryConfig struct {
+	// Total number of attempts, including the first one. Set to 1 to disable retries.
+	MaxAttempts int           `yaml:"maxAttempts"`
+	BaseDelay   time.Duration `yaml:"baseDelay"`
+	MaxDelay    time.Duration `yaml:"maxDelay"`
+}
+
+type breakerConfig struct {
+	Enabled              bool          `yaml:"enabled"`
+	FailureRateThreshold float64       `yaml:"failureRateThreshold"`
+	MinRequests          int           `yaml:"minRequests"`
+	WindowSize           int           `yaml:"windowSize"`
+	CoolDown             time.Duration `yaml:"coolDown"`
+}
+
+// Settings for the built-in fake vendor, see fakevendor.go.
+type fakeVendorConfig struct {
+	// Serve the bundled seed dataset locally and point typicodeClient at it instead of upstream.baseUrl.
+	Enabled bool             `yaml:"enabled"`
+	Faults  fakeVendorFaults `yaml:"faults"`
+}
+
+// Settings for recording and replaying Cool Vendor's responses, see cassette.go.
+type cassetteConfig struct {
+	Mode cassetteMode `yaml:"mode"`
+	File string       `yaml:"file"`
+}
+
+// Settings for full-text search across every post, see search.go.
+type searchConfig struct {
+	Enabled            bool          `yaml:"enabled"`
+	RefreshInterval    time.Duration `yaml:"refreshInterval"`
+	MinRefreshInterval time.Duration `yaml:"minRefreshInterval"`
+}
+
+// Settings for fetching many users' posts in one request, see batch.go.
+type batchConfig struct {
+	// Most user IDs that a single batch can ask for.
+	MaxSize int `yaml:"maxSize"`
+
+	// Most users fetched at once for a single batch.
+	Concurrency int `yaml:"concurrency"`
+
+	// Batches with at least this many users fetch them with Cool Vendor's bulk filters. Zero disables bulk fetches.
+	BulkThreshold int `yaml:"bulkThreshold"`
+}
+
+// Settings for fetching a user's albums and their photos, see albums.go.
+type albumsConfig struct {
+	// Most photos returned per album.
+	MaxPhotosPerAlbum int `yaml:"maxPhotosPerAlbum"`
+
+	// Most albums that have their photos fetched at once for a single request.
+	Concurrency int `yaml:"concurrency"`
+}
+
+// Settings for where users and their posts come from, see source.go.
+type sourceConfig struct {
+	// Either "typicode" for Cool Vendor's API, "jsonFile" for a local JSON file, or "mirror" for a local mirror of
+	// Cool Vendor.
+	Backend sourceBackend `yaml:"backend"`
+
+	// Path to the JSON file for the "jsonFile" backend.
+	File string `yaml:"file"`
+}
+
+// Settings for the local mirror of Cool Vendor's users and posts, see mirror.go. Only used by the "mirror" source.
+type mirrorConfig struct {
+	// Path to the mirror's SQLite database. Created at startup if it doesn't exist yet.
+	File string `yaml:"file"`
+
+	// How often the mirror is synced with Cool Vendor.
+	SyncInterval time.Duration `yaml:"syncInterval"`
+}
+
+// Settings for leaving failed sub-resources out of a response rather than failing it, see partial.go.
+type partialResponsesConfig struct {
+	Enabled bool `yaml:"enabled"`
+
+	// Status code for a response with a sub-resource left out, either 200 or 206.
+	Status int `yaml:"status"`
+}
+
+var defaultAppConfig = appConfig{
+	Server: defaultServerConfig,
+	Upstream: upstreamConfig{
+		BaseUrl:               "https://jsonplaceholder.typicode.com",
+		Timeout:               5 * time.Second,
+		DialTimeout:           2 * time.Second,
+		TLSHandshakeTimeout:   2 * time.Second,
+		ResponseHeaderTimeout: 4 * time.Second,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   20,
+		MaxConnsPerHost:       0,
+		IdleConnTimeout:       90 * time.Second,
+	},
+	Cache: cacheConfig{
+		Enabled:     true,
+		TTL:         30 * time.Second,
+		NotFoundTTL: 10 * time.Second,
+		MaxEntries:  1000,
+
+		StaleWhileRevalidate: 30 * time.Second,
+		StaleIfError:         10 * time.Minute,
+	},
+	Retry: retryConfig{
+		MaxAttempts: 3,
+		BaseDelay:   100 * time.Millisecond,
+		MaxDelay:    2 * time.Second,
+	},
+	CircuitBreaker: breakerConfig{
+		Enabled:              true,
+		FailureRateThreshold: 0.5,
+		MinRequests:          10,
+		WindowSize:           20,
+		CoolDown:             30 * time.Second,
+	},
+	Cassette: cassetteConfig{
+		Mode: cassettePassthrough,
+	},
+	Search: searchConfig{
+		Enabled:            true,
+		RefreshInterval:    5 * time.Minute,
+		MinRefreshInterval: 10 * time.Second,
+	},
+	Batch: batchConfig{
+		MaxSize:       100,
+		Concurrency:   10,
+		BulkThreshold: 5,
+	},
+	PartialResponses: partialResponsesConfig{
+		Enabled: true,
+		Status:  http.StatusOK,
+	},
+	Albums: albumsConfig{
+		MaxPhotosPerAlbum: 20,
+		Concurrency:       5,
+	},
+	Source: sourceConfig{
+		Backend: sourceTypicode,
+	},
+	Mirror: mirrorConfig{
+		SyncInterval: 5 * time.Minute,
+	},
+}
+
+// Load the configuration from every source in order of precedence, then validate the result.
+//
+// Environment variables are looked up through getenv, which is just os.Getenv outside of unit tests.
+func loadConfig(args []string, getenv func(string) string) (appConfig, error) {
+	// The config file location can itself come from a flag or environment variable, so we need to find it before
+	// we can apply everything else in the right order.
+	configFile := getenv(configEnvPrefix + "CONFIG_FILE")
+	scratch := defaultAppConfig
+	scratchFlags := newConfigFlagSet(&scratch, &configFile)
+	if err := scratchFlags.Parse(args); err != nil {
+		return appConfig{}, err
+	}
+
+	config := defaultAppConfig
+	if configFile != "" {
+		if err := loadConfigFile(configFile, &config); err != nil {
+			return appConfig{}, err
+		}
+	}
+
+	flags := newConfigFlagSet(&config, &configFile)
+	var envErrs []string
+	flags.VisitAll(func(f *flag.Flag) {
+		envName := configEnvName(f.Name)
+		if value := getenv(envName); value != "" && f.Name != "config" {
+			if err := flags.Set(f.Name, value); err != nil {
+				envErrs = append(envErrs, fmt.Sprint(envName, "='", value, "' is invalid: ", err.Error()))
+			}
+		}
+	})
+	if len(envErrs) > 0 {
+		return appConfig{}, errors.New("Invalid environment variables:\n  - " + strings.Join(envErrs, "\n  - "))
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return appConfig{}, err
+	}
+
+	if err := config.validate(); err != nil {
+		return appConfig{}, err
+	}
+	return config, nil
+}
+
+// Read a YAML or JSON config file into config. Anything not set in the file keeps its current value.
+//
+// JSON is a subset of YAML, so both go through the same strict YAML decoder, which also rejects any unknown keys
+// so that a typo doesn't silently fall back to a default.
+func loadConfigFile(path string, config *appConfig) error {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("Unable to read config file '%s': error=%w", path, err)
+	}
+	if err := yaml.UnmarshalStrict(contents, config); err != nil {
+		return fmt.Errorf("Unable to parse config file '%s' as YAML or JSON: error=%w", path, err)
+	}
+	return nil
+}
+
+// Bind every setting to a flag. The same flag set is also used to parse environment variables so that both
+// sources always accept exactly the same formats, e.g. "5s" for durations.
+func newConfigFlagSet(config *appConfig, configFile *string) *flag.FlagSet {
+	flags := flag.NewFlagSet("back-to-the-2000s", flag.ContinueOnError)
+	flags.StringVar(configFile, "config", *configFile, "Path to a YAML or JSON config file")
+
+	flags.StringVar(&config.Server.Host, "host", config.Server.Host, "Interface to listen on")
+	flags.IntVar(&config.Server.Port, "port", config.Server.Port, "Port to listen on")
+	flags.DurationVar(&config.Server.ReadTimeout, "read-timeout", config.Server.ReadTimeout, "Maximum duration for reading an entire request")
+	flags.DurationVar(&config.Server.WriteTimeout, "write-timeout", config.Server.WriteTimeout, "Maximum duration before timing out writes of a response")
+	flags.DurationVar(&config.Server.IdleTimeout, "idle-timeout", config.Server.IdleTimeout, "Maximum duration to keep an idle keep-alive connection open")
+	flags.DurationVar(&config.Server.ShutdownGracePeriod, "shutdown-grace-period", config.Server.ShutdownGracePeriod, "Maximum duration to wait for in-flight requests on shutdown")
+	flags.DurationVar(&config.Server.RequestTimeout, "request-timeout", config.Server.RequestTimeout, "Maximum duration any single API request can spend waiting on Cool Vendor")
+
+	flags.StringVar(&config.Upstream.BaseUrl, "upstream-base-url", config.Upstream.BaseUrl, "Base URL for Cool Vendor's API")
+	flags.DurationVar(&config.Upstream.Timeout, "upstream-timeout", config.Upstream.Timeout, "Maximum duration for a single request to Cool Vendor")
+	flags.DurationVar(&config.Upstream.DialTimeout, "upstream-dial-timeout", config.Upstream.DialTimeout, "Maximum duration to establish a connection to Cool Vendor")
+	flags.DurationVar(&config.Upstream.TLSHandshakeTimeout, "upstream-tls-handshake-timeout", config.Upstream.TLSHandshakeTimeout, "Maximum duration for the TLS handshake with Cool Vendor")
+	flags.DurationVar(&config.Upstream.ResponseHeaderTimeout, "upstream-response-header-timeout", config.Upstream.ResponseHeaderTimeout, "Maximum duration to wait for Cool Vendor's response headers")
+	flags.IntVar(&config.Upstream.MaxIdleConns, "upstream-max-idle-conns", config.Upstream.MaxIdleConns, "Maximum idle connections to Cool Vendor, zero means no limit")
+	flags.IntVar(&config.Upstream.MaxIdleConnsPerHost, "upstream-max-idle-conns-per-host", config.Upstream.MaxIdleConnsPerHost, "Maximum idle connections per Cool Vendor host")
+	flags.IntVar(&config.Upstream.MaxConnsPerHost, "upstream-max-conns-per-host", config.Upstream.MaxConnsPerHost, "Maximum connections per Cool Vendor host, zero means no limit")
+	flags.DurationVar(&config.Upstream.IdleConnTimeout, "upstream-idle-conn-timeout", config.Upstream.IdleConnTimeout, "Maximum duration an idle connection to Cool Vendor is kept open")
+
+	flags.BoolVar(&config.Cache.Enabled, "cache-enabled", config.Cache.Enabled, "Cache responses from Cool Vendor in memory")
+	flags.DurationVar(&config.Cache.TTL, "cache-ttl", config.Cache.TTL, "How long a successful response is cached")
+	flags.DurationVar(&config.Cache.NotFoundTTL, "cache-not-found-ttl", config.Cache.NotFoundTTL, "How long a 404 response is cached, zero disables caching 404s")
+	flags.IntVar(&config.Cache.MaxEntries, "cache-max-entries", config.Cache.MaxEntries, "Maximum number of cached responses")
+	flags.DurationVar(&config.Cache.StaleWhileRevalidate, "cache-stale-while-revalidate", config.Cache.StaleWhileRevalidate, "How long after expiring a cached response is still served while it's refreshed in the background, zero disables it")
+	flags.DurationVar(&config.Cache.StaleIfError, "cache-stale-if-error", config.Cache.StaleIfError, "How long after expiring a cached response is still served when Cool Vendor fails, zero disables it")
+
+	flags.IntVar(&config.Retry.MaxAttempts, "retry-max-attempts", config.Retry.MaxAttempts, "Total attempts for a request to Cool Vendor, 1 disables retries")
+	flags.DurationVar(&config.Retry.BaseDelay, "retry-base-delay", config.Retry.BaseDelay, "Backoff before the first retry")
+	flags.DurationVar(&config.Retry.MaxDelay, "retry-max-delay", config.Retry.MaxDelay, "Upper bound for any single backoff or Retry-After")
+
+	flags.BoolVar(&config.CircuitBreaker.Enabled, "circuit-breaker-enabled", config.CircuitBreaker.Enabled, "Fail fast while Cool Vendor is unhealthy")
+	flags.Float64Var(&config.CircuitBreaker.FailureRateThreshold, "circuit-breaker-failure-rate-threshold", config.CircuitBreaker.FailureRateThreshold, "Failure rate, between 0 and 1, that opens a breaker")
+	flags.IntVar(&config.CircuitBreaker.MinRequests, "circuit-breaker-min-requests", config.CircuitBreaker.MinRequests, "Minimum requests in the window before a breaker can open")
+	flags.IntVar(&config.CircuitBreaker.WindowSize, "circuit-breaker-window-size", config.CircuitBreaker.WindowSize, "Number of most recent requests the failure rate is calculated over")
+	flags.DurationVar(&config.CircuitBreaker.CoolDown, "circuit-breaker-cool-down", config.CircuitBreaker.CoolDown, "How long a breaker stays open before letting a probe through")
+
+	flags.BoolVar(&config.FakeVendor.Enabled, "fake-vendor", config.FakeVendor.Enabled, "Serve the bundled seed data from a local fake vendor instead of calling upstream-base-url")
+	flags.Var(&config.FakeVendor.Faults, "fake-vendor-faults", "Faults to inject into the fake vendor per route, e.g. 'users=latency:200ms,errorRate:0.1;posts=status:503'")
+	flags.StringVar((*string)(&config.Cassette.Mode), "cassette-mode", string(config.Cassette.Mode), "Record Cool Vendor's responses to, or replay them from, cassette-file: passthrough, record, or replay")
+	flags.StringVar(&config.Cassette.File, "cassette-file", config.Cassette.File, "Path to the cassette file for the record and replay cassette modes")
+
+	flags.BoolVar(&config.Search.Enabled, "search-enabled", config.Search.Enabled, "Index every post from Cool Vendor for full-text search")
+	flags.DurationVar(&config.Search.RefreshInterval, "search-refresh-interval", config.Search.RefreshInterval, "How often the search index is rebuilt")
+	flags.DurationVar(&config.Search.MinRefreshInterval, "search-min-refresh-interval", config.Search.MinRefreshInterval, "Minimum time between rebuilds of the search index triggered by new data from Cool Vendor")
+
+	flags.IntVar(&config.Batch.MaxSize, "batch-max-size", config.Batch.MaxSize, "Most user IDs that a single batch request can ask for")
+	flags.IntVar(&config.Batch.Concurrency, "batch-concurrency", config.Batch.Concurrency, "Most users fetched at once for a single batch request")
+	flags.IntVar(&config.Batch.BulkThreshold, "batch-bulk-threshold", config.Batch.BulkThreshold, "Batch requests with at least this many users fetch them with Cool Vendor's bulk filters, zero disables bulk fetches")
+
+	flags.BoolVar(&config.PartialResponses.Enabled, "partial-responses-enabled", config.PartialResponses.Enabled, "Leave failed posts, comments, or photos out of a response rather than failing it, unless the consumer asks for strict handling")
+	flags.IntVar(&config.PartialResponses.Status, "partial-responses-status", config.PartialResponses.Status, "Status code for a response with posts, comments, or photos left out: 200 or 206")
+
+	flags.IntVar(&config.Albums.MaxPhotosPerAlbum, "albums-max-photos-per-album", config.Albums.MaxPhotosPerAlbum, "Most photos returned per album with include=photos")
+	flags.IntVar(&config.Albums.Concurrency, "albums-concurrency", config.Albums.Concurrency, "Most albums that have their photos fetched at once for a single request")
+
+	flags.StringVar((*string)(&config.Source.Backend), "source", string(config.Source.Backend), "Where users and their posts come from: typicode, jsonFile for source-file, or mirror for mirror-file")
+	flags.StringVar(&config.Source.File, "source-file", config.Source.File, "Path to the JSON file for the jsonFile source, in the same shape as the bundled seed data")
+
+	flags.StringVar(&config.Mirror.File, "mirror-file", config.Mirror.File, "Path to the local mirror of Cool Vendor's users and posts for the mirror source")
+	flags.DurationVar(&config.Mirror.SyncInterval, "mirror-sync-interval", config.Mirror.SyncInterval, "How often the mirror is synced with Cool Vendor")
+	return flags
+}
+
+// e.g. "upstream-base-url" becomes "BTT_UPSTREAM_BASE_URL".
+func configEnvName(flagName string) string {
+	return configEnvPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// Check every setting and report all problems at once so that they can be fixed in one go.
+func (config appConfig) validate() error {
+	var problems []string
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+
+	check(config.Server.Port >= 0 && config.Server.Port <= 65535, "server.port must be between 0 and 65535, but got %d", config.Server.Port)
+	check(config.Server.ReadTimeout >= 0, "server.readTimeout must not be negative, but got %s", config.Server.ReadTimeout)
+	check(config.Server.WriteTimeout >= 0, "server.writeTimeout must not be negative, but got %s", config.Server.WriteTimeout)
+	check(config.Server.IdleTimeout >= 0, "server.idleTimeout must not be negative, but got %s", config.Server.IdleTimeout)
+	check(config.Server.ShutdownGracePeriod > 0, "server.shutdownGracePeriod must be positive, but got %s", config.Server.ShutdownGracePeriod)
+	check(config.Server.RequestTimeout >= 0, "server.requestTimeout must not be negative, but got %s", config.Server.RequestTimeout)
+
+	baseUrl, err := url.Parse(config.Upstream.BaseUrl)
+	check(err == nil && (baseUrl.Scheme == "http" || baseUrl.Scheme == "https") && baseUrl.Host != "", "upstream.baseUrl must be an absolute http(s) URL, but got '%s'", config.Upstream.BaseUrl)
+	check(!strings.HasSuffix(config.Upstream.BaseUrl, "/"), "upstream.baseUrl must not end with a '/', but got '%s'", config.Upstream.BaseUrl)
+	check(config.Upstream.Timeout >= 0, "upstream.timeout must not be negative, but got %s", config.Upstream.Timeout)
+	check(config.Upstream.DialTimeout >= 0, "upstream.dialTimeout must not be negative, but got %s", config.Upstream.DialTimeout)
+	check(config.Upstream.TLSHandshakeTimeout >= 0, "upstream.tlsHandshakeTimeout must not be negative, but got %s", config.Upstream.TLSHandshakeTimeout)
+	check(config.Upstream.ResponseHeaderTimeout >= 0, "upstream.responseHeaderTimeout must not be negative, but got %s", config.Upstream.ResponseHeaderTimeout)
+	check(config.Upstream.MaxIdleConns >= 0, "upstream.maxIdleConns must not be negative, but got %d", config.Upstream.MaxIdleConns)
+	check(config.Upstream.MaxIdleConnsPerHost >= 0, "upstream.maxIdleConnsPerHost must not be negative, but got %d", config.Upstream.MaxIdleConnsPerHost)
+	check(config.Upstream.MaxConnsPerHost >= 0, "upstream.maxConnsPerHost must not be negative, but got %d", config.Upstream.MaxConnsPerHost)
+	check(config.Upstream.IdleConnTimeout >= 0, "upstream.idleConnTimeout must not be negative, but got %s", config.Upstream.IdleConnTimeout)
+
+	if config.Cache.Enabled {
+		check(config.Cache.TTL > 0, "cache.ttl must be positive when the cache is enabled, but got %s", config.Cache.TTL)
+		check(config.Cache.NotFoundTTL >= 0, "cache.notFoundTtl must not be negative, but got %s", config.Cache.NotFoundTTL)
+		check(config.Cache.MaxEntries > 0, "cache.maxEntries must be positive when the cache is enabled, but got %d", config.Cache.MaxEntries)
+		check(config.Cache.StaleWhileRevalidate >= 0, "cache.staleWhileRevalidate must not be negative, but got %s", config.Cache.StaleWhileRevalidate)
+		check(config.Cache.StaleIfError >= 0, "cache.staleIfError must not be negative, but got %s", config.Cache.StaleIfError)
+	}
+
+	check(config.Retry.MaxAttempts >= 1, "retry.maxAttempts must be at least 1, but got %d", config.Retry.MaxAttempts)
+	check(config.Retry.BaseDelay >= 0, "retry.baseDelay must not be negative, but got %s", config.Retry.BaseDelay)
+	check(config.Retry.MaxDelay >= config.Retry.BaseDelay, "retry.maxDelay must be at least retry.baseDelay (%s), but got %s", config.Retry.BaseDelay, config.Retry.MaxDelay)
+
+	if config.CircuitBreaker.Enabled {
+		check(config.CircuitBreaker.FailureRateThreshold > 0 && config.CircuitBreaker.FailureRateThreshold <= 1, "circuitBreaker.failureRateThreshold must be greater than 0 and at most 1, but got %v", config.CircuitBreaker.FailureRateThreshold)
+		check(config.CircuitBreaker.WindowSize > 0, "circuitBreaker.windowSize must be positive, but got %d", config.CircuitBreaker.WindowSize)
+		check(config.CircuitBreaker.MinRequests > 0 && config.CircuitBreaker.MinRequests <= config.CircuitBreaker.WindowSize, "circuitBreaker.minRequests must be between 1 and circuitBreaker.windowSize (%d), but got %d", config.CircuitBreaker.WindowSize, config.CircuitBreaker.MinRequests)
+		check(config.CircuitBreaker.CoolDown > 0, "circuitBreaker.coolDown must be positive, but got %s", config.CircuitBreaker.CoolDown)
+	}
+
+	problems = append(problems, config.FakeVendor.Faults.problems()...)
+
+	check(isCassetteMode(config.Cassette.Mode), "cassette.mode must be one of passthrough, record, or replay, but got '%s'", config.Cassette.Mode)
+	if config.Cassette.Mode == cassetteRecord || config.Cassette.Mode == cassetteReplay {
+		check(config.Cassette.File != "", "cassette.file must be set when cassette.mode is '%s'", config.Cassette.Mode)
+	}
+
+	if config.Search.Enabled {
+		check(config.Search.RefreshInterval > 0, "search.refreshInterval must be positive when search is enabled, but got %s", config.Search.RefreshInterval)
+		check(config.Search.MinRefreshInterval >= 0, "search.minRefreshInterval must not be negative, but got %s", config.Search.MinRefreshInterval)
+	}
+
+	check(config.Batch.MaxSize > 0, "batch.maxSize must be positive, but got %d", config.Batch.MaxSize)
+	check(config.Batch.Concurrency > 0, "batch.concurrency must be positive, but got %d", config.Batch.Concurrency)
+	check(config.Batch.BulkThreshold >= 0, "batch.bulkThreshold must not be negative, but got %d", config.Batch.BulkThreshold)
+
+	if config.PartialResponses.Enabled {
+		check(config.PartialResponses.Status == http.StatusOK || config.PartialResponses.Status == http.StatusPartialContent, "partialResponses.status must be 200 or 206, but got %d", config.PartialResponses.Status)
+	}
+
+	check(config.Albums.MaxPhotosPerAlbum > 0, "albums.maxPhotosPerAlbum must be positive, but got %d", config.Albums.MaxPhotosPerAlbum)
+	check(config.Albums.Concurrency > 0, "albums.concurrency must be positive, but got %d", config.Albums.Concurrency)
+
+	check(isSourceBackend(config.Source.Backend), "source.backend must be one of typicode, jsonFile, or mirror, but got '%s'", config.Source.Backend)
+	if config.Source.Backend == sourceJSONFile {
+		check(config.Source.File != "", "source.file must be set when source.backend is '%s'", config.Source.Backend)
+	}
+	if config.Source.Backend == sourceMirror {
+		check(config.Mirror.File != "", "mirror.file must be set when source.backend is '%s'", config.Source.Backend)
+		check(config.Mirror.SyncInterval > 0, "mirror.syncInterval must be positive when source.backend is '%s', but got %s", config.Source.Backend, config.Mirror.SyncInterval)
+	}
+
+	if len(problems) > 0 {
+		return errors.New("Invalid configuration:\n  - " + strings.Join(problems, "\n  - "))
+	}
+	return nil
+}
+
+// Build the http.Client used to talk to Cool Vendor from the upstream settings.
+func newUpstreamHTTPClient(config upstreamConfig) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{
+		Timeout:   config.DialTimeout,
+		KeepAlive: 30 * time.Second,
+	}).DialContext
+	transport.TLSHandshakeTimeout = config.TLSHandshakeTimeout
+	transport.ResponseHeaderTimeout = config.ResponseHeaderTimeout
+	transport.MaxIdleConns = config.MaxIdleConns
+	transport.MaxIdleConnsPerHost = config.MaxIdleConnsPerHost
+	transport.MaxConnsPerHost = config.MaxConnsPerHost
+	transport.IdleConnTimeout = config.IdleConnTimeout
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   config.Timeout,
+	}
+}
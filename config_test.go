@@ -0,0 +1,259 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// loadConfig
+
+func TestLoadConfigDefaults(t *testing.T) {
+	config, err := loadConfig([]string{}, emptyEnv)
+	assert.Nil(t, err)
+	assert.Equal(t, defaultAppConfig, config)
+	assert.Equal(t, "localhost:8080", config.Server.address())
+}
+
+func TestLoadConfigYAMLFile(t *testing.T) {
+	path := writeTestConfigFile(t, "config.yaml", `
+server:
+  host: 0.0.0.0
+  port: 9090
+  readTimeout: 1s
+upstream:
+  baseUrl: http://localhost:3000
+  maxIdleConnsPerHost: 5
+cache:
+  enabled: false
+`)
+
+	config, err := loadConfig([]string{"-config", path}, emptyEnv)
+	assert.Nil(t, err)
+	assert.Equal(t, "0.0.0.0:9090", config.Server.address())
+	assert.Equal(t, time.Second, config.Server.ReadTimeout)
+	assert.Equal(t, "http://localhost:3000", config.Upstream.BaseUrl)
+	assert.Equal(t, 5, config.Upstream.MaxIdleConnsPerHost)
+	assert.False(t, config.Cache.Enabled)
+	// Anything not in the file keeps its default.
+	assert.Equal(t, defaultAppConfig.Server.WriteTimeout, config.Server.WriteTimeout)
+	assert.Equal(t, defaultAppConfig.Cache.TTL, config.Cache.TTL)
+}
+
+func TestLoadConfigJSONFile(t *testing.T) {
+	path := writeTestConfigFile(t, "config.json", `{"upstream": {"baseUrl": "http://localhost:3000", "timeout": "750ms"}, "retry": {"maxAttempts": 1}}`)
+
+	config, err := loadConfig([]string{}, fakeEnv(map[string]string{"BTT_CONFIG_FILE": path}))
+	assert.Nil(t, err)
+	assert.Equal(t, "http://localhost:3000", config.Upstream.BaseUrl)
+	assert.Equal(t, 750*time.Millisecond, config.Upstream.Timeout)
+	assert.Equal(t, 1, config.Retry.MaxAttempts)
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	path := writeTestConfigFile(t, "config.yaml", `
+server:
+  port: 9090
+upstream:
+  baseUrl: http://from-file:3000
+cache:
+  ttl: 1m
+`)
+	env := fakeEnv(map[string]string{
+		"BTT_CONFIG_FILE":       path,
+		"BTT_PORT":              "9191",
+		"BTT_UPSTREAM_BASE_URL": "http://from-env:3000",
+	})
+
+	config, err := loadConfig([]string{"-port=9292"}, env)
+	assert.Nil(t, err)
+	// Flags beat environment variables, which beat the config file, which beats the defaults.
+	assert.Equal(t, 9292, config.Server.Port)
+	assert.Equal(t, "http://from-env:3000", config.Upstream.BaseUrl)
+	assert.Equal(t, time.Minute, config.Cache.TTL)
+	assert.Equal(t, defaultAppConfig.Cache.MaxEntries, config.Cache.MaxEntries)
+}
+
+func TestLoadConfigInvalidEnv(t *testing.T) {
+	_, err := loadConfig([]string{}, fakeEnv(map[string]string{"BTT_CACHE_TTL": "forever"}))
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "BTT_CACHE_TTL='forever' is invalid")
+}
+
+func TestLoadConfigUnknownFileKey(t *testing.T) {
+	path := writeTestConfigFile(t, "config.yaml", `
+upstream:
+  baseURL: http://localhost:3000
+`)
+
+	_, err := loadConfig([]string{"-config", path}, emptyEnv)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "Unable to parse config file")
+	assert.Contains(t, err.Error(), "baseURL")
+}
+
+func TestLoadConfigMissingFile(t *testing.T) {
+	_, err := loadConfig([]string{"-config", filepath.Join(t.TempDir(), "nope.yaml")}, emptyEnv)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "Unable to read config file")
+}
+
+func TestLoadConfigValidation(t *testing.T) {
+	_, err := loadConfig([]string{
+		"-port=70000",
+		"-upstream-base-url=jsonplaceholder.typicode.com",
+		"-retry-max-attempts=0",
+		"-circuit-breaker-failure-rate-threshold=2",
+	}, emptyEnv)
+	assert.NotNil(t, err)
+	// Every problem should be reported at once.
+	assert.Equal(t, "Invalid configuration:\n"+
+		"  - server.port must be between 0 and 65535, but got 70000\n"+
+		"  - upstream.baseUrl must be an absolute http(s) URL, but got 'jsonplaceholder.typicode.com'\n"+
+		"  - retry.maxAttempts must be at least 1, but got 0\n"+
+		"  - circuitBreaker.failureRateThreshold must be greater than 0 and at most 1, but got 2", err.Error())
+}
+
+func TestLoadConfigDisabledFeaturesSkipValidation(t *testing.T) {
+	_, err := loadConfig([]string{"-cache-enabled=false", "-cache-ttl=0s", "-circuit-breaker-enabled=false", "-circuit-breaker-window-size=0"}, emptyEnv)
+	assert.Nil(t, err)
+}
+
+func TestLoadConfigFakeVendorFaults(t *testing.T) {
+	path := writeTestConfigFile(t, "config.yaml", `
+fakeVendor:
+  enabled: true
+  faults:
+    users:
+      latency: 200ms
+      errorRate: 0.1
+`)
+
+	config, err := loadConfig([]string{"-config", path}, emptyEnv)
+	assert.Nil(t, err)
+	assert.True(t, config.FakeVendor.Enabled)
+	assert.Equal(t, fakeVendorFaults{"users": {Latency: 200 * time.Millisecond, ErrorRate: 0.1}}, config.FakeVendor.Faults)
+
+	// Environment variables and flags replace the file's faults entirely rather than merging with them.
+	config, err = loadConfig([]string{"-config", path}, fakeEnv(map[string]string{"BTT_FAKE_VENDOR_FAULTS": "posts=status:503"}))
+	assert.Nil(t, err)
+	assert.Equal(t, fakeVendorFaults{"posts": {Status: 503}}, config.FakeVendor.Faults)
+}
+
+func TestLoadConfigFakeVendorFaultsValidation(t *testing.T) {
+	_, err := loadConfig([]string{"-fake-vendor-faults=likes=status:500;users=errorRate:2"}, emptyEnv)
+	assert.NotNil(t, err)
+	assert.Equal(t, "Invalid configuration:\n"+
+		"  - fakeVendor.faults has unsupported route 'likes', expected '*' or one of: users, posts, comments, albums, photos, todos\n"+
+		"  - fakeVendor.faults.users.errorRate must be between 0 and 1, but got 2", err.Error())
+}
+
+func TestLoadConfigCassetteValidation(t *testing.T) {
+	_, err := loadConfig([]string{"-cassette-mode=replay"}, emptyEnv)
+	assert.EqualError(t, err, "Invalid configuration:\n  - cassette.file must be set when cassette.mode is 'replay'")
+
+	_, err = loadConfig([]string{"-cassette-mode=rewind"}, emptyEnv)
+	assert.EqualError(t, err, "Invalid configuration:\n  - cassette.mode must be one of passthrough, record, or replay, but got 'rewind'")
+
+	config, err := loadConfig([]string{}, fakeEnv(map[string]string{"BTT_CASSETTE_MODE": "record", "BTT_CASSETTE_FILE": "cassette.json"}))
+	assert.Nil(t, err)
+	assert.Equal(t, cassetteConfig{Mode: cassetteRecord, File: "cassette.json"}, config.Cassette)
+}
+
+func TestLoadConfigCacheStaleValidation(t *testing.T) {
+	_, err := loadConfig([]string{"-cache-stale-while-revalidate=-1s", "-cache-stale-if-error=-1m"}, emptyEnv)
+	assert.EqualError(t, err, "Invalid configuration:\n"+
+		"  - cache.staleWhileRevalidate must not be negative, but got -1s\n"+
+		"  - cache.staleIfError must not be negative, but got -1m0s")
+}
+
+func TestLoadConfigSearchValidation(t *testing.T) {
+	_, err := loadConfig([]string{"-search-refresh-interval=0s", "-search-min-refresh-interval=-1s"}, emptyEnv)
+	assert.EqualError(t, err, "Invalid configuration:\n"+
+		"  - search.refreshInterval must be positive when search is enabled, but got 0s\n"+
+		"  - search.minRefreshInterval must not be negative, but got -1s")
+
+	_, err = loadConfig([]string{"-search-enabled=false", "-search-refresh-interval=0s"}, emptyEnv)
+	assert.Nil(t, err)
+}
+
+func TestLoadConfigBatchValidation(t *testing.T) {
+	_, err := loadConfig([]string{"-batch-max-size=0", "-batch-concurrency=-1", "-batch-bulk-threshold=-1"}, emptyEnv)
+	assert.EqualError(t, err, "Invalid configuration:\n"+
+		"  - batch.maxSize must be positive, but got 0\n"+
+		"  - batch.concurrency must be positive, but got -1\n"+
+		"  - batch.bulkThreshold must not be negative, but got -1")
+}
+
+func TestLoadConfigPartialResponsesValidation(t *testing.T) {
+	_, err := loadConfig([]string{"-partial-responses-status=500"}, emptyEnv)
+	assert.EqualError(t, err, "Invalid configuration:\n"+
+		"  - partialResponses.status must be 200 or 206, but got 500")
+
+	config, err := loadConfig([]string{"-partial-responses-status=206"}, emptyEnv)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusPartialContent, config.PartialResponses.Status)
+}
+
+func TestLoadConfigAlbumsValidation(t *testing.T) {
+	_, err := loadConfig([]string{"-albums-max-photos-per-album=0", "-albums-concurrency=0"}, emptyEnv)
+	assert.EqualError(t, err, "Invalid configuration:\n"+
+		"  - albums.maxPhotosPerAlbum must be positive, but got 0\n"+
+		"  - albums.concurrency must be positive, but got 0")
+}
+
+func TestLoadConfigSourceValidation(t *testing.T) {
+	_, err := loadConfig([]string{"-source=jsonFile"}, emptyEnv)
+	assert.EqualError(t, err, "Invalid configuration:\n  - source.file must be set when source.backend is 'jsonFile'")
+
+	_, err = loadConfig([]string{"-source=mainframe"}, emptyEnv)
+	assert.EqualError(t, err, "Invalid configuration:\n  - source.backend must be one of typicode, jsonFile, or mirror, but got 'mainframe'")
+
+	config, err := loadConfig([]string{}, fakeEnv(map[string]string{"BTT_SOURCE": "jsonFile", "BTT_SOURCE_FILE": "users.json"}))
+	assert.Nil(t, err)
+	assert.Equal(t, sourceConfig{Backend: sourceJSONFile, File: "users.json"}, config.Source)
+}
+
+func TestLoadConfigMirrorValidation(t *testing.T) {
+	_, err := loadConfig([]string{"-source=mirror", "-mirror-sync-interval=0s"}, emptyEnv)
+	assert.EqualError(t, err, "Invalid configuration:\n"+
+		"  - mirror.file must be set when source.backend is 'mirror'\n"+
+		"  - mirror.syncInterval must be positive when source.backend is 'mirror', but got 0s")
+
+	_, err = loadConfig([]string{"-mirror-sync-interval=0s"}, emptyEnv)
+	assert.Nil(t, err)
+
+	config, err := loadConfig([]string{"-source=mirror", "-mirror-file=mirror.db"}, emptyEnv)
+	assert.Nil(t, err)
+	assert.Equal(t, mirrorConfig{File: "mirror.db", SyncInterval: 5 * time.Minute}, config.Mirror)
+}
+
+// newUpstreamHTTPClient
+
+func TestNewUpstreamHTTPClient(t *testing.T) {
+	client := newUpstreamHTTPClient(defaultAppConfig.Upstream)
+	assert.Equal(t, defaultAppConfig.Upstream.Timeout, client.Timeout)
+	assert.NotNil(t, client.Transport)
+}
+
+// Test Helpers
+
+func writeTestConfigFile(t *testing.T, name string, contents string) string {
+	path := filepath.Join(t.TempDir(), name)
+	assert.Nil(t, ioutil.WriteFile(path, []byte(contents), 0600))
+	return path
+}
+
+func fakeEnv(env map[string]string) func(string) string {
+	return func(name string) string {
+		return env[name]
+	}
+}
+
+func emptyEnv(string) string {
+	return ""
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+)
+
+/*
+	Errors
+
+	Typed errors shared across every layer, so that the controller layer can decide on an API status code
+	without having to string-match error messages or compare against empty models.
+
+	Every error keeps its underlying cause (if any), so errors.Is/errors.As still see through to things like
+	context.DeadlineExceeded or circuitOpenError, while errors.As against domainError or any of the concrete types
+	below tells the controller what kind of failure it was.
+*/
+
+// Implemented by every typed error below so that the controller layer can map any of them to an API response.
+type domainError interface {
+	error
+	httpStatus() int
+	errorCode() string
+}
+
+// Common fields for every typed error.
+type domainErrorDetails struct {
+	// Human-readable description of what went wrong.
+	Message string
+
+	// Underlying error that caused this one, if any.
+	Cause error
+}
+
+func (details domainErrorDetails) Error() string {
+	return details.Message
+}
+
+func (details domainErrorDetails) Unwrap() error {
+	return details.Cause
+}
+
+// The requested resource doesn't exist, e.g. Cool Vendor returned a 404 for a user ID.
+type notFoundError struct {
+	domainErrorDetails
+}
+
+func (*notFoundError) httpStatus() int   { return http.StatusNotFound }
+func (*notFoundError) errorCode() string { return "not_found" }
+
+// Cool Vendor couldn't be reached or told us they're having problems, i.e. a communication error, a 5xx, or a 429.
+// These are generally temporary.
+type upstreamUnavailableError struct {
+	domainErrorDetails
+
+	// Status code Cool Vendor responded with, if they responded at all.
+	StatusCode int
+}
+
+func (*upstreamUnavailableError) httpStatus() int   { return http.StatusServiceUnavailable }
+func (*upstreamUnavailableError) errorCode() string { return "upstream_unavailable" }
+
+// Cool Vendor responded, but with something we didn't expect, e.g. an unexpected status code or a body that doesn't
+// match their documented models. Retrying won't help with these.
+type upstreamBadResponseError struct {
+	domainErrorDetails
+
+	// Status code Cool Vendor responded with.
+	StatusCode int
+}
+
+func (*upstreamBadResponseError) httpStatus() int   { return http.StatusBadGateway }
+func (*upstreamBadResponseError) errorCode() string { return "upstream_bad_response" }
+
+// We ran out of time waiting on Cool Vendor.
+type timeoutError struct {
+	domainErrorDetails
+}
+
+func (*timeoutError) httpStatus() int   { return http.StatusGatewayTimeout }
+func (*timeoutError) errorCode() string { return "timeout" }
+
+// The consumer's input is invalid, e.g. a non-integer user ID or an unsupported query parameter value.
+type invalidInputError struct {
+	domainErrorDetails
+}
+
+func (*invalidInputError) httpStatus() int   { return http.StatusBadRequest }
+func (*invalidInputError) errorCode() string { return "invalid_input" }
+
+// Classify an error from executing a request to Cool Vendor or reading its response, where we never got a usable
+// response at all.
+func newUpstreamCommunicationError(cause error, message string) error {
+	if isTimeout(cause) {
+		return &timeoutError{domainErrorDetails{Message: message, Cause: cause}}
+	}
+	return &upstreamUnavailableError{domainErrorDetails: domainErrorDetails{Message: message, Cause: cause}}
+}
+
+// Classify an unexpected status code from Cool Vendor.
+func newUpstreamStatusError(statusCode int, message string) error {
+	if statusCode == http.StatusTooManyRequests || statusCode >= http.StatusInternalServerError {
+		return &upstreamUnavailableError{domainErrorDetails: domainErrorDetails{Message: message}, StatusCode: statusCode}
+	}
+	return &upstreamBadResponseError{domainErrorDetails: domainErrorDetails{Message: message}, StatusCode: statusCode}
+}
+
+// Classify a failure to decode a 200 Ok response body from Cool Vendor. Running out of time partway through reading
+// the body shows up here too, which shouldn't be blamed on Cool Vendor's response.
+func newUpstreamDecodeError(cause error, message string) error {
+	if isTimeout(cause) {
+		return &timeoutError{domainErrorDetails{Message: message, Cause: cause}}
+	}
+	return &upstreamBadResponseError{domainErrorDetails: domainErrorDetails{Message: message, Cause: cause}, StatusCode: http.StatusOK}
+}
+
+func newInvalidInputError(message string) error {
+	return &invalidInputError{domainErrorDetails{Message: message}}
+}
+
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
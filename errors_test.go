@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// Domain errors
+
+func TestDomainErrorStatusAndCode(t *testing.T) {
+	tests := []struct {
+		err    domainError
+		status int
+		code   string
+	}{
+		{&notFoundError{}, http.StatusNotFound, "not_found"},
+		{&upstreamUnavailableError{}, http.StatusServiceUnavailable, "upstream_unavailable"},
+		{&upstreamBadResponseError{}, http.StatusBadGateway, "upstream_bad_response"},
+		{&timeoutError{}, http.StatusGatewayTimeout, "timeout"},
+		{&invalidInputError{}, http.StatusBadRequest, "invalid_input"},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.status, test.err.httpStatus(), test.code)
+		assert.Equal(t, test.code, test.err.errorCode())
+	}
+}
+
+func TestDomainErrorUnwrap(t *testing.T) {
+	err := newUpstreamCommunicationError(errFoo, "Could not reach Cool Vendor")
+	assert.Equal(t, "Could not reach Cool Vendor", err.Error())
+	assert.True(t, errors.Is(err, errFoo))
+
+	var unavailableErr *upstreamUnavailableError
+	assert.True(t, errors.As(err, &unavailableErr))
+	var domainErr domainError
+	assert.True(t, errors.As(err, &domainErr))
+	assert.Equal(t, http.StatusServiceUnavailable, domainErr.httpStatus())
+}
+
+func TestNewUpstreamCommunicationErrorTimeout(t *testing.T) {
+	err := newUpstreamCommunicationError(context.DeadlineExceeded, "Timed out")
+	var timeoutErr *timeoutError
+	assert.True(t, errors.As(err, &timeoutErr))
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+}
+
+func TestNewUpstreamStatusError(t *testing.T) {
+	var unavailableErr *upstreamUnavailableError
+	assert.True(t, errors.As(newUpstreamStatusError(http.StatusTooManyRequests, ""), &unavailableErr))
+	assert.True(t, errors.As(newUpstreamStatusError(http.StatusBadGateway, ""), &unavailableErr))
+
+	var badResponseErr *upstreamBadResponseError
+	assert.True(t, errors.As(newUpstreamStatusError(http.StatusBadRequest, ""), &badResponseErr))
+	assert.Equal(t, http.StatusBadRequest, badResponseErr.StatusCode)
+}
+
+func TestNewUpstreamDecodeError(t *testing.T) {
+	var badResponseErr *upstreamBadResponseError
+	assert.True(t, errors.As(newUpstreamDecodeError(errFoo, ""), &badResponseErr))
+	assert.Equal(t, http.StatusOK, badResponseErr.StatusCode)
+
+	var timeoutErr *timeoutError
+	assert.True(t, errors.As(newUpstreamDecodeError(context.DeadlineExceeded, ""), &timeoutErr))
+}
+
+// Controller - respondWithError
+
+func TestRespondWithErrorDomainError(t *testing.T) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/v1/users/1", nil)
+
+	respondWithError(c, newUpstreamStatusError(http.StatusBadRequest, "Cool Vendor didn't like that"))
+
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+	assertProblem(t, w, "upstream_bad_response", "Cool Vendor didn't like that")
+}
+
+func TestRespondWithErrorUntypedDeadline(t *testing.T) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/v1/users/1", nil)
+
+	respondWithError(c, context.DeadlineExceeded)
+
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+	assert.Contains(t, w.Body.String(), "\"code\": \"timeout\"")
+}
+
+func TestRespondWithErrorUntyped(t *testing.T) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/v1/users/1", nil)
+
+	respondWithError(c, errFoo)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, w.Body.String(), "\"code\": \"internal_error\"")
+	// Unexpected errors could contain anything, so they should only ever show up in our logs.
+	assert.NotContains(t, w.Body.String(), errFoo.Error())
+}
@@ -0,0 +1,348 @@
+package main
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Fake Vendor
+//
+// A stand-in for Cool Vendor's API that serves a seed dataset bundled into the binary, so that the service and its
+// tests can run without ever reaching jsonplaceholder.typicode.com. It follows the same contract as the real thing:
+//   - "/users", "/posts", etc. list every item, optionally filtered by any field, e.g. "/posts?userId=1". Repeating
+//     a filter matches any of its values, e.g. "/posts?userId=1&userId=2".
+//   - "/users/1" returns a single item, or a 404 with an empty {} body if it doesn't exist.
+//   - "/users/1/posts" lists the items that belong to another item, i.e. the same as "/posts?userId=1".
+//
+// Each route can also have faults injected (see fakeVendorFault) to exercise our failure paths end to end.
+
+//go:embed seed/typicode.json
+var fakeVendorSeed []byte
+
+// Every resource in the seed dataset, which are also the only routes faults can be injected for (besides "*").
+var fakeVendorResources = []string{"users", "posts", "comments", "albums", "photos", "todos"}
+
+// Faults to inject into a single route's responses. The zero value is a perfectly healthy route.
+type fakeVendorFault struct {
+	// Delay every response by this long.
+	Latency time.Duration `yaml:"latency"`
+
+	// Fraction of requests, between 0 and 1, that fail with ErrorStatus instead.
+	ErrorRate float64 `yaml:"errorRate"`
+
+	// Status code for the requests that fail because of ErrorRate. Defaults to a 500.
+	ErrorStatus int `yaml:"errorStatus"`
+
+	// Respond to every request with this status code instead of the real response.
+	Status int `yaml:"status"`
+}
+
+// Faults keyed by route, i.e. the resource name such as "users" or "posts", or "*" for any route that doesn't
+// have faults of its own.
+//
+// Also usable as a flag in the format "users=latency:200ms,errorRate:0.1;posts=status:503".
+type fakeVendorFaults map[string]fakeVendorFault
+
+func (faults fakeVendorFaults) String() string {
+	routes := make([]string, 0, len(faults))
+	for route := range faults {
+		routes = append(routes, route)
+	}
+	sort.Strings(routes)
+
+	var specs []string
+	for _, route := range routes {
+		fault := faults[route]
+		var settings []string
+		if fault.Latency != 0 {
+			settings = append(settings, "latency:"+fault.Latency.String())
+		}
+		if fault.ErrorRate != 0 {
+			settings = append(settings, "errorRate:"+strconv.FormatFloat(fault.ErrorRate, 'g', -1, 64))
+		}
+		if fault.ErrorStatus != 0 {
+			settings = append(settings, "errorStatus:"+strconv.Itoa(fault.ErrorStatus))
+		}
+		if fault.Status != 0 {
+			settings = append(settings, "status:"+strconv.Itoa(fault.Status))
+		}
+		specs = append(specs, route+"="+strings.Join(settings, ","))
+	}
+	return strings.Join(specs, ";")
+}
+
+// Replace every fault with the ones in value. An empty value clears them all.
+func (faults *fakeVendorFaults) Set(value string) error {
+	parsed := fakeVendorFaults{}
+	for _, spec := range strings.Split(value, ";") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		route, settings, ok := strings.Cut(spec, "=")
+		if !ok {
+			return fmt.Errorf("expected 'route=setting:value,...', but got '%s'", spec)
+		}
+
+		var fault fakeVendorFault
+		for _, setting := range strings.Split(settings, ",") {
+			name, value, ok := strings.Cut(strings.TrimSpace(setting), ":")
+			if !ok {
+				return fmt.Errorf("expected 'setting:value' for route '%s', but got '%s'", route, setting)
+			}
+			var err error
+			switch name {
+			case "latency":
+				fault.Latency, err = time.ParseDuration(value)
+			case "errorRate":
+				fault.ErrorRate, err = strconv.ParseFloat(value, 64)
+			case "errorStatus":
+				fault.ErrorStatus, err = strconv.Atoi(value)
+			case "status":
+				fault.Status, err = strconv.Atoi(value)
+			default:
+				return fmt.Errorf("unsupported setting '%s' for route '%s', expected one of: latency, errorRate, errorStatus, status", name, route)
+			}
+			if err != nil {
+				return fmt.Errorf("invalid %s for route '%s': %w", name, route, err)
+			}
+		}
+		parsed[strings.TrimSpace(route)] = fault
+	}
+	*faults = parsed
+	return nil
+}
+
+// Check every fault and report each problem in the same format as appConfig.validate.
+func (faults fakeVendorFaults) problems() []string {
+	var problems []string
+	for route, fault := range faults {
+		if route != "*" && !isFakeVendorResource(route) {
+			problems = append(problems, fmt.Sprintf("fakeVendor.faults has unsupported route '%s', expected '*' or one of: %s", route, strings.Join(fakeVendorResources, ", ")))
+		}
+		if fault.Latency < 0 {
+			problems = append(problems, fmt.Sprintf("fakeVendor.faults.%s.latency must not be negative, but got %s", route, fault.Latency))
+		}
+		if fault.ErrorRate < 0 || fault.ErrorRate > 1 {
+			problems = append(problems, fmt.Sprintf("fakeVendor.faults.%s.errorRate must be between 0 and 1, but got %v", route, fault.ErrorRate))
+		}
+		if fault.ErrorStatus != 0 && (fault.ErrorStatus < 400 || fault.ErrorStatus > 599) {
+			problems = append(problems, fmt.Sprintf("fakeVendor.faults.%s.errorStatus must be between 400 and 599, but got %d", route, fault.ErrorStatus))
+		}
+		if fault.Status != 0 && (fault.Status < 200 || fault.Status > 599) {
+			problems = append(problems, fmt.Sprintf("fakeVendor.faults.%s.status must be between 200 and 599, but got %d", route, fault.Status))
+		}
+	}
+	sort.Strings(problems)
+	return problems
+}
+
+func isFakeVendorResource(name string) bool {
+	for _, resource := range fakeVendorResources {
+		if name == resource {
+			return true
+		}
+	}
+	return false
+}
+
+type fakeVendor struct {
+	resources map[string][]fakeVendorItem
+	faults    fakeVendorFaults
+
+	// Only overridden by unit tests.
+	random func() float64
+	sleep  func(ctx context.Context, d time.Duration) error
+}
+
+// A single item in the seed dataset. The raw JSON is served as-is, while the decoded fields are only used for
+// filtering.
+type fakeVendorItem struct {
+	raw    json.RawMessage
+	fields map[string]interface{}
+}
+
+// Build a fake vendor from a seed dataset in the format {"users": [...], "posts": [...], ...}.
+func newFakeVendor(seed []byte, faults fakeVendorFaults) (*fakeVendor, error) {
+	var rawResources map[string][]json.RawMessage
+	if err := json.Unmarshal(seed, &rawResources); err != nil {
+		return nil, fmt.Errorf("Unable to parse fake vendor seed data: error=%w", err)
+	}
+
+	resources := map[string][]fakeVendorItem{}
+	for name, rawItems := range rawResources {
+		items := make([]fakeVendorItem, len(rawItems))
+		for i, raw := range rawItems {
+			items[i].raw = raw
+			if err := json.Unmarshal(raw, &items[i].fields); err != nil {
+				return nil, fmt.Errorf("Unable to parse fake vendor seed data for '%s': error=%w", name, err)
+			}
+		}
+		resources[name] = items
+	}
+
+	return &fakeVendor{
+		resources: resources,
+		faults:    faults,
+		random:    rand.Float64,
+		sleep:     sleepContext,
+	}, nil
+}
+
+func (fakeVendor *fakeVendor) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	segments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if err := fakeVendor.injectFaults(w, r, segments[0]); err != nil {
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		fakeVendor.writeJSON(w, http.StatusMethodNotAllowed, json.RawMessage("{}"))
+		return
+	}
+
+	resource, ok := fakeVendor.resources[segments[0]]
+	if !ok {
+		fakeVendor.writeJSON(w, http.StatusNotFound, json.RawMessage("{}"))
+		return
+	}
+
+	switch len(segments) {
+	case 1:
+		// e.g. "/posts?userId=1"
+		fakeVendor.writeJSON(w, http.StatusOK, filterFakeVendorItems(resource, r.URL.Query()))
+	case 2:
+		// e.g. "/users/1"
+		for _, item := range resource {
+			if fmt.Sprint(item.fields["id"]) == segments[1] {
+				fakeVendor.writeJSON(w, http.StatusOK, item.raw)
+				return
+			}
+		}
+		fakeVendor.writeJSON(w, http.StatusNotFound, json.RawMessage("{}"))
+	case 3:
+		// e.g. "/users/1/posts", which is the same as "/posts?userId=1"
+		children, ok := fakeVendor.resources[segments[2]]
+		if !ok {
+			fakeVendor.writeJSON(w, http.StatusNotFound, json.RawMessage("{}"))
+			return
+		}
+		filters := r.URL.Query()
+		filters.Set(strings.TrimSuffix(segments[0], "s")+"Id", segments[1])
+		fakeVendor.writeJSON(w, http.StatusOK, filterFakeVendorItems(children, filters))
+	default:
+		fakeVendor.writeJSON(w, http.StatusNotFound, json.RawMessage("{}"))
+	}
+}
+
+// Apply any faults configured for the route. Returns an error if a response has already been written or the
+// consumer went away, in which case the request shouldn't be served any further.
+func (fakeVendor *fakeVendor) injectFaults(w http.ResponseWriter, r *http.Request, route string) error {
+	fault, ok := fakeVendor.faults[route]
+	if !ok {
+		fault = fakeVendor.faults["*"]
+	}
+
+	if fault.Latency > 0 {
+		if err := fakeVendor.sleep(r.Context(), fault.Latency); err != nil {
+			return err
+		}
+	}
+
+	status := fault.Status
+	if status == 0 && fault.ErrorRate > 0 && fakeVendor.random() < fault.ErrorRate {
+		status = fault.ErrorStatus
+		if status == 0 {
+			status = http.StatusInternalServerError
+		}
+	}
+	if status == 0 {
+		return nil
+	}
+
+	log.Printf("Fake vendor injecting a %d for %s %s", status, r.Method, r.URL.String())
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "Fake vendor injected a %d for route '%s'", status, route)
+	return errors.New("fault injected")
+}
+
+func (fakeVendor *fakeVendor) writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	body, err := json.Marshal(value)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+// Keep only the items that match every filter, where a filter matches if the item's field equals any of the
+// filter's values. Query parameters starting with "_" are reserved by Typicode for things like pagination, so
+// they aren't treated as filters.
+func filterFakeVendorItems(items []fakeVendorItem, filters map[string][]string) []json.RawMessage {
+	matches := []json.RawMessage{}
+	for _, item := range items {
+		if fakeVendorItemMatches(item, filters) {
+			matches = append(matches, item.raw)
+		}
+	}
+	return matches
+}
+
+func fakeVendorItemMatches(item fakeVendorItem, filters map[string][]string) bool {
+	for field, values := range filters {
+		if strings.HasPrefix(field, "_") {
+			continue
+		}
+		fieldValue, ok := item.fields[field]
+		if !ok {
+			return false
+		}
+		matched := false
+		for _, value := range values {
+			if fmt.Sprint(fieldValue) == value {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// Start serving the bundled seed dataset on a random local port for as long as the process is running, and return
+// the base URL to point typicodeClient at.
+func startFakeVendor(faults fakeVendorFaults) (string, error) {
+	fakeVendor, err := newFakeVendor(fakeVendorSeed, faults)
+	if err != nil {
+		return "", err
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", fmt.Errorf("Unable to listen for the fake vendor: error=%w", err)
+	}
+	go func() {
+		if err := http.Serve(listener, fakeVendor); err != nil {
+			log.Printf("Fake vendor stopped unexpectedly: error=%s", err.Error())
+		}
+	}()
+
+	baseUrl := "http://" + listener.Addr().String()
+	log.Printf("Serving the fake vendor at %s with faults '%s'", baseUrl, faults.String())
+	return baseUrl, nil
+}
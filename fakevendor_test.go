@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeVendor.ServeHTTP
+
+func TestFakeVendorGetUser(t *testing.T) {
+	w := serveTestFakeVendor(t, nil, "/users/1")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var userResp user
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(&userResp))
+	assert.Equal(t, 1, userResp.ID)
+	assert.Equal(t, "Leanne Graham", userResp.Name)
+	assert.Equal(t, "Romaguera-Crona", userResp.Company.Name)
+}
+
+func TestFakeVendorGetUserNotFound(t *testing.T) {
+	w := serveTestFakeVendor(t, nil, "/users/123456")
+
+	// Same as Cool Vendor, which responds with an empty object.
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "{}", w.Body.String())
+}
+
+func TestFakeVendorFilter(t *testing.T) {
+	var postsResp []postSummary
+	w := serveTestFakeVendor(t, nil, "/posts?userId=1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(&postsResp))
+	assert.Len(t, postsResp, 10)
+
+	// Repeated filters match any of their values.
+	w = serveTestFakeVendor(t, nil, "/posts?userId=1&userId=2")
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(&postsResp))
+	assert.Len(t, postsResp, 20)
+
+	w = serveTestFakeVendor(t, nil, "/posts?userId=123456")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "[]", w.Body.String())
+
+	w = serveTestFakeVendor(t, nil, "/todos?userId=1&completed=true")
+	var todos []struct {
+		UserID    int  `json:"userId"`
+		Completed bool `json:"completed"`
+	}
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(&todos))
+	assert.NotEmpty(t, todos)
+	for _, todo := range todos {
+		assert.Equal(t, 1, todo.UserID)
+		assert.True(t, todo.Completed)
+	}
+}
+
+func TestFakeVendorNestedRoute(t *testing.T) {
+	nested := serveTestFakeVendor(t, nil, "/posts/1/comments")
+	filtered := serveTestFakeVendor(t, nil, "/comments?postId=1")
+
+	assert.Equal(t, http.StatusOK, nested.Code)
+	assert.Equal(t, filtered.Body.String(), nested.Body.String())
+	var commentsResp []comment
+	assert.Nil(t, json.NewDecoder(nested.Body).Decode(&commentsResp))
+	assert.Len(t, commentsResp, 5)
+}
+
+func TestFakeVendorUnknownRoute(t *testing.T) {
+	assert.Equal(t, http.StatusNotFound, serveTestFakeVendor(t, nil, "/likes").Code)
+	assert.Equal(t, http.StatusNotFound, serveTestFakeVendor(t, nil, "/users/1/likes").Code)
+}
+
+func TestFakeVendorFaultStatus(t *testing.T) {
+	faults := fakeVendorFaults{"posts": {Status: http.StatusServiceUnavailable}}
+
+	assert.Equal(t, http.StatusServiceUnavailable, serveTestFakeVendor(t, faults, "/posts?userId=1").Code)
+	assert.Equal(t, http.StatusOK, serveTestFakeVendor(t, faults, "/users/1").Code)
+}
+
+func TestFakeVendorFaultWildcard(t *testing.T) {
+	faults := fakeVendorFaults{"*": {Status: http.StatusBadGateway}, "users": {}}
+
+	assert.Equal(t, http.StatusBadGateway, serveTestFakeVendor(t, faults, "/posts?userId=1").Code)
+	// Routes with their own faults don't fall back to the wildcard.
+	assert.Equal(t, http.StatusOK, serveTestFakeVendor(t, faults, "/users/1").Code)
+}
+
+func TestFakeVendorFaultErrorRate(t *testing.T) {
+	fakeVendor := newTestFakeVendor(t, fakeVendorFaults{"users": {ErrorRate: 0.5, ErrorStatus: http.StatusTooManyRequests}})
+	rolls := []float64{0.1, 0.9}
+	fakeVendor.random = func() float64 {
+		roll := rolls[0]
+		rolls = rolls[1:]
+		return roll
+	}
+
+	w := httptest.NewRecorder()
+	fakeVendor.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/1", nil))
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+
+	w = httptest.NewRecorder()
+	fakeVendor.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/1", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestFakeVendorFaultLatency(t *testing.T) {
+	fakeVendor := newTestFakeVendor(t, fakeVendorFaults{"users": {Latency: time.Second}})
+	var sleeps []time.Duration
+	fakeVendor.sleep = func(ctx context.Context, d time.Duration) error {
+		sleeps = append(sleeps, d)
+		return nil
+	}
+
+	w := httptest.NewRecorder()
+	fakeVendor.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/1", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []time.Duration{time.Second}, sleeps)
+}
+
+// Make sure the seed data actually fits our models, so that the fake vendor can't drift from Cool Vendor's contract.
+func TestFakeVendorSeedMatchesModels(t *testing.T) {
+	var seed struct {
+		Users    []user        `json:"users"`
+		Posts    []postSummary `json:"posts"`
+		Comments []comment     `json:"comments"`
+	}
+	assert.Nil(t, json.Unmarshal(fakeVendorSeed, &seed))
+	assert.Len(t, seed.Users, 10)
+	assert.Len(t, seed.Posts, 100)
+	assert.Len(t, seed.Comments, 500)
+}
+
+// End to end against the fake vendor
+
+func TestFakeVendorEndToEnd(t *testing.T) {
+	initializeTestFakeVendor(t, nil)
+
+	w := httptest.NewRecorder()
+	setupRouter().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/user-posts/1?include=comments", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var userPostsResp userPosts
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(&userPostsResp))
+	assert.Equal(t, userInfo{Name: "Leanne Graham", Username: "Bret", Email: "Sincere@april.biz"}, userPostsResp.UserInfo)
+	assert.Len(t, userPostsResp.Posts, 10)
+	for _, post := range userPostsResp.Posts {
+		assert.Len(t, *post.Comments, 5)
+	}
+}
+
+func TestFakeVendorEndToEndNotFound(t *testing.T) {
+	initializeTestFakeVendor(t, nil)
+
+	w := httptest.NewRecorder()
+	setupRouter().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/user-posts/123456", nil))
+
+	assertProblem(t, w, "not_found", "Could not find userId=123456")
+}
+
+func TestFakeVendorEndToEndUpstreamFailure(t *testing.T) {
+	initializeTestFakeVendor(t, fakeVendorFaults{"posts": {Status: http.StatusInternalServerError}})
+
+	w := httptest.NewRecorder()
+	setupRouter().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/user-posts/1?strict=true", nil))
+
+	assertProblem(t, w, "upstream_unavailable", "Unexpected server error occurred trying to fetch posts for userId=1 from Cool Vendor: status=500")
+	assert.NotContains(t, w.Body.String(), "Fake vendor injected")
+}
+
+// fakeVendorFaults
+
+func TestFakeVendorFaultsSet(t *testing.T) {
+	var faults fakeVendorFaults
+	assert.Nil(t, faults.Set("users=latency:200ms,errorRate:0.1,errorStatus:503; posts=status:500"))
+	assert.Equal(t, fakeVendorFaults{
+		"users": {Latency: 200 * time.Millisecond, ErrorRate: 0.1, ErrorStatus: 503},
+		"posts": {Status: 500},
+	}, faults)
+	assert.Equal(t, "posts=status:500;users=latency:200ms,errorRate:0.1,errorStatus:503", faults.String())
+
+	assert.Nil(t, faults.Set(""))
+	assert.Empty(t, faults)
+}
+
+func TestFakeVendorFaultsSetInvalid(t *testing.T) {
+	var faults fakeVendorFaults
+	assert.EqualError(t, faults.Set("users"), "expected 'route=setting:value,...', but got 'users'")
+	assert.EqualError(t, faults.Set("users=latency"), "expected 'setting:value' for route 'users', but got 'latency'")
+	assert.EqualError(t, faults.Set("users=color:red"), "unsupported setting 'color' for route 'users', expected one of: latency, errorRate, errorStatus, status")
+	assert.Contains(t, faults.Set("users=latency:soon").Error(), "invalid latency for route 'users'")
+}
+
+// Test Helpers
+
+func newTestFakeVendor(t *testing.T, faults fakeVendorFaults) *fakeVendor {
+	fakeVendor, err := newFakeVendor(fakeVendorSeed, faults)
+	assert.Nil(t, err)
+	return fakeVendor
+}
+
+func serveTestFakeVendor(t *testing.T, faults fakeVendorFaults, target string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	newTestFakeVendor(t, faults).ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+	return w
+}
+
+// Point the whole service at a fake vendor for the duration of the test.
+func initializeTestFakeVendor(t *testing.T, faults fakeVendorFaults) {
+	server := httptest.NewServer(newTestFakeVendor(t, faults))
+	t.Cleanup(server.Close)
+
+	config := defaultAppConfig
+	config.Upstream.BaseUrl = server.URL
+	config.Retry = retryConfig{MaxAttempts: 2, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+	config.Cache.Enabled = false
+	assert.Nil(t, initialize(config))
+	t.Cleanup(func() {
+		typicodeCache = nil
+		typicodeBreaker = nil
+		postSearchIndexer = nil
+	})
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Filtering
+//
+// Lets consumers narrow down a user's posts to the ones mentioning a keyword, rather than downloading every post and
+// searching through them on their end. Filters are applied before sorting and pagination, so that pages and their
+// totals are always of the matching posts.
+
+// Filters for a user's posts. The zero value matches every post.
+type postsFilter struct {
+	// Whitespace-separated terms that must each appear somewhere in the post's title or body, ignoring case.
+	Query string
+
+	// Text that must appear in the post's title, ignoring case.
+	TitleContains string
+}
+
+// Parse the "q" and "titleContains" query parameters.
+func parsePostsFilter(c *gin.Context) postsFilter {
+	return postsFilter{
+		Query:         strings.TrimSpace(c.Query("q")),
+		TitleContains: strings.TrimSpace(c.Query("titleContains")),
+	}
+}
+
+func (filter postsFilter) isEmpty() bool {
+	return filter.Query == "" && filter.TitleContains == ""
+}
+
+// Return the posts matching every part of the filter, keeping their original order.
+func filterPosts(posts []postSummary, filter postsFilter) []postSummary {
+	if filter.isEmpty() {
+		return posts
+	}
+
+	terms := strings.Fields(strings.ToLower(filter.Query))
+	titleContains := strings.ToLower(filter.TitleContains)
+	matched := []postSummary{}
+	for _, post := range posts {
+		title := strings.ToLower(post.Title)
+		if !strings.Contains(title, titleContains) {
+			continue
+		}
+		if containsAll(title+"\n"+strings.ToLower(post.Body), terms) {
+			matched = append(matched, post)
+		}
+	}
+	return matched
+}
+
+func containsAll(text string, terms []string) bool {
+	for _, term := range terms {
+		if !strings.Contains(text, term) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// parsePostsFilter
+
+func TestParsePostsFilter(t *testing.T) {
+	filter := parsePostsFilter(newTestPaginationContext("/v1/user-posts/1?q=+Foo+Bar+&titleContains=baz"))
+	assert.Equal(t, postsFilter{Query: "Foo Bar", TitleContains: "baz"}, filter)
+	assert.False(t, filter.isEmpty())
+
+	assert.True(t, parsePostsFilter(newTestPaginationContext("/v1/user-posts/1?q=+")).isEmpty())
+}
+
+// filterPosts
+
+func TestFilterPostsQuery(t *testing.T) {
+	posts := testFilterPosts()
+
+	// Matches either the title or the body, ignoring case.
+	assert.Equal(t, []int{1, 3}, postIDs(filterPosts(posts, postsFilter{Query: "GOLANG"})))
+
+	// Every term has to appear, but not necessarily together or in the same field.
+	assert.Equal(t, []int{1}, postIDs(filterPosts(posts, postsFilter{Query: "golang generics"})))
+	assert.Equal(t, []int{1}, postIDs(filterPosts(posts, postsFilter{Query: "tips golang"})))
+
+	// Terms can match part of a word.
+	assert.Equal(t, []int{1, 2}, postIDs(filterPosts(posts, postsFilter{Query: "tip"})))
+
+	assert.Empty(t, filterPosts(posts, postsFilter{Query: "rust"}))
+}
+
+func TestFilterPostsTitleContains(t *testing.T) {
+	posts := testFilterPosts()
+
+	// Only the title counts, and the whole value has to appear as is.
+	assert.Equal(t, []int{3}, postIDs(filterPosts(posts, postsFilter{TitleContains: "golang"})))
+	assert.Equal(t, []int{1}, postIDs(filterPosts(posts, postsFilter{TitleContains: "go TIPS"})))
+	assert.Empty(t, filterPosts(posts, postsFilter{TitleContains: "tips go"}))
+}
+
+func TestFilterPostsCombined(t *testing.T) {
+	posts := testFilterPosts()
+
+	assert.Equal(t, []int{1}, postIDs(filterPosts(posts, postsFilter{Query: "golang", TitleContains: "tips"})))
+}
+
+func TestFilterPostsEmpty(t *testing.T) {
+	posts := testFilterPosts()
+
+	assert.Equal(t, posts, filterPosts(posts, postsFilter{}))
+}
+
+// Controller - getUserPostsByUserId filtering
+
+func TestGetUserPostsByUserIdFiltered(t *testing.T) {
+	initializeTestFakeVendor(t, nil)
+
+	w := httptest.NewRecorder()
+	setupRouter().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/user-posts/1?q=Praesentium&pageSize=2", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var userPostsResp userPosts
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(&userPostsResp))
+	assert.Len(t, userPostsResp.Posts, 2)
+	for _, post := range userPostsResp.Posts {
+		assert.Contains(t, strings.ToLower(post.Title+" "+post.Body), "praesentium")
+	}
+	assert.NotNil(t, userPostsResp.Matched)
+	assert.Equal(t, *userPostsResp.Matched, userPostsResp.Pagination.Total)
+	assert.Contains(t, userPostsResp.Pagination.Links.Next, "q=Praesentium")
+}
+
+func TestGetUserPostsByUserIdFilteredNoMatches(t *testing.T) {
+	initializeTestFakeVendor(t, nil)
+
+	w := httptest.NewRecorder()
+	setupRouter().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/user-posts/1?titleContains=nothing+like+this", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"posts": []`)
+	assert.Contains(t, w.Body.String(), `"matched": 0`)
+}
+
+func TestGetUserPostsByUserIdUnfilteredOmitsMatched(t *testing.T) {
+	initializeTestFakeVendor(t, nil)
+
+	w := httptest.NewRecorder()
+	setupRouter().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/user-posts/1", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), `"matched"`)
+}
+
+// Test Helpers
+
+func testFilterPosts() []postSummary {
+	return []postSummary{
+		{ID: 1, Title: "Go tips", Body: "Generics are finally coming to Golang."},
+		{ID: 2, Title: "Cooking tips", Body: "Salt everything."},
+		{ID: 3, Title: "Why Golang", Body: "It's simple."},
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Freshness Headers
+//
+// cachingHTTPClient says how old every response it serves is, but those responses only ever make it as far as
+// typicodeClient. To let consumers see how fresh our own responses are, every response from Cool Vendor that went
+// into one is recorded on the way back up, and the response then gets:
+//
+//   - "Age", i.e. how old the oldest data in it is, in seconds.
+//   - "Warning", i.e. every warning from any of the data in it, such as it being stale.
+//
+// Responses made up entirely of data fresh from Cool Vendor get neither.
+
+// Everything known about how fresh the data for a single request is.
+type responseFreshness struct {
+	mutex    sync.Mutex
+	hasAge   bool
+	age      int
+	warnings []string
+}
+
+type responseFreshnessKey struct{}
+
+// Get the freshness being recorded for the request that ctx belongs to, if any.
+func responseFreshnessFromContext(ctx context.Context) *responseFreshness {
+	freshness, _ := ctx.Value(responseFreshnessKey{}).(*responseFreshness)
+	return freshness
+}
+
+// Fold in the "Age" and "Warning" headers of a response from Cool Vendor.
+func (freshness *responseFreshness) record(header http.Header) {
+	if header.Get("Age") == "" && len(header.Values("Warning")) == 0 {
+		return
+	}
+
+	freshness.mutex.Lock()
+	defer freshness.mutex.Unlock()
+	if header.Get("Age") != "" {
+		age := int(parseAge(header).Seconds())
+		if !freshness.hasAge || age > freshness.age {
+			freshness.age = age
+		}
+		freshness.hasAge = true
+	}
+	for _, warning := range header.Values("Warning") {
+		if !containsString(freshness.warnings, warning) {
+			freshness.warnings = append(freshness.warnings, warning)
+		}
+	}
+}
+
+// Set the recorded "Age" and "Warning" headers on our own response.
+func (freshness *responseFreshness) apply(header http.Header) {
+	freshness.mutex.Lock()
+	defer freshness.mutex.Unlock()
+	if freshness.hasAge {
+		header.Set("Age", strconv.Itoa(freshness.age))
+	}
+	for _, warning := range freshness.warnings {
+		header.Add("Warning", warning)
+	}
+}
+
+// Clients - freshnessRecordingHTTPClient
+//
+// Records the freshness of every response onto the request it was for. Should sit in front of every other client so
+// that it sees exactly what typicodeClient does.
+type freshnessRecordingHTTPClient struct {
+	Client httpClient
+}
+
+func (freshnessRecordingHTTPClient freshnessRecordingHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	resp, err := freshnessRecordingHTTPClient.Client.Do(req)
+	if err != nil {
+		return resp, err
+	}
+	if freshness := responseFreshnessFromContext(req.Context()); freshness != nil {
+		freshness.record(resp.Header)
+	}
+	return resp, nil
+}
+
+// Middleware that records the freshness of every response from Cool Vendor that goes into the current request, and
+// sets the matching headers on the response right before it's written.
+func freshnessHeaders() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		freshness := &responseFreshness{}
+		c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), responseFreshnessKey{}, freshness))
+		c.Writer = &freshnessResponseWriter{ResponseWriter: c.Writer, freshness: freshness}
+		c.Next()
+	}
+}
+
+// Sets the freshness headers the first time anything is written, since headers can't be changed after that.
+type freshnessResponseWriter struct {
+	gin.ResponseWriter
+	freshness *responseFreshness
+	applied   bool
+}
+
+func (w *freshnessResponseWriter) applyOnce() {
+	if !w.applied && !w.Written() {
+		w.freshness.apply(w.Header())
+	}
+	w.applied = true
+}
+
+func (w *freshnessResponseWriter) WriteHeaderNow() {
+	w.applyOnce()
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *freshnessResponseWriter) Write(data []byte) (int, error) {
+	w.applyOnce()
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *freshnessResponseWriter) WriteString(s string) (int, error) {
+	w.applyOnce()
+	return w.ResponseWriter.WriteString(s)
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// responseFreshness
+
+func TestResponseFreshnessRecord(t *testing.T) {
+	freshness := &responseFreshness{}
+
+	freshness.record(http.Header{})
+	freshness.record(http.Header{"Age": []string{"5"}})
+	freshness.record(http.Header{"Age": []string{"30"}, "Warning": []string{warningResponseIsStale}})
+	freshness.record(http.Header{"Age": []string{"10"}, "Warning": []string{warningResponseIsStale, warningRevalidationFailed}})
+
+	// The oldest age wins, and every warning is only set once.
+	header := http.Header{}
+	freshness.apply(header)
+	assert.Equal(t, "30", header.Get("Age"))
+	assert.Equal(t, []string{warningResponseIsStale, warningRevalidationFailed}, header.Values("Warning"))
+}
+
+func TestResponseFreshnessNothingCached(t *testing.T) {
+	freshness := &responseFreshness{}
+
+	freshness.record(http.Header{"Content-Type": []string{"application/json"}})
+
+	header := http.Header{}
+	freshness.apply(header)
+	assert.Empty(t, header)
+}
+
+// freshnessRecordingHTTPClient.Do
+
+func TestFreshnessRecordingHTTPClient(t *testing.T) {
+	mockHTTPClientDo = func(r *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: 200,
+			Header:     http.Header{"Age": []string{"12"}},
+			Body:       ioutil.NopCloser(strings.NewReader("hello")),
+		}, nil
+	}
+	freshness := &responseFreshness{}
+	req := newTestGetRequest(t, "/users/1")
+	req = req.WithContext(context.WithValue(req.Context(), responseFreshnessKey{}, freshness))
+
+	_, err := freshnessRecordingHTTPClient{Client: &mockHTTPClient{}}.Do(req)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 12, freshness.age)
+}
+
+func TestFreshnessRecordingHTTPClientWithoutRecorder(t *testing.T) {
+	mockHTTPClientDo = func(r *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: 200,
+			Header:     http.Header{"Age": []string{"12"}},
+			Body:       ioutil.NopCloser(strings.NewReader("hello")),
+		}, nil
+	}
+
+	// e.g. the search indexer, which isn't part of any consumer's request.
+	resp, err := freshnessRecordingHTTPClient{Client: &mockHTTPClient{}}.Do(newTestGetRequest(t, "/users/1"))
+
+	assert.Nil(t, err)
+	assert.Equal(t, "12", resp.Header.Get("Age"))
+}
+
+// Controller - freshnessHeaders
+
+func TestGetUserByIdStaleIfError(t *testing.T) {
+	failing := false
+	mockHTTPClientDo = func(r *http.Request) (*http.Response, error) {
+		if failing {
+			return nil, errFoo
+		}
+		body, _ := json.Marshal(testUser)
+		return &http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(strings.NewReader(string(body))),
+		}, nil
+	}
+	cache := newCachingHTTPClient(&mockHTTPClient{}, cacheOptions{TTL: time.Minute, MaxEntries: 10, StaleIfError: time.Hour})
+	userPostServiceImpl = userPostService{
+		Source: typicodeClient{
+			Client:  freshnessRecordingHTTPClient{Client: &dedupingHTTPClient{Client: cache}},
+			BaseUrl: mockBaseURL,
+		},
+	}
+
+	// Fresh from Cool Vendor, so there's nothing to say.
+	w := httptest.NewRecorder()
+	setupRouter().ServeHTTP(w, httptest.NewRequest(http.MethodGet, fmt.Sprint("/v1/users/", userId), nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Age"))
+	assert.Empty(t, w.Header().Values("Warning"))
+
+	failing = true
+	storedAt := time.Now()
+	cache.now = func() time.Time { return storedAt.Add(5 * time.Minute) }
+	w = httptest.NewRecorder()
+	setupRouter().ServeHTTP(w, httptest.NewRequest(http.MethodGet, fmt.Sprint("/v1/users/", userId), nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "300", w.Header().Get("Age"))
+	assert.Equal(t, []string{warningResponseIsStale, warningRevalidationFailed}, w.Header().Values("Warning"))
+	var userResp user
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(&userResp))
+	assert.Equal(t, testUser, userResp)
+}
@@ -1,14 +1,21 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io/ioutil"
+	"log"
+	"net"
 	"net/http"
-	"reflect"
+	"net/url"
+	"os"
+	"os/signal"
 	"strconv"
+	"strings"
 	"sync"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -19,35 +26,217 @@ import (
 // in a more global context, such as during service startup, so that it can be injected and shared across different services.
 var userPostServiceImpl userPostService
 
-func initialize() {
+// Shared response cache and circuit breakers in front of Cool Vendor. Kept as their own globals so that their
+// stats can be exposed for diagnostics.
+var typicodeCache *cachingHTTPClient
+var typicodeBreaker *circuitBreakingHTTPClient
+
+// Keeps the local mirror of Cool Vendor in sync, or nil unless the mirror source is in use.
+var typicodeMirror *mirrorSyncer
+
+// Full-text search index over every post, or nil if search is disabled.
+var postSearchIndexer *searchIndexer
+
+// Upper bound on how long any single API request can spend waiting on Cool Vendor. See serverConfig.RequestTimeout.
+var requestTimeout = defaultServerConfig.RequestTimeout
+
+// Limits for fetching many users' posts at once. See batchConfig.
+var batchMaxSize = defaultAppConfig.Batch.MaxSize
+var batchConcurrency = defaultAppConfig.Batch.Concurrency
+var batchBulkThreshold = defaultAppConfig.Batch.BulkThreshold
+
+// Whether a response can leave out a sub-resource that failed, and the status code it gets when it does. See
+// partialResponsesConfig.
+var partialResponsesEnabled = defaultAppConfig.PartialResponses.Enabled
+var partialResponseStatus = defaultAppConfig.PartialResponses.Status
+
+// Limits for fetching a user's albums and their photos. See albumsConfig.
+var albumsMaxPhotosPerAlbum = defaultAppConfig.Albums.MaxPhotosPerAlbum
+var albumsConcurrency = defaultAppConfig.Albums.Concurrency
+
+func initialize(config appConfig) error {
+	requestTimeout = config.Server.RequestTimeout
+	batchMaxSize = config.Batch.MaxSize
+	batchConcurrency = config.Batch.Concurrency
+	batchBulkThreshold = config.Batch.BulkThreshold
+	partialResponsesEnabled = config.PartialResponses.Enabled
+	partialResponseStatus = config.PartialResponses.Status
+	albumsMaxPhotosPerAlbum = config.Albums.MaxPhotosPerAlbum
+	albumsConcurrency = config.Albums.Concurrency
+
+	typicodeBreaker = nil
+	typicodeCache = nil
+	typicodeMirror = nil
+	var source UserPostSource
+	switch config.Source.Backend {
+	case sourceJSONFile:
+		jsonFileSource, err := newJSONFileSource(config.Source.File)
+		if err != nil {
+			return err
+		}
+		source = jsonFileSource
+	default:
+		client, err := newTypicodeHTTPClient(config)
+		if err != nil {
+			return err
+		}
+		// In a more formal project, the http.Client, typicodeClient, and userPostService would probably
+		// get instantiated once-and-only-once in a more global context, such as during service startup, so that
+		// they can be shared across different services.
+		source = typicodeClient{Client: client, BaseUrl: config.Upstream.BaseUrl}
+
+		if config.Source.Backend == sourceMirror {
+			store, err := openMirrorStore(config.Mirror.File)
+			if err != nil {
+				return err
+			}
+			// Every sync should get the latest data rather than whatever happens to be cached.
+			typicodeMirror = newMirrorSyncer(typicodeClient{Client: client, BaseUrl: config.Upstream.BaseUrl, BypassCache: true}, store, mirrorSyncOptions{
+				Interval: config.Mirror.SyncInterval,
+				Timeout:  config.Server.RequestTimeout,
+			})
+			source = mirrorSource{UserPostSource: source, Store: store}
+		}
+	}
+
+	// The index is rebuilt from whatever the source has right now, which also refreshes any cache along the way. The
+	// mirror is the exception, since it's kept fresh by its own syncs and should keep the index going without Cool Vendor.
+	postSearchIndexer = nil
+	if config.Search.Enabled {
+		indexSource := source
+		if cacheBypassingSource, ok := source.(cacheBypassingSource); ok && config.Source.Backend != sourceMirror {
+			indexSource = cacheBypassingSource.bypassingCache()
+		}
+		postSearchIndexer = newSearchIndexer(indexSource, searchIndexerOptions{
+			RefreshInterval:    config.Search.RefreshInterval,
+			MinRefreshInterval: config.Search.MinRefreshInterval,
+			RefreshTimeout:     config.Server.RequestTimeout,
+		})
+	}
+
 	userPostServiceImpl = userPostService{
-		TypicodeClient: typicodeClient{
-			// Right now, we don't care about any special customizations for the sake of this project,
-			// but if we wanted to customize certain attributes, such as timeouts, redirect policies, etc.,
-			// then it would make sense to inject a customized client
-			//
-			// Also, in a more formal project, the http.Client, typicodeClient, and userPostService would probably
-			// get instantiated once-and-only-once in a more global context, such as during service startup, so that
-			// they can be shared across different services.
-			Client: http.DefaultClient,
-
-			// If we were testing across multiple environments, then this would probably make more sense as
-			// a config/environment variable.
-			BaseUrl: "https://jsonplaceholder.typicode.com",
+		Source:        source,
+		SearchIndexer: postSearchIndexer,
+	}
+	return nil
+}
+
+// Build the client for talking to Cool Vendor, with every client policy layered in front of the network. Also sets
+// typicodeBreaker and typicodeCache when they're enabled.
+func newTypicodeHTTPClient(config appConfig) (httpClient, error) {
+	// Cassettes sit right on top of the network so that they record exactly what Cool Vendor sent us.
+	var client httpClient = newUpstreamHTTPClient(config.Upstream)
+	if config.Cassette.Mode != cassettePassthrough {
+		cassetteClient, err := newCassetteHTTPClient(client, config.Cassette.Mode, config.Cassette.File)
+		if err != nil {
+			return nil, err
+		}
+		client = cassetteClient
+	}
+
+	// Only transient failures are retried, and always underneath the cache so that a cached response never
+	// waits on a backoff.
+	client = &retryingHTTPClient{
+		Client: client,
+		Policy: retryPolicy{
+			MaxAttempts: config.Retry.MaxAttempts,
+			BaseDelay:   config.Retry.BaseDelay,
+			MaxDelay:    config.Retry.MaxDelay,
 		},
 	}
+
+	// Breakers sit above the retries so that a request that exhausted all of its retries only counts as one
+	// failure, and so that an open breaker short-circuits before any retries even start.
+	if config.CircuitBreaker.Enabled {
+		typicodeBreaker = newCircuitBreakingHTTPClient(client, breakerOptions{
+			FailureRateThreshold: config.CircuitBreaker.FailureRateThreshold,
+			MinRequests:          config.CircuitBreaker.MinRequests,
+			WindowSize:           config.CircuitBreaker.WindowSize,
+			CoolDown:             config.CircuitBreaker.CoolDown,
+		})
+		client = typicodeBreaker
+	}
+
+	if config.Cache.Enabled {
+		typicodeCache = newCachingHTTPClient(client, cacheOptions{
+			TTL:         config.Cache.TTL,
+			NotFoundTTL: config.Cache.NotFoundTTL,
+			MaxEntries:  config.Cache.MaxEntries,
+
+			StaleWhileRevalidate: config.Cache.StaleWhileRevalidate,
+			StaleIfError:         config.Cache.StaleIfError,
+			RevalidateTimeout:    config.Server.RequestTimeout,
+		})
+		client = typicodeCache
+	}
+
+	// Concurrent identical requests are collapsed in front of the cache so that a burst of cache misses for the
+	// same user still only costs a single request to Cool Vendor.
+	client = &dedupingHTTPClient{Client: client}
+
+	// Outermost so that it records exactly the responses that every typicodeClient sees. See freshness.go.
+	client = freshnessRecordingHTTPClient{Client: client}
+
+	return client, nil
 }
 
 func setupRouter() *gin.Engine {
 	router := gin.Default()
+	router.Use(correlationID(), freshnessHeaders(), requestDeadline(requestTimeout))
+	router.GET("/v1/user-posts", getUserPostsByUserIds)
 	router.GET("/v1/user-posts/:userId", getUserPostsByUserId)
+	router.GET("/v1/users/:userId", getUserById)
+	router.GET("/v1/users/:userId/albums", getAlbumsByUserId)
+	router.GET("/v1/users/:userId/todos", getTodosByUserId)
+	router.GET("/v1/search/posts", searchPosts)
+	router.GET("/v1/diagnostics/cache", getCacheStats)
+	router.GET("/v1/diagnostics/circuit-breakers", getCircuitBreakerStats)
+	router.GET("/v1/diagnostics/mirror", getMirrorStats)
 	return router
 }
 
 func main() {
-	initialize()
-	router := setupRouter()
-	router.Run("localhost:8080")
+	config, err := loadConfig(os.Args[1:], os.Getenv)
+	if err != nil {
+		log.Fatalf("Unable to load configuration: %s", err.Error())
+	}
+
+	// Develop and test offline against our own stand-in for Cool Vendor.
+	if config.FakeVendor.Enabled {
+		config.Upstream.BaseUrl, err = startFakeVendor(config.FakeVendor.Faults)
+		if err != nil {
+			log.Fatalf("Unable to start the fake vendor: %s", err.Error())
+		}
+	}
+
+	if err := initialize(config); err != nil {
+		log.Fatalf("Unable to initialize: %s", err.Error())
+	}
+	server := newHTTPServer(config.Server, setupRouter())
+
+	listener, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		log.Fatalf("Unable to listen on %s: error=%s", server.Addr, err.Error())
+	}
+	log.Printf("Listening on %s", listener.Addr())
+
+	// Stop accepting new connections on SIGINT/SIGTERM, but let in-flight requests finish first.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if typicodeMirror != nil {
+		go typicodeMirror.Run(ctx)
+	}
+	if postSearchIndexer != nil {
+		go postSearchIndexer.Run(ctx)
+	}
+	if err := runServer(ctx, server, listener, config.Server.ShutdownGracePeriod); err != nil {
+		log.Fatalf("Server stopped unexpectedly: error=%s", err.Error())
+	}
+	if typicodeMirror != nil {
+		if err := typicodeMirror.Store.Close(); err != nil {
+			log.Printf("Unable to close the mirror: error=%s", err.Error())
+		}
+	}
 }
 
 /*
@@ -67,25 +256,406 @@ func getUserPostsByUserId(c *gin.Context) {
 	// Validate input as expected ID type.
 	userIdInt, err := strconv.Atoi(userId)
 	if err != nil {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "Expected ID in integer format, but got '" + userId + "' instead"})
+		respondWithError(c, newInvalidInputError("Expected ID in integer format, but got '"+userId+"' instead"))
+		return
+	}
+
+	// Nested sub-resources are opt-in since each one costs additional requests to Cool Vendor.
+	includes, err := parseIncludes(c.Query("include"), includeComments)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	page, pageSize, sortOrder, err := parsePostsPage(c)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	allowPartial, err := parseAllowPartial(c)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	userPostsResp, err := userPostServiceFor(c).getUserPostsByUserId(c.Request.Context(), userIdInt, userPostsOptions{
+		IncludeComments: includes[includeComments],
+		Page:            page,
+		PageSize:        pageSize,
+		Sort:            sortOrder,
+		Filter:          parsePostsFilter(c),
+		AllowPartial:    allowPartial,
+	})
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+	setPaginationLinks(c, userPostsResp.Pagination)
+	c.IndentedJSON(describePartialResponse(c, userPostsResp.Warnings), userPostsResp)
+}
+
+// Fetch the posts for many users at once, e.g. "?ids=1,2,3". Every user gets their own result, including any
+// errors, so the batch as a whole only fails for problems with the request itself.
+func getUserPostsByUserIds(c *gin.Context) {
+	userIds, err := parseUserIds(c.Query("ids"), batchMaxSize)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	includes, err := parseIncludes(c.Query("include"), includeComments)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	sortOrder, err := parsePostsSort(c)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	allowPartial, err := parseAllowPartial(c)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	items := userPostServiceFor(c).getUserPostsByUserIds(c.Request.Context(), userIds, userPostsOptions{
+		IncludeComments: includes[includeComments],
+		Sort:            sortOrder,
+		Filter:          parsePostsFilter(c),
+		AllowPartial:    allowPartial,
+	}, batchOptions{
+		Concurrency:   batchConcurrency,
+		BulkThreshold: batchBulkThreshold,
+	})
+
+	// Same as a single user, there's nobody to respond to if the consumer already hung up.
+	if errors.Is(c.Request.Context().Err(), context.Canceled) {
+		c.AbortWithStatus(499)
+		return
+	}
+
+	batch := userPostsBatch{Results: make([]userPostsBatchResult, 0, len(items))}
+	for _, item := range items {
+		if item.Err != nil {
+			// Point at the single user equivalent so that each problem reads the same as it would on its own.
+			problem := problemForError(c, item.Err)
+			problem.Instance = fmt.Sprint("/v1/user-posts/", item.UserID)
+			batch.Results = append(batch.Results, userPostsBatchResult{UserID: item.UserID, Status: problem.Status, Error: &problem})
+			batch.Failed++
+			continue
+		}
+		userPosts := item.UserPosts
+		status := describePartialResponse(c, userPosts.Warnings)
+		batch.Results = append(batch.Results, userPostsBatchResult{UserID: item.UserID, Status: status, UserPosts: &userPosts})
+		if len(userPosts.Warnings) > 0 {
+			batch.Partial++
+		} else {
+			batch.Succeeded++
+		}
+	}
+	c.IndentedJSON(http.StatusOK, batch)
+}
+
+func getUserById(c *gin.Context) {
+	userId := c.Param("userId")
+
+	// Validate input as expected ID type.
+	userIdInt, err := strconv.Atoi(userId)
+	if err != nil {
+		respondWithError(c, newInvalidInputError("Expected ID in integer format, but got '"+userId+"' instead"))
+		return
+	}
+
+	// Validate the projection up front so that we don't waste a request to Cool Vendor on a bad input.
+	var fields []string
+	if fieldsParam := c.Query("fields"); fieldsParam != "" {
+		fields = strings.Split(fieldsParam, ",")
+		for i, field := range fields {
+			field = strings.TrimSpace(field)
+			fields[i] = field
+			if !isUserProfileField(field) {
+				respondWithError(c, newInvalidInputError("Unsupported field '"+field+"', expected one of: "+strings.Join(userProfileFields, ", ")))
+				return
+			}
+		}
+	}
+
+	userResp, err := userPostServiceFor(c).getUserById(c.Request.Context(), userIdInt)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+	if fields == nil {
+		c.IndentedJSON(http.StatusOK, userResp)
+		return
+	}
+
+	projection, err := projectFields(userResp, fields)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+	c.IndentedJSON(http.StatusOK, projection)
+}
+
+// Fetch a user's albums, optionally with the first few photos of each one, e.g. "?include=photos".
+func getAlbumsByUserId(c *gin.Context) {
+	userId := c.Param("userId")
+
+	// Validate input as expected ID type.
+	userIdInt, err := strconv.Atoi(userId)
+	if err != nil {
+		respondWithError(c, newInvalidInputError("Expected ID in integer format, but got '"+userId+"' instead"))
+		return
+	}
+
+	includes, err := parseIncludes(c.Query("include"), includePhotos)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	allowPartial, err := parseAllowPartial(c)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	userAlbumsResp, err := userPostServiceFor(c).getAlbumsByUserId(c.Request.Context(), userIdInt, userAlbumsOptions{
+		IncludePhotos:     includes[includePhotos],
+		MaxPhotosPerAlbum: albumsMaxPhotosPerAlbum,
+		Concurrency:       albumsConcurrency,
+		AllowPartial:      allowPartial,
+	})
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+	c.IndentedJSON(describePartialResponse(c, userAlbumsResp.Warnings), userAlbumsResp)
+}
+
+// Fetch a user's todos along with how many of them they've completed, optionally only the (in)complete ones, e.g.
+// "?completed=false".
+func getTodosByUserId(c *gin.Context) {
+	userId := c.Param("userId")
+
+	// Validate input as expected ID type.
+	userIdInt, err := strconv.Atoi(userId)
+	if err != nil {
+		respondWithError(c, newInvalidInputError("Expected ID in integer format, but got '"+userId+"' instead"))
+		return
+	}
+
+	completed, err := parseCompletedFilter(c)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	userTodosResp, err := userPostServiceFor(c).getTodosByUserId(c.Request.Context(), userIdInt, userTodosOptions{Completed: completed})
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+	c.IndentedJSON(http.StatusOK, userTodosResp)
+}
+
+// Middleware that puts a deadline on every request's context, which then gets threaded all the way down to the
+// upstream requests to Cool Vendor so that they get cancelled as soon as the deadline passes.
+func requestDeadline(timeout time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if timeout <= 0 {
+			c.Next()
+			return
+		}
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// Map any error from the controller or service layer to a problem details response (see problem.go).
+func respondWithError(c *gin.Context, err error) {
+	// The consumer already hung up, so there's nobody to respond to. 499 is nginx's convention for "client closed
+	// request", which at least keeps these out of the 5xx's in our access logs.
+	if errors.Is(err, context.Canceled) {
+		c.AbortWithStatus(499)
+		return
+	}
+
+	// Cool Vendor is known to be unhealthy, so let the consumer know when it's worth trying again.
+	var circuitErr *circuitOpenError
+	if errors.As(err, &circuitErr) {
+		c.Header("Retry-After", strconv.Itoa(retryAfterSeconds(circuitErr.RetryAfter)))
+	}
+
+	writeProblem(c, problemForError(c, err))
+}
+
+// Describe any error from the controller or service layer as problem details.
+//
+// Typed errors (see errors.go) carry their own status code and machine-readable error code, so this only has to
+// special-case the errors that don't come from our own code.
+func problemForError(c *gin.Context, err error) problemDetails {
+	var domainErr domainError
+	if !errors.As(err, &domainErr) {
+		if errors.Is(err, context.DeadlineExceeded) {
+			// Ran out of time before even getting to Cool Vendor, e.g. while waiting for a slot to fetch comments.
+			domainErr = &timeoutError{domainErrorDetails{Message: "Timed out waiting for Cool Vendor to respond: " + err.Error(), Cause: err}}
+		} else {
+			// Treat all other errors as 500s. Make sure we log it so that it can be troubleshooted in a live site environment too.
+			//
+			// Also, in general, in a live site environment, having monitors for general service 500 errors + alerts to page on-call
+			// engineers if we have a large burst within a short period of time would be good.
+			//
+			// The error itself only goes to the logs since there's no telling what it might contain.
+			log.Printf("Unexpected error handling %s %s: correlationId=%s error=%s", c.Request.Method, c.Request.URL.Path, correlationIDFor(c), err.Error())
+			return newProblemDetails(c, http.StatusInternalServerError, "internal_error", "An unexpected error occurred, please reach out with the correlation ID if it persists")
+		}
+	}
+
+	if domainErr.httpStatus() >= http.StatusInternalServerError {
+		log.Printf("Failed handling %s %s: correlationId=%s error=%s", c.Request.Method, c.Request.URL.Path, correlationIDFor(c), err.Error())
+	}
+	return newProblemDetails(c, domainErr.httpStatus(), domainErr.errorCode(), domainErr.Error())
+}
+
+// Rank every user's posts against the "q" query parameter.
+func searchPosts(c *gin.Context) {
+	if postSearchIndexer == nil {
+		respondWithError(c, &notFoundError{domainErrorDetails{Message: "Search is not enabled"}})
+		return
+	}
+
+	query := strings.TrimSpace(c.Query("q"))
+	if query == "" {
+		respondWithError(c, newInvalidInputError("Expected a non-empty 'q' to search for"))
+		return
+	}
+	page, pageSize, err := parsePageQuery(c)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	// Searches are served entirely from the index, so there's nothing to fall back on until it's first built.
+	index := postSearchIndexer.Index()
+	if index == nil {
+		respondWithError(c, &upstreamUnavailableError{domainErrorDetails: domainErrorDetails{Message: "The search index hasn't been built from Cool Vendor's posts yet, please try again shortly"}})
+		return
+	}
+
+	results := index.search(query, page, pageSize)
+	setPaginationLinks(c, results.Pagination)
+	c.IndentedJSON(http.StatusOK, results)
+}
+
+func getCircuitBreakerStats(c *gin.Context) {
+	if typicodeBreaker == nil {
+		respondWithError(c, &notFoundError{domainErrorDetails{Message: "Circuit breaking is not enabled"}})
+		return
+	}
+	c.IndentedJSON(http.StatusOK, typicodeBreaker.Stats())
+}
+
+func getCacheStats(c *gin.Context) {
+	if typicodeCache == nil {
+		respondWithError(c, &notFoundError{domainErrorDetails{Message: "Response caching is not enabled"}})
+		return
+	}
+	c.IndentedJSON(http.StatusOK, typicodeCache.Stats())
+}
+
+func getMirrorStats(c *gin.Context) {
+	if typicodeMirror == nil {
+		respondWithError(c, &notFoundError{domainErrorDetails{Message: "The mirror source is not enabled"}})
 		return
 	}
+	c.IndentedJSON(http.StatusOK, typicodeMirror.Stats())
+}
+
+// Resolve the service to use for the current request.
+//
+// Since userPostService and typicodeClient are plain values, we can hand out a tweaked copy for any per-request
+// client policies, such as a consumer sending "Cache-Control: no-cache" to force fresh data from Cool Vendor,
+// without affecting any other in-flight requests.
+func userPostServiceFor(c *gin.Context) userPostService {
+	service := userPostServiceImpl
+	if source, ok := service.Source.(cacheBypassingSource); ok && c.Request != nil && isNoCache(c.Request.Header) {
+		service.Source = source.bypassingCache()
+	}
+	return service
+}
+
+// Top-level JSON fields of "user" that can be requested through the "fields" query parameter.
+//
+// For example, "?fields=name,username,email" returns the same slim shape as "userInfo".
+var userProfileFields = []string{"id", "name", "username", "email", "address", "phone", "website", "company"}
+
+func isUserProfileField(field string) bool {
+	for _, userProfileField := range userProfileFields {
+		if field == userProfileField {
+			return true
+		}
+	}
+	return false
+}
+
+// Project a JSON-serializable value down to only the given top-level JSON fields.
+//
+// Round-tripping through JSON is not the cheapest way to do this, but it guarantees the projection always
+// matches the model's JSON tags without having to maintain a separate mapping by hand.
+func projectFields(value interface{}, fields []string) (map[string]interface{}, error) {
+	valueBytes, err := json.Marshal(value)
+	if err != nil {
+		return nil, errors.New("Unexpected error serializing response for field projection: error=" + err.Error())
+	}
+	var valueMap map[string]interface{}
+	if err := json.Unmarshal(valueBytes, &valueMap); err != nil {
+		return nil, errors.New("Unexpected error deserializing response for field projection: error=" + err.Error())
+	}
+
+	projection := map[string]interface{}{}
+	for _, field := range fields {
+		if fieldValue, ok := valueMap[field]; ok {
+			projection[field] = fieldValue
+		}
+	}
+	return projection, nil
+}
+
+// Supported values for the "include" query parameter.
+const includeComments = "comments"
+const includePhotos = "photos"
 
-	userPostsResp, err := userPostServiceImpl.getUserPostsByUserId(userIdInt)
+// Parse a comma-separated "include" query parameter, e.g. "?include=comments", into a set of requested
+// sub-resources. Any value that isn't in the allowed list is rejected so that typos don't silently get ignored.
+func parseIncludes(include string, allowed ...string) (map[string]bool, error) {
+	includes := map[string]bool{}
+	if include == "" {
+		return includes, nil
+	}
 
-	// We have a defined value. Return a 200 with the JSON response.
-	if !reflect.DeepEqual(userPostsResp, userPosts{}) {
-		c.IndentedJSON(http.StatusOK, userPostsResp)
-	} else if err == nil {
-		// No explicit error. Treat this as a 404.
-		c.IndentedJSON(http.StatusNotFound, gin.H{"message": "Could not find userId=" + userId})
-	} else {
-		// Treat all other errors as 500s. Make sure we log it so that it can be troubleshooted in a live site environment too.
-		//
-		// Also, in general, in a live site environment, having monitors for general service 500 errors + alerts to page on-call
-		// engineers if we have a large burst within a short period of time would be good.
-		c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+	for _, value := range strings.Split(include, ",") {
+		value = strings.TrimSpace(value)
+		isAllowed := false
+		for _, allowedValue := range allowed {
+			if value == allowedValue {
+				isAllowed = true
+				break
+			}
+		}
+		if !isAllowed {
+			return nil, newInvalidInputError("Unsupported include value '" + value + "', expected one of: " + strings.Join(allowed, ", "))
+		}
+		includes[value] = true
 	}
+	return includes, nil
 }
 
 /*
@@ -99,10 +669,37 @@ func getUserPostsByUserId(c *gin.Context) {
 */
 
 type userPostService struct {
-	TypicodeClient typicodeClient
+	// Where users, their posts, and everything else about them come from. See source.go.
+	Source UserPostSource
+
+	// Told about every user's posts we fetch so that it can notice when Cool Vendor has new data. Optional.
+	SearchIndexer *searchIndexer
+}
+
+// Upper bound on how many comment requests can be in flight at once for a single getUserPostsByUserId call.
+// Users can have any number of posts, so we don't want to blast Cool Vendor with one request per post all at once.
+const maxConcurrentCommentFetches = 5
+
+// Optional behaviors for getUserPostsByUserId. The zero value returns just the user info and their posts.
+type userPostsOptions struct {
+	// Nest the comments for each post under "posts[].comments".
+	IncludeComments bool
+
+	// Only return a single page of posts, starting from page 1. Zero for PageSize returns every post.
+	Page     int
+	PageSize int
+
+	// One of postSortOrders. Defaults to sorting by ID.
+	Sort string
+
+	// Only return the posts matching the filter. The zero value returns every post.
+	Filter postsFilter
+
+	// Leave out any posts or comments that fail rather than failing the whole request. See partial.go.
+	AllowPartial bool
 }
 
-func (userPostService userPostService) getUserPostsByUserId(userId int) (userPosts, error) {
+func (userPostService userPostService) getUserPostsByUserId(ctx context.Context, userId int, options userPostsOptions) (userPosts, error) {
 	var userResp user
 	var userErr error
 	var posts []postSummary
@@ -112,41 +709,126 @@ func (userPostService userPostService) getUserPostsByUserId(userId int) (userPos
 	waitGroup := sync.WaitGroup{}
 	waitGroup.Add(1)
 	go func() {
-		userResp, userErr = userPostService.TypicodeClient.getUserById(userId)
+		userResp, userErr = userPostService.Source.getUserById(ctx, userId)
 		waitGroup.Done()
 	}()
 	waitGroup.Add(1)
 	go func() {
-		posts, postsErr = userPostService.TypicodeClient.getPostsByUserId(userId)
+		posts, postsErr = userPostService.Source.getPostsByUserId(ctx, userId)
 		waitGroup.Done()
 	}()
 	waitGroup.Wait()
 
-	// Prioritize getUserById response first since we can't return any relevant information if no
-	// user info exists at all.
-	if !reflect.DeepEqual(userResp, user{}) {
-		// There is user data + no error from fetching posts
-		if postsErr == nil {
-			return userPosts{
-				ID: userResp.ID,
-				UserInfo: userInfo{
-					Name:     userResp.Name,
-					Username: userResp.Username,
-					Email:    userResp.Email,
-				},
-				Posts: posts,
-			}, nil
-		} else {
-			// Plan for 500 with any other error captured specifically while fetching posts
-			return userPosts{}, postsErr
-		}
-	} else if userErr == nil {
-		// Empty 'user' + no explicit error = 404 Not Found
-		return userPosts{}, nil
-	} else {
-		// Plan for 500 with any other error captured specifically while fetching user info
+	// Prioritize getUserById error first since we can't return any relevant information if no
+	// user info exists at all, including a notFoundError for a user that doesn't exist.
+	if userErr != nil {
 		return userPosts{}, userErr
 	}
+	if postsErr != nil {
+		if canRespondPartially(options.AllowPartial, postsErr) {
+			return newPartialUserPosts(userResp, postsErr), nil
+		}
+		return userPosts{}, postsErr
+	}
+	return userPostService.assembleUserPosts(ctx, userResp, posts, options)
+}
+
+// Build the response for a user out of every one of their posts, applying any options.
+func (userPostService userPostService) assembleUserPosts(ctx context.Context, userResp user, posts []postSummary, options userPostsOptions) (userPosts, error) {
+	if userPostService.SearchIndexer != nil {
+		userPostService.SearchIndexer.notifyPosts(userResp.ID, posts)
+	}
+
+	// Filter first so that the pagination totals only count the matching posts.
+	var matched *int
+	if !options.Filter.isEmpty() {
+		posts = filterPosts(posts, options.Filter)
+		matchedCount := len(posts)
+		matched = &matchedCount
+	}
+
+	// Narrow down to the requested page before fetching comments so that we only fetch comments for posts that
+	// the consumer is actually going to see.
+	sortPosts(posts, options.Sort)
+	var postsPagination *pagination
+	if options.PageSize > 0 {
+		posts, postsPagination = paginatePosts(posts, options.Page, options.PageSize)
+	}
+
+	var warnings []partialWarning
+	if options.IncludeComments {
+		if err := userPostService.attachComments(ctx, posts); err != nil {
+			if !canRespondPartially(options.AllowPartial, err) {
+				return userPosts{}, err
+			}
+			// Drop every comment since a partial set of comments would be misleading to the consumer.
+			for i := range posts {
+				posts[i].Comments = nil
+			}
+			warnings = append(warnings, partialWarning{Resource: partialResourceComments, err: err})
+		}
+	}
+	return userPosts{
+		ID: userResp.ID,
+		UserInfo: userInfo{
+			Name:     userResp.Name,
+			Username: userResp.Username,
+			Email:    userResp.Email,
+		},
+		Posts:      posts,
+		Matched:    matched,
+		Pagination: postsPagination,
+		Warnings:   warnings,
+	}, nil
+}
+
+// Fetch the full profile for a given user, including their address and company info.
+//
+// Returns a notFoundError if the user doesn't exist.
+func (userPostService userPostService) getUserById(ctx context.Context, userId int) (user, error) {
+	return userPostService.Source.getUserById(ctx, userId)
+}
+
+// Fetch the comments for each post and attach them in place.
+//
+// The requests run concurrently, but are bounded by maxConcurrentCommentFetches. If any fetch fails, then
+// the first error encountered is returned.
+func (userPostService userPostService) attachComments(ctx context.Context, posts []postSummary) error {
+	errs := make([]error, len(posts))
+	semaphore := make(chan struct{}, maxConcurrentCommentFetches)
+
+	waitGroup := sync.WaitGroup{}
+	for i := range posts {
+		waitGroup.Add(1)
+		go func(i int) {
+			defer waitGroup.Done()
+
+			// Don't bother queuing up for a slot if the consumer has already gone away or run out of time.
+			select {
+			case semaphore <- struct{}{}:
+				defer func() { <-semaphore }()
+			case <-ctx.Done():
+				errs[i] = ctx.Err()
+				return
+			}
+
+			// Each goroutine only ever writes to its own index, so there is no need for a lock here.
+			comments, err := userPostService.Source.getCommentsByPostId(ctx, posts[i].ID)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			posts[i].Comments = &comments
+		}(i)
+	}
+	waitGroup.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // Clients - General
@@ -164,86 +846,135 @@ type httpClient interface {
 type typicodeClient struct {
 	Client  httpClient
 	BaseUrl string
+
+	// Send "Cache-Control: no-cache" on every request so that any caching layer in "Client" goes upstream.
+	BypassCache bool
+}
+
+// Copy of the client that skips any caching layer, see cacheBypassingSource.
+func (typicodeClient typicodeClient) bypassingCache() UserPostSource {
+	typicodeClient.BypassCache = true
+	return typicodeClient
+}
+
+// Execute a request against Cool Vendor, applying any per-request client policies.
+func (typicodeClient typicodeClient) do(req *http.Request) (*http.Response, error) {
+	if typicodeClient.BypassCache {
+		req.Header.Set("Cache-Control", "no-cache")
+	}
+	return typicodeClient.Client.Do(req)
+}
+
+// Log Cool Vendor's response body for an unexpected status code. These can contain just about anything, so they only
+// ever go to our logs rather than back to the consumer, tagged with the correlation ID that the consumer gets instead.
+func (typicodeClient typicodeClient) logUnexpectedResponse(req *http.Request, resp *http.Response, body []byte) {
+	log.Printf("Unexpected response from Cool Vendor for %s %s: correlationId=%s status=%d body=%s",
+		req.Method, req.URL.String(), correlationIDFromContext(req.Context()), resp.StatusCode, string(body))
 }
 
 // Fetch the general user info from Cool Vendor.
-func (typicodeClient typicodeClient) getUserById(userId int) (user, error) {
+func (typicodeClient typicodeClient) getUserById(ctx context.Context, userId int) (user, error) {
 	// Note that even though the expected ID type is an integer, the Typicode API can actually handle
 	// any string and will just return a 404 with a generic empty JSON {} response, so we can save
 	// ourselves from having to actually validate the user's input here.
-	req, err := http.NewRequest(http.MethodGet, fmt.Sprint(typicodeClient.BaseUrl, "/users/", userId), nil)
-	if err != nil {
-		return user{}, errors.New("Unexpected error creating client request for Cool Vendor's Get User API: error=" + err.Error())
-	}
+	return getTypicodeResourceById[user](ctx, typicodeClient, "Get User", "users", userId)
+}
 
-	// Execute request.
-	resp, err := typicodeClient.Client.Do(req)
-	if err != nil {
-		return user{}, errors.New(fmt.Sprint("Unexpected communication or client policy error occurred trying to fetch userId=", userId, " from Cool Vendor: ", err.Error()))
-	}
-	defer resp.Body.Close()
-
-	// Standard API contract by Typicode. If we get a 200 Ok from them, then it's considered successful
-	// fetch of user data by ID.
-	//
-	// We should just make sure we explicitly handle the JSON parsing of the response body just to be safe.
-	if resp.StatusCode == http.StatusOK {
-		var userObj user
-		if err := json.NewDecoder(resp.Body).Decode(&userObj); err != nil {
-			return user{}, errors.New("Unable to parse response body as 'user' JSON for Cool Vendor's Get User By ID API: error=" + err.Error())
+// Fetch the posts for a given user ID.
+func (typicodeClient typicodeClient) getPostsByUserId(ctx context.Context, userId int) ([]postSummary, error) {
+	return getTypicodeList[postSummary](ctx, typicodeClient, "Get Posts", "posts", url.Values{"userId": {strconv.Itoa(userId)}})
+}
+
+// Fetch every user from Cool Vendor.
+func (typicodeClient typicodeClient) getUsers(ctx context.Context) ([]user, error) {
+	return getTypicodeList[user](ctx, typicodeClient, "Get Users", "users", nil)
+}
+
+// Fetch every post from Cool Vendor, across every user.
+func (typicodeClient typicodeClient) getPosts(ctx context.Context) ([]post, error) {
+	return getTypicodeList[post](ctx, typicodeClient, "Get Posts", "posts", nil)
+}
+
+// Most IDs sent in a single bulk request to Cool Vendor, so that the URL stays well within any length limits.
+const maxIdsPerBulkRequest = 50
+
+// Fetch the given users in as few requests as possible, keyed by their ID.
+//
+// Users that don't exist are just missing from the result, since Cool Vendor's filters return whatever matches
+// rather than a 404.
+func (typicodeClient typicodeClient) getUsersByIds(ctx context.Context, userIds []int) (map[int]user, error) {
+	usersById := map[int]user{}
+	for start := 0; start < len(userIds); start += maxIdsPerBulkRequest {
+		chunk := userIds[start:minInt(start+maxIdsPerBulkRequest, len(userIds))]
+		users, err := getTypicodeFilteredList[user](ctx, typicodeClient, "users", "id", chunk)
+		if err != nil {
+			return nil, err
 		}
-		return userObj, nil
-	} else if resp.StatusCode == http.StatusNotFound {
-		// Cool Vendor API returns a 404 when a user could not found in their system, so we can rely
-		// on that status code rather than trying to check for an empty {} object response.
-		return user{}, nil
-	} else {
-		// Any non 200 or 404 is considered a general error that we should at least log.
-		body, err := ioutil.ReadAll(resp.Body)
+		for _, user := range users {
+			usersById[user.ID] = user
+		}
+	}
+	return usersById, nil
+}
+
+// Fetch the posts for every given user in as few requests as possible, grouped by user ID. Every given user has an
+// entry, even if they have no posts.
+func (typicodeClient typicodeClient) getPostsByUserIds(ctx context.Context, userIds []int) (map[int][]postSummary, error) {
+	postsByUserId := map[int][]postSummary{}
+	for _, userId := range userIds {
+		postsByUserId[userId] = []postSummary{}
+	}
+	for start := 0; start < len(userIds); start += maxIdsPerBulkRequest {
+		chunk := userIds[start:minInt(start+maxIdsPerBulkRequest, len(userIds))]
+		posts, err := getTypicodeFilteredList[post](ctx, typicodeClient, "posts", "userId", chunk)
 		if err != nil {
-			return user{}, errors.New(fmt.Sprint("Unexpected error trying to read response body for server error trying to fetch userId=", userId, " from Cool Vendor: error=", err.Error()))
+			return nil, err
+		}
+		for _, post := range posts {
+			postsByUserId[post.UserID] = append(postsByUserId[post.UserID], post.postSummary)
 		}
-		return user{}, errors.New(fmt.Sprint("Unexpected server error occurred trying to fetch userId=", userId, " from Cool Vendor: ", string(body)))
 	}
+	return postsByUserId, nil
 }
 
-// Fetch the posts for a given user ID.
-func (typicodeClient typicodeClient) getPostsByUserId(userId int) ([]postSummary, error) {
-	// Form request.
-	req, err := http.NewRequest(http.MethodGet, fmt.Sprint(typicodeClient.BaseUrl, "/posts"), nil)
-	if err != nil {
-		return []postSummary{}, errors.New("Unexpected error creating client request for Cool Vendor's Get Posts API: error=" + err.Error())
+// Fetch one of Cool Vendor's list resources, e.g. "posts", filtered down to the items whose field matches any of
+// the given IDs.
+//
+// Repeating a filter in Typicode's API matches any of its values, e.g. "/posts?userId=1&userId=2" returns the posts
+// for both users.
+func getTypicodeFilteredList[T any](ctx context.Context, typicodeClient typicodeClient, resource string, field string, ids []int) ([]T, error) {
+	query := url.Values{}
+	for _, id := range ids {
+		query.Add(field, strconv.Itoa(id))
 	}
+	api := resource + " list"
+	return getTypicodeResource[[]T](ctx, typicodeClient, typicodeRequest{
+		Path:         "/" + resource,
+		Query:        query,
+		API:          api,
+		Description:  describeTypicodeList(resource, query),
+		DecodeTarget: "JSON for Cool Vendor's " + api + " API",
+	})
+}
+
+// Fetch the comments for a given post ID.
+func (typicodeClient typicodeClient) getCommentsByPostId(ctx context.Context, postId int) ([]comment, error) {
+	return getTypicodeList[comment](ctx, typicodeClient, "Get Comments", "comments", url.Values{"postId": {strconv.Itoa(postId)}})
+}
 
-	// Attach query params.
-	q := req.URL.Query()
-	q.Add("userId", fmt.Sprint(userId))
-	req.URL.RawQuery = q.Encode()
+// Fetch the albums for a given user ID.
+func (typicodeClient typicodeClient) getAlbumsByUserId(ctx context.Context, userId int) ([]album, error) {
+	return getTypicodeList[album](ctx, typicodeClient, "Get Albums", "albums", url.Values{"userId": {strconv.Itoa(userId)}})
+}
 
-	// Execute request.
-	resp, err := typicodeClient.Client.Do(req)
-	if err != nil {
-		return []postSummary{}, errors.New(fmt.Sprint("Unexpected communication or client policy error occurred trying to fetch posts for userId=", userId, " from Cool Vendor: ", err.Error()))
-	}
-	defer resp.Body.Close()
+// Fetch the photos for a given album ID.
+func (typicodeClient typicodeClient) getPhotosByAlbumId(ctx context.Context, albumId int) ([]photo, error) {
+	return getTypicodeList[photo](ctx, typicodeClient, "Get Photos", "photos", url.Values{"albumId": {strconv.Itoa(albumId)}})
+}
 
-	// Since Typicode's API contract will always return a 200 Ok for any valid request, including empty array []
-	// for any requests where there are no matching user IDs, then it is safe to assume that we can just map any
-	// 200 response from Typicode to the []postSummary value directly.
-	if resp.StatusCode == http.StatusOK {
-		var posts []postSummary
-		if err := json.NewDecoder(resp.Body).Decode(&posts); err != nil {
-			return []postSummary{}, errors.New("Unable to parse response body as '[]postSummary' JSON for Cool Vendor's Get Posts API: error=" + err.Error())
-		}
-		return posts, nil
-	} else {
-		// Any non-200 response should be processed as an error, though, since we are not expecting it.
-		body, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			return []postSummary{}, errors.New(fmt.Sprint("Unexpected error trying to read response body for server error trying to fetch posts for userId=", userId, " from Cool Vendor: error=", err.Error()))
-		}
-		return []postSummary{}, errors.New(fmt.Sprint("Unexpected server error occurred trying to fetch posts for userId=", userId, " from Cool Vendor: ", string(body)))
-	}
+// Fetch the todos for a given user ID.
+func (typicodeClient typicodeClient) getTodosByUserId(ctx context.Context, userId int) ([]todo, error) {
+	return getTypicodeList[todo](ctx, typicodeClient, "Get Todos", "todos", url.Values{"userId": {strconv.Itoa(userId)}})
 }
 
 /*
@@ -258,17 +989,45 @@ func (typicodeClient typicodeClient) getPostsByUserId(userId int) ([]postSummary
 /*
 	Represents a user from Cool Vendor's Users API.
 
-	Please note that this model is a bare-minimum version of their full response model
-	because we still want to explicitly define a model for usage in our internal code,
-	but we only care about consuming these subset of fields.
+	This model mirrors their full response model so that it can back the full user profile
+	endpoint. Other models, such as "userInfo", should be used whenever only a subset of the
+	fields is relevant to the consumer.
 
 	@see https://coolvendor.com/api-docs/models/#user
 */
 type user struct {
-	ID       int    `json:"id"`
-	Name     string `json:"name"`
-	Username string `json:"username"`
-	Email    string `json:"email"`
+	ID       int     `json:"id"`
+	Name     string  `json:"name"`
+	Username string  `json:"username"`
+	Email    string  `json:"email"`
+	Address  address `json:"address"`
+	Phone    string  `json:"phone"`
+	Website  string  `json:"website"`
+	Company  company `json:"company"`
+}
+
+// Represents a user's mailing address as part of "user".
+type address struct {
+	Street  string `json:"street"`
+	Suite   string `json:"suite"`
+	City    string `json:"city"`
+	Zipcode string `json:"zipcode"`
+	Geo     geo    `json:"geo"`
+}
+
+// Represents the coordinates of an "address".
+//
+// Cool Vendor returns these as strings rather than numbers, so we keep them as-is to avoid any lossy conversions.
+type geo struct {
+	Lat string `json:"lat"`
+	Lng string `json:"lng"`
+}
+
+// Represents the company a user works for as part of "user".
+type company struct {
+	Name        string `json:"name"`
+	CatchPhrase string `json:"catchPhrase"`
+	Bs          string `json:"bs"`
 }
 
 // Represents a combination of relevant user info and their current posts.
@@ -276,6 +1035,15 @@ type userPosts struct {
 	ID       int           `json:"id"`
 	UserInfo userInfo      `json:"userInfo"`
 	Posts    []postSummary `json:"posts"`
+
+	// How many of the user's posts matched the filter in total, across every page. Only set when filtering.
+	Matched *int `json:"matched,omitempty"`
+
+	// Only set when "posts" is a single page of the user's posts.
+	Pagination *pagination `json:"pagination,omitempty"`
+
+	// Any sub-resources that had to be left out, in which case they're null. See partial.go.
+	Warnings []partialWarning `json:"warnings,omitempty"`
 }
 
 // Represents a summary of user info to be used in "userPosts".
@@ -287,8 +1055,86 @@ type userInfo struct {
 
 // Represents a summary of raw post data to be used in "userPosts".
 // Any user-associated data is removed for this model.
+//
+// Comments are only populated when explicitly requested, e.g. "?include=comments", and are omitted otherwise. They're
+// a pointer so that a post without any comments still has an empty "comments", rather than looking the same as one
+// where they weren't requested at all.
 type postSummary struct {
+	ID       int        `json:"id"`
+	Title    string     `json:"title"`
+	Body     string     `json:"body"`
+	Comments *[]comment `json:"comments,omitempty"`
+}
+
+/*
+	Represents a post from Cool Vendor's Posts API, including the user it belongs to.
+
+	"postSummary" should be used instead whenever the post is already nested under its user.
+
+	@see https://coolvendor.com/api-docs/models/#post
+*/
+type post struct {
+	UserID int `json:"userId"`
+	postSummary
+}
+
+/*
+	Represents a comment on a post from Cool Vendor's Comments API.
+
+	Similar to "user", the "postId" is dropped since comments are always nested under their post.
+
+	@see https://coolvendor.com/api-docs/models/#comment
+*/
+type comment struct {
 	ID    int    `json:"id"`
-	Title string `json:"title"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
 	Body  string `json:"body"`
 }
+
+/*
+	Represents an album from Cool Vendor's Albums API.
+
+	Same as "comment", the "userId" is dropped since albums are always nested under their user.
+
+	Photos are only populated when explicitly requested, e.g. "?include=photos", and are omitted otherwise. Same as
+	"postSummary.Comments", they're a pointer so that an album without any photos still has an empty "photos".
+
+	@see https://coolvendor.com/api-docs/models/#album
+*/
+type album struct {
+	ID     int      `json:"id"`
+	Title  string   `json:"title"`
+	Photos *[]photo `json:"photos,omitempty"`
+
+	// Total number of photos in the album, which can be more than "photos" since those are capped. Only set along
+	// with "photos".
+	PhotoCount *int `json:"photoCount,omitempty"`
+}
+
+/*
+	Represents a photo from Cool Vendor's Photos API.
+
+	Similar to "comment", the "albumId" is dropped since photos are always nested under their album.
+
+	@see https://coolvendor.com/api-docs/models/#photo
+*/
+type photo struct {
+	ID           int    `json:"id"`
+	Title        string `json:"title"`
+	URL          string `json:"url"`
+	ThumbnailURL string `json:"thumbnailUrl"`
+}
+
+/*
+	Represents a todo from Cool Vendor's Todos API.
+
+	Same as "album", the "userId" is dropped since todos are always nested under their user.
+
+	@see https://coolvendor.com/api-docs/models/#todo
+*/
+type todo struct {
+	ID        int    `json:"id"`
+	Title     string `json:"title"`
+	Completed bool   `json:"completed"`
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -10,6 +11,7 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
@@ -28,9 +30,10 @@ func TestGetUserPostsByUserIdSuccess(t *testing.T) {
 	w := httptest.NewRecorder()
 	c, _ := gin.CreateTestContext(w)
 	c.Params = []gin.Param{{Key: "userId", Value: fmt.Sprint(userId)}}
+	c.Request = httptest.NewRequest(http.MethodGet, fmt.Sprint("/v1/user-posts/", userId), nil)
 
 	userPostServiceImpl = userPostService{
-		TypicodeClient: typicodeClient{
+		Source: typicodeClient{
 			Client:  &mockHTTPClient{},
 			BaseUrl: mockBaseURL,
 		},
@@ -66,21 +69,22 @@ func TestGetUserPostsByUserId400(t *testing.T) {
 	w := httptest.NewRecorder()
 	c, _ := gin.CreateTestContext(w)
 	c.Params = []gin.Param{{Key: "userId", Value: "test-123"}}
+	c.Request = httptest.NewRequest(http.MethodGet, "/v1/user-posts/test-123", nil)
 
 	getUserPostsByUserId(c)
 
 	assert.Equal(t, http.StatusBadRequest, w.Code)
-	expectedBody := "{\n    \"message\": \"Expected ID in integer format, but got 'test-123' instead\"\n}"
-	assert.Equal(t, expectedBody, w.Body.String())
+	assertProblem(t, w, "invalid_input", "Expected ID in integer format, but got 'test-123' instead")
 }
 
 func TestGetUserPostsByUserId404(t *testing.T) {
 	w := httptest.NewRecorder()
 	c, _ := gin.CreateTestContext(w)
 	c.Params = []gin.Param{{Key: "userId", Value: fmt.Sprint(userId)}}
+	c.Request = httptest.NewRequest(http.MethodGet, fmt.Sprint("/v1/user-posts/", userId), nil)
 
 	userPostServiceImpl = userPostService{
-		TypicodeClient: typicodeClient{
+		Source: typicodeClient{
 			Client:  &mockHTTPClient{},
 			BaseUrl: mockBaseURL,
 		},
@@ -102,17 +106,17 @@ func TestGetUserPostsByUserId404(t *testing.T) {
 	getUserPostsByUserId(c)
 
 	assert.Equal(t, http.StatusNotFound, w.Code)
-	expectedBody := fmt.Sprint("{\n    \"message\": \"Could not find userId=", userId, "\"\n}")
-	assert.Equal(t, expectedBody, w.Body.String())
+	assertProblem(t, w, "not_found", fmt.Sprint("Could not find userId=", userId))
 }
 
 func TestGetUserPostsByUserId500(t *testing.T) {
 	w := httptest.NewRecorder()
 	c, _ := gin.CreateTestContext(w)
 	c.Params = []gin.Param{{Key: "userId", Value: fmt.Sprint(userId)}}
+	c.Request = httptest.NewRequest(http.MethodGet, fmt.Sprint("/v1/user-posts/", userId), nil)
 
 	userPostServiceImpl = userPostService{
-		TypicodeClient: typicodeClient{
+		Source: typicodeClient{
 			Client:  &mockHTTPClient{},
 			BaseUrl: mockBaseURL,
 		},
@@ -133,16 +137,231 @@ func TestGetUserPostsByUserId500(t *testing.T) {
 
 	getUserPostsByUserId(c)
 
-	assert.Equal(t, http.StatusInternalServerError, w.Code)
-	expectedBody := fmt.Sprint("{\n    \"message\": \"Unexpected server error occurred trying to fetch userId=", userId, " from Cool Vendor: world.execute (me);\"\n}")
-	assert.Equal(t, expectedBody, w.Body.String())
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assertProblem(t, w, "upstream_unavailable", fmt.Sprint("Unexpected server error occurred trying to fetch userId=", userId, " from Cool Vendor: status=500"))
+	// Cool Vendor's response body should only ever show up in our logs.
+	assert.NotContains(t, w.Body.String(), errMsg500)
+}
+
+func TestGetUserPostsByUserIdUnsupportedInclude400(t *testing.T) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Params = []gin.Param{{Key: "userId", Value: fmt.Sprint(userId)}}
+	c.Request = httptest.NewRequest(http.MethodGet, "/v1/user-posts/1?include=likes", nil)
+
+	getUserPostsByUserId(c)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assertProblem(t, w, "invalid_input", "Unsupported include value 'likes', expected one of: comments")
+}
+
+func TestGetUserPostsByUserIdNoCache(t *testing.T) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Params = []gin.Param{{Key: "userId", Value: fmt.Sprint(userId)}}
+	c.Request = httptest.NewRequest(http.MethodGet, fmt.Sprint("/v1/user-posts/", userId), nil)
+	c.Request.Header.Set("Cache-Control", "no-cache")
+
+	userPostServiceImpl = userPostService{
+		Source: typicodeClient{
+			Client:  &mockHTTPClient{},
+			BaseUrl: mockBaseURL,
+		},
+	}
+	mockHTTPClientDo = func(r *http.Request) (*http.Response, error) {
+		// The consumer's cache directive should be forwarded to every upstream request.
+		assert.Equal(t, "no-cache", r.Header.Get("Cache-Control"))
+		return &http.Response{
+			StatusCode: 404,
+			Body:       ioutil.NopCloser(strings.NewReader("")),
+		}, nil
+	}
+
+	getUserPostsByUserId(c)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.False(t, userPostServiceImpl.Source.(typicodeClient).BypassCache)
+}
+
+func TestGetUserPostsByUserIdCircuitOpen503(t *testing.T) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Params = []gin.Param{{Key: "userId", Value: fmt.Sprint(userId)}}
+	c.Request = httptest.NewRequest(http.MethodGet, fmt.Sprint("/v1/user-posts/", userId), nil)
+
+	userPostServiceImpl = userPostService{
+		Source: typicodeClient{
+			Client:  &mockHTTPClient{},
+			BaseUrl: mockBaseURL,
+		},
+	}
+	mockHTTPClientDo = func(r *http.Request) (*http.Response, error) {
+		return nil, &circuitOpenError{Endpoint: "users", RetryAfter: 2500 * time.Millisecond}
+	}
+
+	getUserPostsByUserId(c)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "3", w.Header().Get("Retry-After"))
+	assert.Contains(t, w.Body.String(), "Circuit breaker for Cool Vendor's 'users' endpoint is open")
+}
+
+func TestGetUserPostsByUserIdDeadlineExceeded504(t *testing.T) {
+	userPostServiceImpl = userPostService{
+		Source: typicodeClient{
+			Client:  &mockHTTPClient{},
+			BaseUrl: mockBaseURL,
+		},
+	}
+	mockHTTPClientDo = func(r *http.Request) (*http.Response, error) {
+		// Simulate Cool Vendor hanging until our deadline passes.
+		<-r.Context().Done()
+		return nil, r.Context().Err()
+	}
+	defaultRequestTimeout := requestTimeout
+	requestTimeout = 10 * time.Millisecond
+	defer func() { requestTimeout = defaultRequestTimeout }()
+
+	w := httptest.NewRecorder()
+	setupRouter().ServeHTTP(w, httptest.NewRequest(http.MethodGet, fmt.Sprint("/v1/user-posts/", userId), nil))
+
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+	assert.Contains(t, w.Body.String(), "\"code\": \"timeout\"")
+}
+
+func TestGetUserPostsByUserIdClientCancelled(t *testing.T) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Params = []gin.Param{{Key: "userId", Value: fmt.Sprint(userId)}}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c.Request = httptest.NewRequest(http.MethodGet, fmt.Sprint("/v1/user-posts/", userId), nil).WithContext(ctx)
+
+	userPostServiceImpl = userPostService{
+		Source: typicodeClient{
+			Client:  &mockHTTPClient{},
+			BaseUrl: mockBaseURL,
+		},
+	}
+	mockHTTPClientDo = func(r *http.Request) (*http.Response, error) {
+		return nil, r.Context().Err()
+	}
+
+	getUserPostsByUserId(c)
+
+	assert.Equal(t, 499, w.Code)
+	assert.Empty(t, w.Body.String())
+}
+
+// Controller - getUserById
+
+func TestGetUserByIdSuccess(t *testing.T) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Params = []gin.Param{{Key: "userId", Value: fmt.Sprint(userId)}}
+	c.Request = httptest.NewRequest(http.MethodGet, fmt.Sprint("/v1/users/", userId), nil)
+
+	userPostServiceImpl = userPostService{
+		Source: typicodeClient{
+			Client:  &mockHTTPClient{},
+			BaseUrl: mockBaseURL,
+		},
+	}
+	mockHTTPClientDo = func(r *http.Request) (*http.Response, error) {
+		assert.Equal(t, r.URL.String(), fmt.Sprint(mockBaseURL, "/users/", userId))
+		userBytes, userBytesErr := json.Marshal(testUser)
+		assert.Nil(t, userBytesErr)
+		return &http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(bytes.NewReader(userBytes)),
+		}, nil
+	}
+
+	getUserById(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var userResp user
+	userRespErr := json.NewDecoder(w.Body).Decode(&userResp)
+	assert.Nil(t, userRespErr)
+	assert.Equal(t, testUser, userResp)
+}
+
+func TestGetUserByIdFieldProjection(t *testing.T) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Params = []gin.Param{{Key: "userId", Value: fmt.Sprint(userId)}}
+	c.Request = httptest.NewRequest(http.MethodGet, fmt.Sprint("/v1/users/", userId, "?fields=name,username,email"), nil)
+
+	userPostServiceImpl = userPostService{
+		Source: typicodeClient{
+			Client:  &mockHTTPClient{},
+			BaseUrl: mockBaseURL,
+		},
+	}
+	mockHTTPClientDo = func(r *http.Request) (*http.Response, error) {
+		userBytes, userBytesErr := json.Marshal(testUser)
+		assert.Nil(t, userBytesErr)
+		return &http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(bytes.NewReader(userBytes)),
+		}, nil
+	}
+
+	getUserById(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var userInfoResp map[string]interface{}
+	userInfoRespErr := json.NewDecoder(w.Body).Decode(&userInfoResp)
+	assert.Nil(t, userInfoRespErr)
+	assert.Equal(t, map[string]interface{}{
+		"name":     testUserPosts.UserInfo.Name,
+		"username": testUserPosts.UserInfo.Username,
+		"email":    testUserPosts.UserInfo.Email,
+	}, userInfoResp)
+}
+
+func TestGetUserByIdUnsupportedField400(t *testing.T) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Params = []gin.Param{{Key: "userId", Value: fmt.Sprint(userId)}}
+	c.Request = httptest.NewRequest(http.MethodGet, fmt.Sprint("/v1/users/", userId, "?fields=name,password"), nil)
+
+	getUserById(c)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "Unsupported field 'password'")
+}
+
+func TestGetUserById404(t *testing.T) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Params = []gin.Param{{Key: "userId", Value: fmt.Sprint(userId)}}
+	c.Request = httptest.NewRequest(http.MethodGet, fmt.Sprint("/v1/users/", userId), nil)
+
+	userPostServiceImpl = userPostService{
+		Source: typicodeClient{
+			Client:  &mockHTTPClient{},
+			BaseUrl: mockBaseURL,
+		},
+	}
+	mockHTTPClientDo = func(r *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: 404,
+			Body:       ioutil.NopCloser(strings.NewReader("")),
+		}, nil
+	}
+
+	getUserById(c)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assertProblem(t, w, "not_found", fmt.Sprint("Could not find userId=", userId))
 }
 
 // userPostService.getUserPostsByUserId
 
 func TestUserPostServiceGetUserPostsByIdSuccess(t *testing.T) {
 	userPostService := userPostService{
-		TypicodeClient: typicodeClient{
+		Source: typicodeClient{
 			Client:  &mockHTTPClient{},
 			BaseUrl: mockBaseURL,
 		},
@@ -165,7 +384,7 @@ func TestUserPostServiceGetUserPostsByIdSuccess(t *testing.T) {
 		}
 	}
 
-	resp, respErr := userPostService.getUserPostsByUserId(userId)
+	resp, respErr := userPostService.getUserPostsByUserId(context.Background(), userId, userPostsOptions{})
 	assert.NotNil(t, resp)
 	assert.Nil(t, respErr)
 	assert.Equal(t, testUserPosts, resp)
@@ -173,7 +392,7 @@ func TestUserPostServiceGetUserPostsByIdSuccess(t *testing.T) {
 
 func TestUserPostServiceGetUserPostsByIdNoUserData(t *testing.T) {
 	userPostService := userPostService{
-		TypicodeClient: typicodeClient{
+		Source: typicodeClient{
 			Client:  &mockHTTPClient{},
 			BaseUrl: mockBaseURL,
 		},
@@ -192,14 +411,15 @@ func TestUserPostServiceGetUserPostsByIdNoUserData(t *testing.T) {
 		}
 	}
 
-	resp, respErr := userPostService.getUserPostsByUserId(userId)
+	resp, respErr := userPostService.getUserPostsByUserId(context.Background(), userId, userPostsOptions{})
 	assert.Equal(t, resp, userPosts{})
-	assert.Nil(t, respErr)
+	var notFoundErr *notFoundError
+	assert.True(t, errors.As(respErr, &notFoundErr))
 }
 
 func TestUserPostServiceGetUserPostsByIdUserError(t *testing.T) {
 	userPostService := userPostService{
-		TypicodeClient: typicodeClient{
+		Source: typicodeClient{
 			Client:  &mockHTTPClient{},
 			BaseUrl: mockBaseURL,
 		},
@@ -218,7 +438,7 @@ func TestUserPostServiceGetUserPostsByIdUserError(t *testing.T) {
 		}
 	}
 
-	resp, respErr := userPostService.getUserPostsByUserId(userId)
+	resp, respErr := userPostService.getUserPostsByUserId(context.Background(), userId, userPostsOptions{})
 	assert.Equal(t, resp, userPosts{})
 	assert.NotNil(t, respErr)
 	// We don't care about asserting the error value because that's tested in their typiscodeClient specs below instead.
@@ -226,7 +446,7 @@ func TestUserPostServiceGetUserPostsByIdUserError(t *testing.T) {
 
 func TestUserPostServiceGetUserPostsByIdPostsError(t *testing.T) {
 	userPostService := userPostService{
-		TypicodeClient: typicodeClient{
+		Source: typicodeClient{
 			Client:  &mockHTTPClient{},
 			BaseUrl: mockBaseURL,
 		},
@@ -247,12 +467,165 @@ func TestUserPostServiceGetUserPostsByIdPostsError(t *testing.T) {
 		}
 	}
 
-	resp, respErr := userPostService.getUserPostsByUserId(userId)
+	resp, respErr := userPostService.getUserPostsByUserId(context.Background(), userId, userPostsOptions{})
 	assert.Equal(t, resp, userPosts{})
 	assert.NotNil(t, respErr)
 	// We don't care about asserting the error value because that's tested in their typiscodeClient specs below instead.
 }
 
+func TestUserPostServiceGetUserPostsByIdIncludeComments(t *testing.T) {
+	userPostService := userPostService{
+		Source: typicodeClient{
+			Client:  &mockHTTPClient{},
+			BaseUrl: mockBaseURL,
+		},
+	}
+	mockHTTPClientDo = func(r *http.Request) (*http.Response, error) {
+		var body interface{}
+		switch r.URL.Path {
+		case fmt.Sprint("/users/", userId):
+			body = testUser
+		case "/posts":
+			body = posts
+		case "/comments":
+			assert.Equal(t, fmt.Sprint(posts[0].ID), r.URL.Query().Get("postId"))
+			body = comments
+		}
+		bodyBytes, bodyBytesErr := json.Marshal(body)
+		assert.Nil(t, bodyBytesErr)
+		return &http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(bytes.NewReader(bodyBytes)),
+		}, nil
+	}
+
+	resp, respErr := userPostService.getUserPostsByUserId(context.Background(), userId, userPostsOptions{IncludeComments: true})
+	assert.Nil(t, respErr)
+	assert.Equal(t, &comments, resp.Posts[0].Comments)
+}
+
+func TestGetUserPostsByUserIdIncludeCommentsEmpty(t *testing.T) {
+	userPostServiceImpl = userPostService{
+		Source: typicodeClient{
+			Client:  &mockHTTPClient{},
+			BaseUrl: mockBaseURL,
+		},
+	}
+	mockHTTPClientDo = func(r *http.Request) (*http.Response, error) {
+		var body interface{}
+		switch r.URL.Path {
+		case fmt.Sprint("/users/", userId):
+			body = testUser
+		case "/posts":
+			body = posts
+		case "/comments":
+			body = []comment{}
+		}
+		bodyBytes, bodyBytesErr := json.Marshal(body)
+		assert.Nil(t, bodyBytesErr)
+		return &http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(bytes.NewReader(bodyBytes)),
+		}, nil
+	}
+
+	// A post without any comments should still say so, rather than look like comments weren't requested.
+	w := httptest.NewRecorder()
+	setupRouter().ServeHTTP(w, httptest.NewRequest(http.MethodGet, fmt.Sprint("/v1/user-posts/", userId, "?include=comments"), nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"comments": []`)
+
+	w = httptest.NewRecorder()
+	setupRouter().ServeHTTP(w, httptest.NewRequest(http.MethodGet, fmt.Sprint("/v1/user-posts/", userId), nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), `"comments"`)
+}
+
+func TestUserPostServiceGetUserPostsByIdCommentsError(t *testing.T) {
+	userPostService := userPostService{
+		Source: typicodeClient{
+			Client:  &mockHTTPClient{},
+			BaseUrl: mockBaseURL,
+		},
+	}
+	mockHTTPClientDo = func(r *http.Request) (*http.Response, error) {
+		var body interface{}
+		switch r.URL.Path {
+		case fmt.Sprint("/users/", userId):
+			body = testUser
+		case "/posts":
+			body = posts
+		default:
+			return &http.Response{
+				StatusCode: 500,
+				Body:       ioutil.NopCloser(strings.NewReader(errMsg500)),
+			}, nil
+		}
+		bodyBytes, bodyBytesErr := json.Marshal(body)
+		assert.Nil(t, bodyBytesErr)
+		return &http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(bytes.NewReader(bodyBytes)),
+		}, nil
+	}
+
+	resp, respErr := userPostService.getUserPostsByUserId(context.Background(), userId, userPostsOptions{IncludeComments: true})
+	assert.Equal(t, resp, userPosts{})
+	assert.NotNil(t, respErr)
+}
+
+func TestUserPostServiceGetUserPostsByIdPropagatesContext(t *testing.T) {
+	userPostService := userPostService{
+		Source: typicodeClient{
+			Client:  &mockHTTPClient{},
+			BaseUrl: mockBaseURL,
+		},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mockHTTPClientDo = func(r *http.Request) (*http.Response, error) {
+		// Every upstream request, including the comments fan-out, should carry the caller's context.
+		assert.Equal(t, ctx.Done(), r.Context().Done())
+		var body interface{}
+		switch r.URL.Path {
+		case fmt.Sprint("/users/", userId):
+			body = testUser
+		case "/posts":
+			body = posts
+		default:
+			body = comments
+		}
+		bodyBytes, bodyBytesErr := json.Marshal(body)
+		assert.Nil(t, bodyBytesErr)
+		return &http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(bytes.NewReader(bodyBytes)),
+		}, nil
+	}
+
+	_, respErr := userPostService.getUserPostsByUserId(ctx, userId, userPostsOptions{IncludeComments: true})
+	assert.Nil(t, respErr)
+}
+
+func TestUserPostServiceAttachCommentsCancelled(t *testing.T) {
+	userPostService := userPostService{
+		Source: typicodeClient{
+			Client:  &mockHTTPClient{},
+			BaseUrl: mockBaseURL,
+		},
+	}
+	doCount := 0
+	mockHTTPClientDo = func(r *http.Request) (*http.Response, error) {
+		doCount++
+		return nil, r.Context().Err()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	respErr := userPostService.attachComments(ctx, make([]postSummary, 3*maxConcurrentCommentFetches))
+	assert.True(t, errors.Is(respErr, context.Canceled))
+}
+
 // typicodeClient.getUserById
 
 func TestTypicodeClientGetUserByIdSuccess(t *testing.T) {
@@ -270,7 +643,7 @@ func TestTypicodeClientGetUserByIdSuccess(t *testing.T) {
 		}, nil
 	}
 
-	resp, respErr := typicodeClient.getUserById(userId)
+	resp, respErr := typicodeClient.getUserById(context.Background(), userId)
 	assert.NotNil(t, resp)
 	assert.Nil(t, respErr)
 	assert.Equal(t, testUser, resp)
@@ -282,7 +655,7 @@ func TestTypicodeClientGetUserByIdNewRequestErr(t *testing.T) {
 		BaseUrl: "   %#%badURL",
 	}
 
-	resp, respErr := typicodeClient.getUserById(userId)
+	resp, respErr := typicodeClient.getUserById(context.Background(), userId)
 	assert.Equal(t, resp, user{})
 	assert.NotNil(t, respErr)
 	assert.Contains(t, respErr.Error(), "Unexpected error creating client request for Cool Vendor's Get User API: error=")
@@ -297,7 +670,7 @@ func TestTypicodeClientGetUserByIdResponseExecutionErr(t *testing.T) {
 		return nil, errFoo
 	}
 
-	resp, respErr := typicodeClient.getUserById(userId)
+	resp, respErr := typicodeClient.getUserById(context.Background(), userId)
 	assert.Equal(t, resp, user{})
 	assert.NotNil(t, respErr)
 	assert.Equal(t, respErr.Error(), fmt.Sprint("Unexpected communication or client policy error occurred trying to fetch userId=", userId, " from Cool Vendor: ", errFoo.Error()))
@@ -315,9 +688,11 @@ func TestTypicodeClientGetUserById404(t *testing.T) {
 		}, nil
 	}
 
-	resp, respErr := typicodeClient.getUserById(userId)
+	resp, respErr := typicodeClient.getUserById(context.Background(), userId)
 	assert.Equal(t, resp, user{})
-	assert.Nil(t, respErr)
+	var notFoundErr *notFoundError
+	assert.True(t, errors.As(respErr, &notFoundErr))
+	assert.Equal(t, fmt.Sprint("Could not find userId=", userId), respErr.Error())
 }
 
 func TestTypicodeClientGetUserByIdBadJson(t *testing.T) {
@@ -332,10 +707,12 @@ func TestTypicodeClientGetUserByIdBadJson(t *testing.T) {
 		}, nil
 	}
 
-	resp, respErr := typicodeClient.getUserById(userId)
+	resp, respErr := typicodeClient.getUserById(context.Background(), userId)
 	assert.Equal(t, resp, user{})
 	assert.NotNil(t, respErr)
 	assert.Contains(t, respErr.Error(), "Unable to parse response body as 'user' JSON for Cool Vendor's Get User By ID API: error=")
+	var badResponseErr *upstreamBadResponseError
+	assert.True(t, errors.As(respErr, &badResponseErr))
 }
 
 func TestTypicodeClientGetUserById500BadResponseBody(t *testing.T) {
@@ -351,7 +728,7 @@ func TestTypicodeClientGetUserById500BadResponseBody(t *testing.T) {
 		}, nil
 	}
 
-	resp, respErr := typicodeClient.getUserById(userId)
+	resp, respErr := typicodeClient.getUserById(context.Background(), userId)
 	assert.Equal(t, resp, user{})
 	assert.NotNil(t, respErr)
 	assert.Equal(t, respErr.Error(), fmt.Sprint("Unexpected error trying to read response body for server error trying to fetch userId=", userId, " from Cool Vendor: error=", badReadCloserErrMsg))
@@ -370,10 +747,48 @@ func TestTypicodeClientGetUserById500(t *testing.T) {
 		}, nil
 	}
 
-	resp, respErr := typicodeClient.getUserById(userId)
+	resp, respErr := typicodeClient.getUserById(context.Background(), userId)
 	assert.Equal(t, resp, user{})
 	assert.NotNil(t, respErr)
-	assert.Equal(t, respErr.Error(), fmt.Sprint("Unexpected server error occurred trying to fetch userId=", userId, " from Cool Vendor: ", errMsg500))
+	assert.Equal(t, respErr.Error(), fmt.Sprint("Unexpected server error occurred trying to fetch userId=", userId, " from Cool Vendor: status=500"))
+	var unavailableErr *upstreamUnavailableError
+	assert.True(t, errors.As(respErr, &unavailableErr))
+	assert.Equal(t, 500, unavailableErr.StatusCode)
+}
+
+func TestTypicodeClientGetUserByIdUnexpectedStatus(t *testing.T) {
+	typicodeClient := typicodeClient{
+		Client:  &mockHTTPClient{},
+		BaseUrl: mockBaseURL,
+	}
+
+	mockHTTPClientDo = func(*http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: 400,
+			Body:       ioutil.NopCloser(strings.NewReader("{}")),
+		}, nil
+	}
+
+	_, respErr := typicodeClient.getUserById(context.Background(), userId)
+	var badResponseErr *upstreamBadResponseError
+	assert.True(t, errors.As(respErr, &badResponseErr))
+	assert.Equal(t, 400, badResponseErr.StatusCode)
+}
+
+func TestTypicodeClientGetUserByIdTimeout(t *testing.T) {
+	typicodeClient := typicodeClient{
+		Client:  &mockHTTPClient{},
+		BaseUrl: mockBaseURL,
+	}
+
+	mockHTTPClientDo = func(*http.Request) (*http.Response, error) {
+		return nil, context.DeadlineExceeded
+	}
+
+	_, respErr := typicodeClient.getUserById(context.Background(), userId)
+	var timeoutErr *timeoutError
+	assert.True(t, errors.As(respErr, &timeoutErr))
+	assert.True(t, errors.Is(respErr, context.DeadlineExceeded))
 }
 
 // typicodeClient.getPostsByUserId
@@ -393,7 +808,7 @@ func TestTypicodeClientGetPostsByUserIdSuccess(t *testing.T) {
 		}, nil
 	}
 
-	resp, respErr := typicodeClient.getPostsByUserId(userId)
+	resp, respErr := typicodeClient.getPostsByUserId(context.Background(), userId)
 	assert.NotNil(t, resp)
 	assert.Nil(t, respErr)
 	assert.Equal(t, posts, resp)
@@ -405,7 +820,7 @@ func TestTypicodeClientGetPostsByUserIdNewRequestErr(t *testing.T) {
 		BaseUrl: "   %#%badURL",
 	}
 
-	resp, respErr := typicodeClient.getPostsByUserId(userId)
+	resp, respErr := typicodeClient.getPostsByUserId(context.Background(), userId)
 	assert.Equal(t, resp, []postSummary{})
 	assert.NotNil(t, respErr)
 	assert.Contains(t, respErr.Error(), "Unexpected error creating client request for Cool Vendor's Get Posts API: error=")
@@ -420,7 +835,7 @@ func TestTypicodeClientGetPostsByUserIdResponseExecutionErr(t *testing.T) {
 		return nil, errFoo
 	}
 
-	resp, respErr := typicodeClient.getPostsByUserId(userId)
+	resp, respErr := typicodeClient.getPostsByUserId(context.Background(), userId)
 	assert.Equal(t, resp, []postSummary{})
 	assert.NotNil(t, respErr)
 	assert.Equal(t, respErr.Error(), fmt.Sprint("Unexpected communication or client policy error occurred trying to fetch posts for userId=", userId, " from Cool Vendor: ", errFoo.Error()))
@@ -438,7 +853,7 @@ func TestTypicodeClientGetPostsByUserIdBadJson(t *testing.T) {
 		}, nil
 	}
 
-	resp, respErr := typicodeClient.getPostsByUserId(userId)
+	resp, respErr := typicodeClient.getPostsByUserId(context.Background(), userId)
 	assert.Equal(t, resp, []postSummary{})
 	assert.NotNil(t, respErr)
 	assert.Contains(t, respErr.Error(), "Unable to parse response body as '[]postSummary' JSON for Cool Vendor's Get Posts API: error=")
@@ -457,7 +872,7 @@ func TestTypicodeClientGetPostsByUserId500BadResponseBody(t *testing.T) {
 		}, nil
 	}
 
-	resp, respErr := typicodeClient.getPostsByUserId(userId)
+	resp, respErr := typicodeClient.getPostsByUserId(context.Background(), userId)
 	assert.Equal(t, resp, []postSummary{})
 	assert.NotNil(t, respErr)
 	assert.Equal(t, respErr.Error(), fmt.Sprint("Unexpected error trying to read response body for server error trying to fetch posts for userId=", userId, " from Cool Vendor: error=", badReadCloserErrMsg))
@@ -476,10 +891,68 @@ func TestTypicodeClientGetPostsByUserId500(t *testing.T) {
 		}, nil
 	}
 
-	resp, respErr := typicodeClient.getPostsByUserId(userId)
+	resp, respErr := typicodeClient.getPostsByUserId(context.Background(), userId)
 	assert.Equal(t, resp, []postSummary{})
 	assert.NotNil(t, respErr)
-	assert.Equal(t, respErr.Error(), fmt.Sprint("Unexpected server error occurred trying to fetch posts for userId=", userId, " from Cool Vendor: ", errMsg500))
+	assert.Equal(t, respErr.Error(), fmt.Sprint("Unexpected server error occurred trying to fetch posts for userId=", userId, " from Cool Vendor: status=500"))
+}
+
+// typicodeClient.getCommentsByPostId
+
+func TestTypicodeClientGetCommentsByPostIdSuccess(t *testing.T) {
+	typicodeClient := typicodeClient{
+		Client:  &mockHTTPClient{},
+		BaseUrl: mockBaseURL,
+	}
+	mockHTTPClientDo = func(r *http.Request) (*http.Response, error) {
+		assert.Equal(t, r.URL.String(), fmt.Sprint(mockBaseURL, "/comments?postId=", posts[0].ID))
+		commentsBytes, commentsBytesErr := json.Marshal(comments)
+		assert.Nil(t, commentsBytesErr)
+		return &http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(bytes.NewReader(commentsBytes)),
+		}, nil
+	}
+
+	resp, respErr := typicodeClient.getCommentsByPostId(context.Background(), posts[0].ID)
+	assert.Nil(t, respErr)
+	assert.Equal(t, comments, resp)
+}
+
+func TestTypicodeClientGetCommentsByPostIdBadJson(t *testing.T) {
+	typicodeClient := typicodeClient{
+		Client:  &mockHTTPClient{},
+		BaseUrl: mockBaseURL,
+	}
+	mockHTTPClientDo = func(*http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(strings.NewReader(`{"id":"im-a-string"}`)),
+		}, nil
+	}
+
+	resp, respErr := typicodeClient.getCommentsByPostId(context.Background(), posts[0].ID)
+	assert.Equal(t, resp, []comment{})
+	assert.NotNil(t, respErr)
+	assert.Contains(t, respErr.Error(), "Unable to parse response body as '[]comment' JSON for Cool Vendor's Get Comments API: error=")
+}
+
+func TestTypicodeClientGetCommentsByPostId500(t *testing.T) {
+	typicodeClient := typicodeClient{
+		Client:  &mockHTTPClient{},
+		BaseUrl: mockBaseURL,
+	}
+	mockHTTPClientDo = func(*http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: 500,
+			Body:       ioutil.NopCloser(strings.NewReader(errMsg500)),
+		}, nil
+	}
+
+	resp, respErr := typicodeClient.getCommentsByPostId(context.Background(), posts[0].ID)
+	assert.Equal(t, resp, []comment{})
+	assert.NotNil(t, respErr)
+	assert.Equal(t, respErr.Error(), fmt.Sprint("Unexpected server error occurred trying to fetch comments for postId=", posts[0].ID, " from Cool Vendor: status=500"))
 }
 
 // Test Helpers
@@ -515,10 +988,27 @@ var testUser = user{
 	Name:     "Chacha",
 	Username: "chacha22",
 	Email:    "chacha22@gmail.com",
+	Address: address{
+		Street:  "Kulas Light",
+		Suite:   "Apt. 556",
+		City:    "Gwenborough",
+		Zipcode: "92998-3874",
+		Geo:     geo{Lat: "-37.3159", Lng: "81.1496"},
+	},
+	Phone:   "1-770-736-8031 x56442",
+	Website: "hildegard.org",
+	Company: company{
+		Name:        "Romaguera-Crona",
+		CatchPhrase: "Multi-layered client-server neural-net",
+		Bs:          "harness real-time e-markets",
+	},
 }
 var posts = []postSummary{
 	{ID: 42, Title: "How to Adult", Body: "N/A"},
 }
+var comments = []comment{
+	{ID: 7, Name: "re: How to Adult", Email: "lurker@gmail.com", Body: "First!"},
+}
 var testUserPosts = userPosts{
 	ID: testUser.ID,
 	UserInfo: userInfo{
@@ -0,0 +1,675 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// Mirror
+//
+// A local copy of Cool Vendor's users and posts, so that the service keeps answering while Cool Vendor is
+// unreachable, and so that we're free to query them in ways Cool Vendor doesn't support. A sync job pulls every user
+// and post from Cool Vendor on a schedule, inserts or updates whatever changed, and deletes whatever Cool Vendor no
+// longer has. Anything that isn't mirrored, such as comments, still comes straight from Cool Vendor.
+//
+// The mirror is an embedded SQLite database, with a "users" and a "posts" table keyed by Cool Vendor's IDs, and a
+// single row in "mirror_state" for when it was last synced. SQLite comes from modernc.org/sqlite, which is pure Go, so
+// the service still builds without cgo. Every sync is a single transaction, so a sync that fails partway through
+// never leaves a half-synced mirror behind.
+
+const mirrorSchema = `
+CREATE TABLE IF NOT EXISTS users (
+	id       INTEGER PRIMARY KEY,
+	name     TEXT NOT NULL,
+	username TEXT NOT NULL,
+	email    TEXT NOT NULL,
+	address  TEXT NOT NULL, -- JSON, same as Cool Vendor's
+	phone    TEXT NOT NULL,
+	website  TEXT NOT NULL,
+	company  TEXT NOT NULL  -- JSON, same as Cool Vendor's
+);
+
+CREATE TABLE IF NOT EXISTS posts (
+	id      INTEGER PRIMARY KEY,
+	user_id INTEGER NOT NULL,
+	title   TEXT NOT NULL,
+	body    TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS posts_user_id ON posts (user_id);
+
+CREATE TABLE IF NOT EXISTS mirror_state (
+	id             INTEGER PRIMARY KEY CHECK (id = 1),
+	last_synced_at TEXT NOT NULL
+);
+`
+
+const mirrorUserColumns = "id, name, username, email, address, phone, website, company"
+const mirrorPostColumns = "id, user_id, title, body"
+
+// The mirrored users and posts, along with when they were last synced from Cool Vendor.
+type mirrorStore struct {
+	Path string
+
+	db *sql.DB
+
+	// Syncs are serialized so that they can't overwrite each other's changes.
+	syncMutex sync.Mutex
+
+	// As of the last sync, so that diagnostics don't need to hit the database.
+	mutex        sync.RWMutex
+	lastSyncedAt time.Time
+	rows         mirrorRowCounts
+}
+
+// Open the mirror at path. A mirror that doesn't exist yet is created empty, and stays that way until it's synced for
+// the first time.
+func openMirrorStore(path string) (*mirrorStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("Unable to create directory for mirror '%s': error=%w", path, err)
+	}
+	// Reads shouldn't wait on a sync, and shouldn't fail outright if one does have the database locked.
+	db, err := sql.Open("sqlite", path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(wal)")
+	if err != nil {
+		return nil, fmt.Errorf("Unable to open mirror '%s': error=%w", path, err)
+	}
+	store := &mirrorStore{Path: path, db: db}
+	if err := store.load(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+func (store *mirrorStore) load() error {
+	if _, err := store.db.Exec(mirrorSchema); err != nil {
+		return fmt.Errorf("Unable to open mirror '%s': error=%w", store.Path, err)
+	}
+
+	var lastSyncedAt string
+	err := store.db.QueryRow("SELECT last_synced_at FROM mirror_state").Scan(&lastSyncedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("Unable to read mirror '%s': error=%w", store.Path, err)
+	}
+	store.lastSyncedAt, err = time.Parse(time.RFC3339Nano, lastSyncedAt)
+	if err != nil {
+		return fmt.Errorf("Unable to parse mirror '%s': error=%w", store.Path, err)
+	}
+	store.rows, err = countMirrored(context.Background(), store.db)
+	if err != nil {
+		return fmt.Errorf("Unable to read mirror '%s': error=%w", store.Path, err)
+	}
+	return nil
+}
+
+func (store *mirrorStore) Close() error {
+	return store.db.Close()
+}
+
+// An error if the mirror has never been synced and so has nothing to serve.
+func (store *mirrorStore) checkSynced() error {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	if store.lastSyncedAt.IsZero() {
+		return &upstreamUnavailableError{domainErrorDetails: domainErrorDetails{
+			Message: "The local mirror of Cool Vendor's data hasn't been synced yet",
+		}}
+	}
+	return nil
+}
+
+func (store *mirrorStore) readError(err error) error {
+	return fmt.Errorf("Unable to read mirror '%s': error=%w", store.Path, err)
+}
+
+// Upsert every given user and post, and delete any that are no longer there, all in one transaction so that nothing
+// changes at all if any of it fails.
+func (store *mirrorStore) apply(ctx context.Context, users []user, posts []post, syncedAt time.Time) (mirrorChanges, error) {
+	store.syncMutex.Lock()
+	defer store.syncMutex.Unlock()
+
+	// Only syncs change the row counts, so they can't change under us while syncMutex is held.
+	store.mutex.RLock()
+	mirrored := store.rows
+	store.mutex.RUnlock()
+	if len(users) == 0 {
+		return mirrorChanges{}, fmt.Errorf("Refusing to sync mirror '%s' since Cool Vendor returned no users", store.Path)
+	}
+	if err := checkMirrorShrink(store.Path, "users", mirrored.Users, len(users)); err != nil {
+		return mirrorChanges{}, err
+	}
+	if err := checkMirrorShrink(store.Path, "posts", mirrored.Posts, len(posts)); err != nil {
+		return mirrorChanges{}, err
+	}
+
+	tx, err := store.db.BeginTx(ctx, nil)
+	if err != nil {
+		return mirrorChanges{}, fmt.Errorf("Unable to write mirror '%s': error=%w", store.Path, err)
+	}
+	defer tx.Rollback()
+
+	var changes mirrorChanges
+	changes.Users, err = upsertMirrored(ctx, tx, "users", mirrorUserColumns, users, func(user user) (int, []interface{}, error) {
+		address, err := json.Marshal(user.Address)
+		if err != nil {
+			return 0, nil, err
+		}
+		company, err := json.Marshal(user.Company)
+		if err != nil {
+			return 0, nil, err
+		}
+		return user.ID, []interface{}{user.ID, user.Name, user.Username, user.Email, string(address), user.Phone, user.Website, string(company)}, nil
+	})
+	if err != nil {
+		return mirrorChanges{}, fmt.Errorf("Unable to write users to mirror '%s': error=%w", store.Path, err)
+	}
+	changes.Posts, err = upsertMirrored(ctx, tx, "posts", mirrorPostColumns, posts, func(post post) (int, []interface{}, error) {
+		return post.ID, []interface{}{post.ID, post.UserID, post.Title, post.Body}, nil
+	})
+	if err != nil {
+		return mirrorChanges{}, fmt.Errorf("Unable to write posts to mirror '%s': error=%w", store.Path, err)
+	}
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO mirror_state (id, last_synced_at) VALUES (1, ?) "+
+		"ON CONFLICT (id) DO UPDATE SET last_synced_at = excluded.last_synced_at", syncedAt.UTC().Format(time.RFC3339Nano))
+	if err != nil {
+		return mirrorChanges{}, fmt.Errorf("Unable to write mirror '%s': error=%w", store.Path, err)
+	}
+	rows, err := countMirrored(ctx, tx)
+	if err != nil {
+		return mirrorChanges{}, fmt.Errorf("Unable to read mirror '%s': error=%w", store.Path, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return mirrorChanges{}, fmt.Errorf("Unable to write mirror '%s': error=%w", store.Path, err)
+	}
+
+	store.mutex.Lock()
+	store.lastSyncedAt = syncedAt
+	store.rows = rows
+	store.mutex.Unlock()
+	return changes, nil
+}
+
+// Most of a resource's mirrored rows that a single sync can delete. Cool Vendor losing that much at once is far more
+// likely to be a bad response than real deletions, and serving a mirror that was just wiped helps nobody.
+const mirrorMaxShrink = 0.5
+
+// An error if syncing latest rows would shrink the mirrored rows of resource by more than mirrorMaxShrink.
+func checkMirrorShrink(path string, resource string, mirrored int, latest int) error {
+	if float64(mirrored-latest) > float64(mirrored)*mirrorMaxShrink {
+		return fmt.Errorf("Refusing to sync mirror '%s' since Cool Vendor returned only %d of the %d mirrored %s",
+			path, latest, mirrored, resource)
+	}
+	return nil
+}
+
+// Upsert every row in latest into table by ID, and delete any rows that aren't in latest. columns must start with
+// "id", and row must return a value for each of them in the same order.
+//
+// Rows that haven't changed are left alone rather than rewritten, which is also how they're told apart from updated
+// ones: an upsert whose WHERE clause doesn't match affects no rows.
+func upsertMirrored[T any](ctx context.Context, tx *sql.Tx, table string, columns string, latest []T, row func(T) (int, []interface{}, error)) (mirrorRowChanges, error) {
+	existingIds, err := selectMirroredIds(ctx, tx, table)
+	if err != nil {
+		return mirrorRowChanges{}, err
+	}
+
+	names := strings.Split(columns, ", ")
+	var updates, current, excluded []string
+	for _, name := range names[1:] {
+		updates = append(updates, name+" = excluded."+name)
+		current = append(current, table+"."+name)
+		excluded = append(excluded, "excluded."+name)
+	}
+	upsert, err := tx.PrepareContext(ctx, fmt.Sprintf(
+		"INSERT INTO %s (%s) VALUES (%s) ON CONFLICT (id) DO UPDATE SET %s WHERE (%s) IS NOT (%s)",
+		table, columns, placeholders(len(names)), strings.Join(updates, ", "), strings.Join(current, ", "), strings.Join(excluded, ", "),
+	))
+	if err != nil {
+		return mirrorRowChanges{}, err
+	}
+	defer upsert.Close()
+
+	changes := mirrorRowChanges{}
+	latestIds := map[int]bool{}
+	for _, latestRow := range latest {
+		rowId, values, err := row(latestRow)
+		if err != nil {
+			return mirrorRowChanges{}, err
+		}
+		latestIds[rowId] = true
+		result, err := upsert.ExecContext(ctx, values...)
+		if err != nil {
+			return mirrorRowChanges{}, err
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return mirrorRowChanges{}, err
+		}
+		switch {
+		case affected == 0:
+		case existingIds[rowId]:
+			changes.Updated++
+		default:
+			changes.Inserted++
+		}
+	}
+
+	for rowId := range existingIds {
+		if latestIds[rowId] {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM "+table+" WHERE id = ?", rowId); err != nil {
+			return mirrorRowChanges{}, err
+		}
+		changes.Deleted++
+	}
+	return changes, nil
+}
+
+func selectMirroredIds(ctx context.Context, tx *sql.Tx, table string) (map[int]bool, error) {
+	rows, err := tx.QueryContext(ctx, "SELECT id FROM "+table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	ids := map[int]bool{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids[id] = true
+	}
+	return ids, rows.Err()
+}
+
+// Either the database or a transaction on it.
+type mirrorQueryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func countMirrored(ctx context.Context, queryer mirrorQueryer) (mirrorRowCounts, error) {
+	var counts mirrorRowCounts
+	err := queryer.QueryRowContext(ctx, "SELECT (SELECT COUNT(*) FROM users), (SELECT COUNT(*) FROM posts)").Scan(&counts.Users, &counts.Posts)
+	return counts, err
+}
+
+// Most IDs bound to a single "IN (...)" query. SQLite caps how many variables a statement can have, as low as 999 on
+// older builds, so bigger lookups are split across several queries.
+const mirrorMaxIdsPerQuery = 500
+
+// Split ids into chunks of at most mirrorMaxIdsPerQuery, each small enough for a single query. Duplicates are dropped
+// so that no ID can turn up in more than one chunk.
+func chunkMirrorIds(ids []int) [][]int {
+	seen := map[int]bool{}
+	uniqueIds := make([]int, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			uniqueIds = append(uniqueIds, id)
+		}
+	}
+	ids = uniqueIds
+
+	chunks := [][]int{}
+	for len(ids) > mirrorMaxIdsPerQuery {
+		chunks = append(chunks, ids[:mirrorMaxIdsPerQuery])
+		ids = ids[mirrorMaxIdsPerQuery:]
+	}
+	if len(ids) > 0 {
+		chunks = append(chunks, ids)
+	}
+	return chunks
+}
+
+// "?, ?, ..." with n placeholders.
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// What a single sync changed, per mirrored resource.
+type mirrorChanges struct {
+	Users mirrorRowChanges `json:"users"`
+	Posts mirrorRowChanges `json:"posts"`
+}
+
+type mirrorRowChanges struct {
+	Inserted int `json:"inserted"`
+	Updated  int `json:"updated"`
+	Deleted  int `json:"deleted"`
+}
+
+// Mirror - mirrorSyncer
+//
+// Keeps a mirrorStore up to date with Cool Vendor.
+
+type mirrorSyncOptions struct {
+	// How often the mirror is synced.
+	Interval time.Duration
+
+	// Longest a single sync can take. Zero means no limit.
+	Timeout time.Duration
+}
+
+// Point-in-time view of the mirror for diagnostics.
+type mirrorStats struct {
+	// When the mirror was last synced successfully, or null if it never has been.
+	LastSyncedAt *time.Time `json:"lastSyncedAt"`
+
+	// How many rows are mirrored right now, per resource.
+	Rows mirrorRowCounts `json:"rows"`
+
+	// When the last sync was attempted, what it changed if it succeeded, and why it failed if it didn't.
+	LastAttemptedAt *time.Time     `json:"lastAttemptedAt"`
+	LastChanges     *mirrorChanges `json:"lastChanges,omitempty"`
+	LastError       string         `json:"lastError,omitempty"`
+
+	Syncs        uint64 `json:"syncs"`
+	SyncFailures uint64 `json:"syncFailures"`
+}
+
+type mirrorRowCounts struct {
+	Users int `json:"users"`
+	Posts int `json:"posts"`
+}
+
+type mirrorSyncer struct {
+	// Should bypass any caching so that every sync gets the latest data.
+	Source  UserPostSource
+	Store   *mirrorStore
+	Options mirrorSyncOptions
+
+	// Overridable for unit tests.
+	now func() time.Time
+
+	mutex sync.Mutex
+	stats mirrorStats
+}
+
+func newMirrorSyncer(source UserPostSource, store *mirrorStore, options mirrorSyncOptions) *mirrorSyncer {
+	return &mirrorSyncer{
+		Source:  source,
+		Store:   store,
+		Options: options,
+		now:     time.Now,
+	}
+}
+
+// Fetch every user and post from Cool Vendor, and apply them to the mirror. Deletions can only be detected from a
+// complete picture of Cool Vendor's data, so the mirror is left untouched if either of them fails.
+func (syncer *mirrorSyncer) Sync(ctx context.Context) error {
+	if syncer.Options.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, syncer.Options.Timeout)
+		defer cancel()
+	}
+	attemptedAt := syncer.now()
+
+	var users []user
+	var usersErr error
+	var posts []post
+	var postsErr error
+
+	waitGroup := sync.WaitGroup{}
+	waitGroup.Add(1)
+	go func() {
+		users, usersErr = syncer.Source.getUsers(ctx)
+		waitGroup.Done()
+	}()
+	waitGroup.Add(1)
+	go func() {
+		posts, postsErr = syncer.Source.getPosts(ctx)
+		waitGroup.Done()
+	}()
+	waitGroup.Wait()
+
+	err := usersErr
+	if err == nil {
+		err = postsErr
+	}
+	var changes mirrorChanges
+	if err == nil {
+		changes, err = syncer.Store.apply(ctx, users, posts, attemptedAt)
+	}
+
+	syncer.mutex.Lock()
+	defer syncer.mutex.Unlock()
+	syncer.stats.LastAttemptedAt = &attemptedAt
+	if err != nil {
+		syncer.stats.SyncFailures++
+		syncer.stats.LastChanges = nil
+		syncer.stats.LastError = err.Error()
+		return err
+	}
+	syncer.stats.Syncs++
+	syncer.stats.LastChanges = &changes
+	syncer.stats.LastError = ""
+	return nil
+}
+
+// Sync the mirror right away, then keep it in sync until ctx is done.
+func (syncer *mirrorSyncer) Run(ctx context.Context) {
+	syncMirror := func() {
+		if err := syncer.Sync(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Unable to sync the mirror, still serving the previous sync: error=%s", err.Error())
+		}
+	}
+	syncMirror()
+
+	ticker := time.NewTicker(syncer.Options.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			syncMirror()
+		}
+	}
+}
+
+func (syncer *mirrorSyncer) Stats() mirrorStats {
+	syncer.mutex.Lock()
+	stats := syncer.stats
+	syncer.mutex.Unlock()
+
+	syncer.Store.mutex.RLock()
+	defer syncer.Store.mutex.RUnlock()
+	if !syncer.Store.lastSyncedAt.IsZero() {
+		lastSyncedAt := syncer.Store.lastSyncedAt
+		stats.LastSyncedAt = &lastSyncedAt
+	}
+	stats.Rows = syncer.Store.rows
+	return stats
+}
+
+// Mirror - mirrorSource
+//
+// Serves users and posts from the mirror, and everything else from Cool Vendor.
+
+var _ UserPostSource = mirrorSource{}
+var _ cacheBypassingSource = mirrorSource{}
+
+type mirrorSource struct {
+	// Anything that isn't mirrored, i.e. everything but users and posts.
+	UserPostSource
+
+	Store *mirrorStore
+}
+
+// The mirror is only as fresh as its last sync, so skipping it means reading straight through to Cool Vendor, past any
+// caching there too.
+func (source mirrorSource) bypassingCache() UserPostSource {
+	if cacheBypassingSource, ok := source.UserPostSource.(cacheBypassingSource); ok {
+		return cacheBypassingSource.bypassingCache()
+	}
+	return source.UserPostSource
+}
+
+func (source mirrorSource) getUserById(ctx context.Context, userId int) (user, error) {
+	if err := source.Store.checkSynced(); err != nil {
+		return user{}, err
+	}
+	row := source.Store.db.QueryRowContext(ctx, "SELECT "+mirrorUserColumns+" FROM users WHERE id = ?", userId)
+	mirroredUser, err := scanMirroredUser(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return user{}, &notFoundError{domainErrorDetails{Message: fmt.Sprint("Could not find userId=", userId)}}
+	}
+	if err != nil {
+		return user{}, source.Store.readError(err)
+	}
+	return mirroredUser, nil
+}
+
+func (source mirrorSource) getUsers(ctx context.Context) ([]user, error) {
+	if err := source.Store.checkSynced(); err != nil {
+		return []user{}, err
+	}
+	return source.queryUsers(ctx, "SELECT "+mirrorUserColumns+" FROM users ORDER BY id")
+}
+
+// Users that don't exist are just missing from the result, same as typicodeClient.getUsersByIds.
+func (source mirrorSource) getUsersByIds(ctx context.Context, userIds []int) (map[int]user, error) {
+	if err := source.Store.checkSynced(); err != nil {
+		return nil, err
+	}
+	usersById := map[int]user{}
+	for _, chunk := range chunkMirrorIds(userIds) {
+		users, err := source.queryUsers(ctx, "SELECT "+mirrorUserColumns+" FROM users WHERE id IN ("+placeholders(len(chunk))+")", mirrorArgs(chunk)...)
+		if err != nil {
+			return nil, err
+		}
+		for _, user := range users {
+			usersById[user.ID] = user
+		}
+	}
+	return usersById, nil
+}
+
+func (source mirrorSource) getPosts(ctx context.Context) ([]post, error) {
+	if err := source.Store.checkSynced(); err != nil {
+		return []post{}, err
+	}
+	return source.queryPosts(ctx, "SELECT "+mirrorPostColumns+" FROM posts ORDER BY id")
+}
+
+func (source mirrorSource) getPostsByUserId(ctx context.Context, userId int) ([]postSummary, error) {
+	if err := source.Store.checkSynced(); err != nil {
+		return []postSummary{}, err
+	}
+	posts, err := source.queryPosts(ctx, "SELECT "+mirrorPostColumns+" FROM posts WHERE user_id = ? ORDER BY id", userId)
+	if err != nil {
+		return []postSummary{}, err
+	}
+	postSummaries := []postSummary{}
+	for _, post := range posts {
+		postSummaries = append(postSummaries, post.postSummary)
+	}
+	return postSummaries, nil
+}
+
+// Every given user has an entry, even if they have no posts, same as typicodeClient.getPostsByUserIds.
+func (source mirrorSource) getPostsByUserIds(ctx context.Context, userIds []int) (map[int][]postSummary, error) {
+	if err := source.Store.checkSynced(); err != nil {
+		return nil, err
+	}
+	postsByUserId := map[int][]postSummary{}
+	for _, userId := range userIds {
+		postsByUserId[userId] = []postSummary{}
+	}
+	// Every user's posts come back from the same chunk, so they stay in order.
+	for _, chunk := range chunkMirrorIds(userIds) {
+		posts, err := source.queryPosts(ctx, "SELECT "+mirrorPostColumns+" FROM posts WHERE user_id IN ("+placeholders(len(chunk))+") ORDER BY id", mirrorArgs(chunk)...)
+		if err != nil {
+			return nil, err
+		}
+		for _, post := range posts {
+			postsByUserId[post.UserID] = append(postsByUserId[post.UserID], post.postSummary)
+		}
+	}
+	return postsByUserId, nil
+}
+
+func (source mirrorSource) queryUsers(ctx context.Context, query string, args ...interface{}) ([]user, error) {
+	rows, err := source.Store.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return []user{}, source.Store.readError(err)
+	}
+	defer rows.Close()
+	users := []user{}
+	for rows.Next() {
+		mirroredUser, err := scanMirroredUser(rows)
+		if err != nil {
+			return []user{}, source.Store.readError(err)
+		}
+		users = append(users, mirroredUser)
+	}
+	if err := rows.Err(); err != nil {
+		return []user{}, source.Store.readError(err)
+	}
+	return users, nil
+}
+
+func (source mirrorSource) queryPosts(ctx context.Context, query string, args ...interface{}) ([]post, error) {
+	rows, err := source.Store.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return []post{}, source.Store.readError(err)
+	}
+	defer rows.Close()
+	posts := []post{}
+	for rows.Next() {
+		var mirroredPost post
+		if err := rows.Scan(&mirroredPost.ID, &mirroredPost.UserID, &mirroredPost.Title, &mirroredPost.Body); err != nil {
+			return []post{}, source.Store.readError(err)
+		}
+		posts = append(posts, mirroredPost)
+	}
+	if err := rows.Err(); err != nil {
+		return []post{}, source.Store.readError(err)
+	}
+	return posts, nil
+}
+
+// Either a single *sql.Row or the current row of *sql.Rows.
+type mirrorScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanMirroredUser(scanner mirrorScanner) (user, error) {
+	var mirroredUser user
+	var address, company string
+	if err := scanner.Scan(&mirroredUser.ID, &mirroredUser.Name, &mirroredUser.Username, &mirroredUser.Email, &address, &mirroredUser.Phone, &mirroredUser.Website, &company); err != nil {
+		return user{}, err
+	}
+	if err := json.Unmarshal([]byte(address), &mirroredUser.Address); err != nil {
+		return user{}, err
+	}
+	if err := json.Unmarshal([]byte(company), &mirroredUser.Company); err != nil {
+		return user{}, err
+	}
+	return mirroredUser, nil
+}
+
+func mirrorArgs(ids []int) []interface{} {
+	args := make([]interface{}, 0, len(ids))
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	return args
+}
@@ -0,0 +1,383 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// mirrorSyncer.Sync
+
+func TestMirrorSyncerSync(t *testing.T) {
+	syncer := newTestMirrorSyncer(t, filepath.Join(t.TempDir(), "mirror.db"), nil)
+
+	assert.Nil(t, syncer.Sync(context.Background()))
+
+	stats := syncer.Stats()
+	assert.Equal(t, testMirrorSyncedAt, *stats.LastSyncedAt)
+	assert.Equal(t, testMirrorSyncedAt, *stats.LastAttemptedAt)
+	assert.Equal(t, mirrorRowCounts{Users: 10, Posts: 100}, stats.Rows)
+	assert.Equal(t, mirrorChanges{Users: mirrorRowChanges{Inserted: 10}, Posts: mirrorRowChanges{Inserted: 100}}, *stats.LastChanges)
+	assert.Equal(t, uint64(1), stats.Syncs)
+
+	// Nothing changed on Cool Vendor's side since.
+	assert.Nil(t, syncer.Sync(context.Background()))
+	assert.Equal(t, mirrorChanges{}, *syncer.Stats().LastChanges)
+}
+
+func TestMirrorSyncerSyncUpsertsAndDeletes(t *testing.T) {
+	syncer := newTestMirrorSyncer(t, filepath.Join(t.TempDir(), "mirror.db"), nil)
+	assert.Nil(t, syncer.Sync(context.Background()))
+
+	// Pretend that the last sync saw an older name for the first user, a user that's gone now, and one less post.
+	_, err := syncer.Store.db.Exec(`
+		UPDATE users SET name = 'Leanne Graham-Bell' WHERE id = 1;
+		INSERT INTO users (id, name, username, email, address, phone, website, company) VALUES (11, 'Deleted User', '', '', '{}', '', '', '{}');
+		DELETE FROM posts WHERE id = 100;
+	`)
+	assert.Nil(t, err)
+
+	assert.Nil(t, syncer.Sync(context.Background()))
+
+	stats := syncer.Stats()
+	assert.Equal(t, mirrorChanges{
+		Users: mirrorRowChanges{Updated: 1, Deleted: 1},
+		Posts: mirrorRowChanges{Inserted: 1},
+	}, *stats.LastChanges)
+	assert.Equal(t, mirrorRowCounts{Users: 10, Posts: 100}, stats.Rows)
+
+	userResp, err := mirrorSource{Store: syncer.Store}.getUserById(context.Background(), 1)
+	assert.Nil(t, err)
+	assert.Equal(t, "Leanne Graham", userResp.Name)
+}
+
+func TestMirrorSyncerSyncFailure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mirror.db")
+	assert.Nil(t, newTestMirrorSyncer(t, path, nil).Sync(context.Background()))
+
+	syncer := newTestMirrorSyncer(t, path, fakeVendorFaults{"posts": {Status: http.StatusInternalServerError}})
+	syncer.now = func() time.Time { return testMirrorSyncedAt.Add(time.Hour) }
+	err := syncer.Sync(context.Background())
+
+	var upstreamUnavailableErr *upstreamUnavailableError
+	assert.True(t, errors.As(err, &upstreamUnavailableErr))
+
+	// Without every post, there's no telling which ones were deleted, so the previous sync is kept as is.
+	stats := syncer.Stats()
+	assert.Equal(t, testMirrorSyncedAt, *stats.LastSyncedAt)
+	assert.Equal(t, testMirrorSyncedAt.Add(time.Hour), *stats.LastAttemptedAt)
+	assert.Equal(t, mirrorRowCounts{Users: 10, Posts: 100}, stats.Rows)
+	assert.Nil(t, stats.LastChanges)
+	assert.Equal(t, err.Error(), stats.LastError)
+	assert.Equal(t, uint64(1), stats.SyncFailures)
+}
+
+func TestMirrorSyncerSyncNoUsers(t *testing.T) {
+	syncer := newTestMirrorSyncer(t, filepath.Join(t.TempDir(), "mirror.db"), nil)
+	syncer.Source = truncatedMirrorSource{UserPostSource: syncer.Source, users: 0, posts: 100}
+
+	err := syncer.Sync(context.Background())
+
+	assert.Contains(t, err.Error(), "since Cool Vendor returned no users")
+	assert.Nil(t, syncer.Stats().LastSyncedAt)
+	assert.Equal(t, uint64(1), syncer.Stats().SyncFailures)
+}
+
+func TestMirrorSyncerSyncTooFewRows(t *testing.T) {
+	syncer := newTestMirrorSyncer(t, filepath.Join(t.TempDir(), "mirror.db"), nil)
+	assert.Nil(t, syncer.Sync(context.Background()))
+	source := syncer.Source
+
+	// A few deletions are fine, but losing most of the posts at once looks a lot more like a bad response.
+	syncer.Source = truncatedMirrorSource{UserPostSource: source, users: 10, posts: 40}
+	err := syncer.Sync(context.Background())
+	assert.Contains(t, err.Error(), "since Cool Vendor returned only 40 of the 100 mirrored posts")
+	assert.Equal(t, mirrorRowCounts{Users: 10, Posts: 100}, syncer.Stats().Rows)
+
+	syncer.Source = truncatedMirrorSource{UserPostSource: source, users: 9, posts: 90}
+	assert.Nil(t, syncer.Sync(context.Background()))
+	assert.Equal(t, mirrorChanges{
+		Users: mirrorRowChanges{Deleted: 1},
+		Posts: mirrorRowChanges{Deleted: 10},
+	}, *syncer.Stats().LastChanges)
+}
+
+func TestMirrorSyncerRun(t *testing.T) {
+	syncer := newTestMirrorSyncer(t, filepath.Join(t.TempDir(), "mirror.db"), nil)
+	syncer.Options = mirrorSyncOptions{Interval: time.Hour}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		syncer.Run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	// The first sync happens right away rather than an interval later.
+	waitForMirrorSyncs(t, syncer, 1)
+}
+
+// openMirrorStore
+
+func TestOpenMirrorStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mirror.db")
+	assert.Nil(t, newTestMirrorSyncer(t, path, nil).Sync(context.Background()))
+
+	store, err := openMirrorStore(path)
+	assert.Nil(t, err)
+	t.Cleanup(func() { store.Close() })
+
+	// Everything from the last sync is still there after a restart.
+	assert.Equal(t, testMirrorSyncedAt, store.lastSyncedAt)
+	assert.Equal(t, mirrorRowCounts{Users: 10, Posts: 100}, store.rows)
+	userResp, err := mirrorSource{Store: store}.getUserById(context.Background(), 1)
+	assert.Nil(t, err)
+	assert.Equal(t, "Leanne Graham", userResp.Name)
+	assert.Equal(t, "Gwenborough", userResp.Address.City)
+	assert.Equal(t, "Romaguera-Crona", userResp.Company.Name)
+}
+
+func TestOpenMirrorStoreMissingFile(t *testing.T) {
+	store, err := openMirrorStore(filepath.Join(t.TempDir(), "data", "mirror.db"))
+
+	assert.Nil(t, err)
+	t.Cleanup(func() { store.Close() })
+	assert.EqualError(t, store.checkSynced(), "The local mirror of Cool Vendor's data hasn't been synced yet")
+}
+
+func TestOpenMirrorStoreInvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mirror.db")
+	assert.Nil(t, ioutil.WriteFile(path, []byte(`{"users": "nope"}`), 0644))
+
+	_, err := openMirrorStore(path)
+
+	assert.Contains(t, err.Error(), "Unable to open mirror '"+path+"'")
+}
+
+// mirrorSource
+
+func TestMirrorSourceWhileVendorIsDown(t *testing.T) {
+	syncer := newTestMirrorSyncer(t, filepath.Join(t.TempDir(), "mirror.db"), nil)
+	assert.Nil(t, syncer.Sync(context.Background()))
+
+	// Only comments still come from Cool Vendor, which can be left out as a partial response.
+	source := mirrorSource{
+		UserPostSource: newTestFakeVendorService(t, fakeVendorFaults{"*": {Status: http.StatusServiceUnavailable}}).Source,
+		Store:          syncer.Store,
+	}
+	userPostService := userPostService{Source: source}
+
+	userPostsResp, err := userPostService.getUserPostsByUserId(context.Background(), 1, userPostsOptions{IncludeComments: true, AllowPartial: true})
+
+	assert.Nil(t, err)
+	assert.Equal(t, userInfo{Name: "Leanne Graham", Username: "Bret", Email: "Sincere@april.biz"}, userPostsResp.UserInfo)
+	assert.Len(t, userPostsResp.Posts, 10)
+	assert.Len(t, userPostsResp.Warnings, 1)
+	assert.Equal(t, partialResourceComments, userPostsResp.Warnings[0].Resource)
+}
+
+func TestMirrorSourceByIds(t *testing.T) {
+	syncer := newTestMirrorSyncer(t, filepath.Join(t.TempDir(), "mirror.db"), nil)
+	assert.Nil(t, syncer.Sync(context.Background()))
+	source := mirrorSource{Store: syncer.Store}
+
+	usersById, err := source.getUsersByIds(context.Background(), []int{2, 1, 404})
+	assert.Nil(t, err)
+	assert.Len(t, usersById, 2)
+	assert.Equal(t, "Ervin Howell", usersById[2].Name)
+
+	postsByUserId, err := source.getPostsByUserIds(context.Background(), []int{2, 404})
+	assert.Nil(t, err)
+	assert.Equal(t, []int{11, 12, 13, 14, 15, 16, 17, 18, 19, 20}, postIDs(postsByUserId[2]))
+	assert.Equal(t, []postSummary{}, postsByUserId[404])
+
+	_, err = source.getUserById(context.Background(), 404)
+	assert.EqualError(t, err, "Could not find userId=404")
+}
+
+func TestMirrorSourceBypassingCache(t *testing.T) {
+	syncer := newTestMirrorSyncer(t, filepath.Join(t.TempDir(), "mirror.db"), nil)
+	assert.Nil(t, syncer.Sync(context.Background()))
+	_, err := syncer.Store.db.Exec("UPDATE users SET name = 'Leanne Graham-Bell' WHERE id = 1")
+	assert.Nil(t, err)
+	source := mirrorSource{UserPostSource: newTestFakeVendorService(t, nil).Source, Store: syncer.Store}
+
+	userResp, err := source.bypassingCache().getUserById(context.Background(), 1)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "Leanne Graham", userResp.Name)
+	assert.True(t, source.bypassingCache().(typicodeClient).BypassCache)
+}
+
+func TestMirrorSourceByIdsChunked(t *testing.T) {
+	syncer := newTestMirrorSyncer(t, filepath.Join(t.TempDir(), "mirror.db"), nil)
+	assert.Nil(t, syncer.Sync(context.Background()))
+	source := mirrorSource{Store: syncer.Store}
+
+	// Way past what SQLite can bind to a single query, with the real users in different chunks.
+	userIds := []int{1}
+	for userId := 1000; userId < 3000; userId++ {
+		userIds = append(userIds, userId)
+	}
+	userIds = append(userIds, 2, 1)
+
+	usersById, err := source.getUsersByIds(context.Background(), userIds)
+	assert.Nil(t, err)
+	assert.Len(t, usersById, 2)
+
+	postsByUserId, err := source.getPostsByUserIds(context.Background(), userIds)
+	assert.Nil(t, err)
+	assert.Len(t, postsByUserId, 2002)
+	assert.Equal(t, []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, postIDs(postsByUserId[1]))
+	assert.Equal(t, []int{11, 12, 13, 14, 15, 16, 17, 18, 19, 20}, postIDs(postsByUserId[2]))
+}
+
+func TestMirrorSourceNotSynced(t *testing.T) {
+	store, err := openMirrorStore(filepath.Join(t.TempDir(), "mirror.db"))
+	assert.Nil(t, err)
+	t.Cleanup(func() { store.Close() })
+	userPostService := userPostService{Source: mirrorSource{Store: store}}
+
+	_, err = userPostService.getUserPostsByUserId(context.Background(), 1, userPostsOptions{})
+
+	var upstreamUnavailableErr *upstreamUnavailableError
+	assert.True(t, errors.As(err, &upstreamUnavailableErr))
+	assert.EqualError(t, err, "The local mirror of Cool Vendor's data hasn't been synced yet")
+}
+
+// chunkMirrorIds
+
+func TestChunkMirrorIds(t *testing.T) {
+	ids := []int{}
+	for id := 1; id <= mirrorMaxIdsPerQuery+1; id++ {
+		ids = append(ids, id)
+	}
+
+	chunks := chunkMirrorIds(append(ids, 1))
+
+	assert.Len(t, chunks, 2)
+	assert.Len(t, chunks[0], mirrorMaxIdsPerQuery)
+	assert.Equal(t, []int{mirrorMaxIdsPerQuery + 1}, chunks[1])
+	assert.Empty(t, chunkMirrorIds(nil))
+}
+
+// Controller - getMirrorStats
+
+func TestGetMirrorStats(t *testing.T) {
+	initializeTestMirror(t)
+
+	w := httptest.NewRecorder()
+	setupRouter().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/diagnostics/mirror", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var stats mirrorStats
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(&stats))
+	assert.NotNil(t, stats.LastSyncedAt)
+	assert.Equal(t, mirrorRowCounts{Users: 10, Posts: 100}, stats.Rows)
+
+	// The service reads users and posts from the mirror now.
+	w = httptest.NewRecorder()
+	setupRouter().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/user-posts/1", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestGetUserPostsByUserIdMirrorNoCache(t *testing.T) {
+	initializeTestMirror(t)
+	_, err := typicodeMirror.Store.db.Exec("UPDATE users SET name = 'Leanne Graham-Bell' WHERE id = 1")
+	assert.Nil(t, err)
+
+	w := httptest.NewRecorder()
+	setupRouter().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/user-posts/1", nil))
+	assert.Contains(t, w.Body.String(), `"name": "Leanne Graham-Bell"`)
+
+	// Cool Vendor's latest, rather than whatever the mirror had as of its last sync.
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/v1/user-posts/1", nil)
+	req.Header.Set("Cache-Control", "no-cache")
+	setupRouter().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"name": "Leanne Graham"`)
+}
+
+func TestGetMirrorStatsNotEnabled(t *testing.T) {
+	typicodeMirror = nil
+
+	w := httptest.NewRecorder()
+	setupRouter().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/diagnostics/mirror", nil))
+
+	assertProblem(t, w, "not_found", "The mirror source is not enabled")
+}
+
+// Test Helpers
+
+var testMirrorSyncedAt = time.Date(2021, time.March, 14, 15, 9, 26, 0, time.UTC)
+
+// A syncer for the mirror at path, pulling from the fake vendor with the given faults.
+func newTestMirrorSyncer(t *testing.T, path string, faults fakeVendorFaults) *mirrorSyncer {
+	store, err := openMirrorStore(path)
+	assert.Nil(t, err)
+	t.Cleanup(func() { store.Close() })
+	syncer := newMirrorSyncer(newTestFakeVendorService(t, faults).Source, store, mirrorSyncOptions{Interval: time.Hour})
+	syncer.now = func() time.Time { return testMirrorSyncedAt }
+	return syncer
+}
+
+// Initialize the service with the mirror source in front of the fake vendor, and sync it once.
+func initializeTestMirror(t *testing.T) {
+	server := httptest.NewServer(newTestFakeVendor(t, nil))
+	t.Cleanup(server.Close)
+	config := defaultAppConfig
+	config.Upstream.BaseUrl = server.URL
+	config.Source.Backend = sourceMirror
+	config.Mirror.File = filepath.Join(t.TempDir(), "mirror.db")
+	assert.Nil(t, initialize(config))
+	t.Cleanup(func() {
+		typicodeMirror.Store.Close()
+		typicodeCache = nil
+		typicodeBreaker = nil
+		typicodeMirror = nil
+		postSearchIndexer = nil
+		userPostServiceImpl = userPostService{}
+	})
+	assert.Nil(t, typicodeMirror.Sync(context.Background()))
+}
+
+// Only the first users and posts of the source it wraps.
+type truncatedMirrorSource struct {
+	UserPostSource
+	users int
+	posts int
+}
+
+func (source truncatedMirrorSource) getUsers(ctx context.Context) ([]user, error) {
+	users, err := source.UserPostSource.getUsers(ctx)
+	return users[:source.users], err
+}
+
+func (source truncatedMirrorSource) getPosts(ctx context.Context) ([]post, error) {
+	posts, err := source.UserPostSource.getPosts(ctx)
+	return posts[:source.posts], err
+}
+
+func waitForMirrorSyncs(t *testing.T, syncer *mirrorSyncer, syncs uint64) {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if syncer.Stats().Syncs == syncs {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("Timed out waiting for %d mirror syncs", syncs)
+}
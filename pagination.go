@@ -0,0 +1,219 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Pagination
+//
+// Lets consumers page through a user's posts with "?page=&pageSize=", and pick the order with "?sort=". Pagination
+// is opt-in so that existing consumers keep getting every post, same as before, and sorting alone just reorders them. Every paginated response describes
+// where it is in the whole collection, with links to its neighboring pages both in the body and in an RFC 8288
+// "Link" header.
+//
+// @see https://www.rfc-editor.org/rfc/rfc8288
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+
+	// Far past the end of anything Cool Vendor has, while keeping "(page-1)*pageSize" well clear of overflowing.
+	maxPage = 10000
+)
+
+// Supported values for the "sort" query parameter.
+const (
+	sortByID     = "id"
+	sortByIDDesc = "-id"
+	sortByTitle  = "title"
+)
+
+var postSortOrders = []string{sortByID, sortByIDDesc, sortByTitle}
+
+// Where a page sits within the whole collection.
+type pagination struct {
+	Page       int             `json:"page"`
+	PageSize   int             `json:"pageSize"`
+	Total      int             `json:"total"`
+	TotalPages int             `json:"totalPages"`
+	Links      paginationLinks `json:"links"`
+}
+
+// Relative to the API's host, with every other query parameter of the current request carried over.
+type paginationLinks struct {
+	Self  string `json:"self"`
+	First string `json:"first"`
+	Prev  string `json:"prev,omitempty"`
+	Next  string `json:"next,omitempty"`
+	Last  string `json:"last"`
+}
+
+// Parse the "page", "pageSize", and "sort" query parameters. Without "page" or "pageSize", pageSize is zero for every
+// post. Otherwise, whichever one is missing falls back to the first page or defaultPageSize posts. Either way, posts
+// are sorted by ID unless "sort" says otherwise.
+func parsePostsPage(c *gin.Context) (page int, pageSize int, sortOrder string, err error) {
+	if isPaginationRequested(c) {
+		page, pageSize, err = parsePageQuery(c)
+		if err != nil {
+			return 0, 0, "", err
+		}
+	}
+
+	sortOrder, err = parsePostsSort(c)
+	if err != nil {
+		return 0, 0, "", err
+	}
+	return page, pageSize, sortOrder, nil
+}
+
+// Parse the "page" and "pageSize" query parameters shared by every paginated endpoint, falling back to the first
+// page of defaultPageSize items.
+func parsePageQuery(c *gin.Context) (page int, pageSize int, err error) {
+	page, err = parsePositiveIntQuery(c, "page", 1, maxPage)
+	if err != nil {
+		return 0, 0, err
+	}
+	pageSize, err = parsePositiveIntQuery(c, "pageSize", defaultPageSize, maxPageSize)
+	if err != nil {
+		return 0, 0, err
+	}
+	return page, pageSize, nil
+}
+
+// Whether the consumer asked for "page" or "pageSize". Sorting alone doesn't count, since it applies to every post.
+func isPaginationRequested(c *gin.Context) bool {
+	for _, name := range []string{"page", "pageSize"} {
+		if _, ok := c.GetQuery(name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Parse the "sort" query parameter, falling back to sorting by ID.
+func parsePostsSort(c *gin.Context) (string, error) {
+	sortOrder := c.DefaultQuery("sort", sortByID)
+	for _, postSortOrder := range postSortOrders {
+		if sortOrder == postSortOrder {
+			return sortOrder, nil
+		}
+	}
+	return "", newInvalidInputError("Unsupported sort value '" + sortOrder + "', expected one of: " + strings.Join(postSortOrders, ", "))
+}
+
+// Parse an optional positive integer query parameter, up to max if max is positive.
+func parsePositiveIntQuery(c *gin.Context, name string, defaultValue int, max int) (int, error) {
+	value, ok := c.GetQuery(name)
+	if !ok {
+		return defaultValue, nil
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if max > 0 && (err != nil || parsed < 1 || parsed > max) {
+		return 0, newInvalidInputError(fmt.Sprintf("Expected '%s' to be an integer between 1 and %d, but got '%s' instead", name, max, value))
+	}
+	if err != nil || parsed < 1 {
+		return 0, newInvalidInputError(fmt.Sprintf("Expected '%s' to be a positive integer, but got '%s' instead", name, value))
+	}
+	return parsed, nil
+}
+
+// Sort posts in place. Ties on the title fall back to the ID so that the order, and therefore every page, is stable.
+func sortPosts(posts []postSummary, sortOrder string) {
+	switch sortOrder {
+	case sortByIDDesc:
+		sort.SliceStable(posts, func(i, j int) bool { return posts[i].ID > posts[j].ID })
+	case sortByTitle:
+		sort.SliceStable(posts, func(i, j int) bool {
+			if posts[i].Title != posts[j].Title {
+				return posts[i].Title < posts[j].Title
+			}
+			return posts[i].ID < posts[j].ID
+		})
+	default:
+		sort.SliceStable(posts, func(i, j int) bool { return posts[i].ID < posts[j].ID })
+	}
+}
+
+// Slice out a single page of posts. A page past the end is just empty rather than an error, so that a consumer
+// paging through a collection that shrinks underneath them doesn't suddenly start getting errors.
+func paginatePosts(posts []postSummary, page int, pageSize int) ([]postSummary, *pagination) {
+	start, end, pagination := paginate(len(posts), page, pageSize)
+	return posts[start:end], pagination
+}
+
+// Work out the bounds of a single page out of total items, for slicing any kind of collection.
+func paginate(total int, page int, pageSize int) (start int, end int, pageInfo *pagination) {
+	if page < 1 {
+		page = 1
+	}
+	totalPages := (total + pageSize - 1) / pageSize
+	if totalPages == 0 {
+		totalPages = 1
+	}
+
+	// Checked before multiplying so that no page, however far past the end, can overflow into a negative start.
+	start = total
+	if page-1 < (total+pageSize-1)/pageSize {
+		start = (page - 1) * pageSize
+	}
+	end = minInt(start+pageSize, total)
+	return start, end, &pagination{
+		Page:       page,
+		PageSize:   pageSize,
+		Total:      total,
+		TotalPages: totalPages,
+	}
+}
+
+// Fill in the links for the current request and mirror them in the "Link" header.
+func setPaginationLinks(c *gin.Context, pagination *pagination) {
+	if pagination == nil {
+		return
+	}
+
+	pageLink := func(page int) string {
+		query := c.Request.URL.Query()
+		query.Set("page", strconv.Itoa(page))
+		query.Set("pageSize", strconv.Itoa(pagination.PageSize))
+		link := url.URL{Path: c.Request.URL.Path, RawQuery: query.Encode()}
+		return link.String()
+	}
+
+	pagination.Links = paginationLinks{
+		Self:  pageLink(pagination.Page),
+		First: pageLink(1),
+		Last:  pageLink(pagination.TotalPages),
+	}
+	if pagination.Page > 1 {
+		pagination.Links.Prev = pageLink(minInt(pagination.Page-1, pagination.TotalPages))
+	}
+	if pagination.Page < pagination.TotalPages {
+		pagination.Links.Next = pageLink(pagination.Page + 1)
+	}
+
+	links := []string{
+		fmt.Sprintf(`<%s>; rel="first"`, pagination.Links.First),
+	}
+	if pagination.Links.Prev != "" {
+		links = append(links, fmt.Sprintf(`<%s>; rel="prev"`, pagination.Links.Prev))
+	}
+	if pagination.Links.Next != "" {
+		links = append(links, fmt.Sprintf(`<%s>; rel="next"`, pagination.Links.Next))
+	}
+	links = append(links, fmt.Sprintf(`<%s>; rel="last"`, pagination.Links.Last))
+	c.Header("Link", strings.Join(links, ", "))
+}
+
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// parsePostsPage
+
+func TestParsePostsPageNotRequested(t *testing.T) {
+	page, pageSize, sortOrder, err := parsePostsPage(newTestPaginationContext("/v1/user-posts/1?include=comments"))
+	assert.Nil(t, err)
+	assert.Equal(t, 0, page)
+	assert.Equal(t, 0, pageSize)
+	assert.Equal(t, sortByID, sortOrder)
+}
+
+func TestParsePostsPageSortOnly(t *testing.T) {
+	page, pageSize, sortOrder, err := parsePostsPage(newTestPaginationContext("/v1/user-posts/1?sort=title"))
+	assert.Nil(t, err)
+	assert.Equal(t, 0, page)
+	assert.Equal(t, 0, pageSize)
+	assert.Equal(t, sortByTitle, sortOrder)
+}
+
+func TestParsePostsPageDefaults(t *testing.T) {
+	page, pageSize, sortOrder, err := parsePostsPage(newTestPaginationContext("/v1/user-posts/1?page=1&sort=title"))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, page)
+	assert.Equal(t, defaultPageSize, pageSize)
+	assert.Equal(t, sortByTitle, sortOrder)
+}
+
+func TestParsePostsPage(t *testing.T) {
+	page, pageSize, sortOrder, err := parsePostsPage(newTestPaginationContext("/v1/user-posts/1?page=3&pageSize=100&sort=-id"))
+	assert.Nil(t, err)
+	assert.Equal(t, 3, page)
+	assert.Equal(t, 100, pageSize)
+	assert.Equal(t, sortByIDDesc, sortOrder)
+}
+
+func TestParsePostsPageInvalid(t *testing.T) {
+	tests := map[string]string{
+		"?page=0":         "Expected 'page' to be an integer between 1 and 10000, but got '0' instead",
+		"?page=two":       "Expected 'page' to be an integer between 1 and 10000, but got 'two' instead",
+		"?page=":          "Expected 'page' to be an integer between 1 and 10000, but got '' instead",
+		"?page=10001":     "Expected 'page' to be an integer between 1 and 10000, but got '10001' instead",
+		"?pageSize=0":     "Expected 'pageSize' to be an integer between 1 and 100, but got '0' instead",
+		"?pageSize=101":   "Expected 'pageSize' to be an integer between 1 and 100, but got '101' instead",
+		"?pageSize=-5":    "Expected 'pageSize' to be an integer between 1 and 100, but got '-5' instead",
+		"?sort=-title":    "Unsupported sort value '-title', expected one of: id, -id, title",
+		"?sort=id&page=x": "Expected 'page' to be an integer between 1 and 10000, but got 'x' instead",
+
+		// Big enough that "(page-1)*pageSize" would overflow.
+		"?page=922337203685477581&pageSize=20": "Expected 'page' to be an integer between 1 and 10000, but got '922337203685477581' instead",
+	}
+	for query, expectedErr := range tests {
+		_, _, _, err := parsePostsPage(newTestPaginationContext("/v1/user-posts/1" + query))
+		assert.EqualError(t, err, expectedErr, query)
+		var invalidInputErr *invalidInputError
+		assert.True(t, errors.As(err, &invalidInputErr))
+	}
+}
+
+// sortPosts
+
+func TestSortPosts(t *testing.T) {
+	unsorted := []postSummary{{ID: 2, Title: "b"}, {ID: 3, Title: "a"}, {ID: 1, Title: "b"}}
+
+	posts := append([]postSummary(nil), unsorted...)
+	sortPosts(posts, sortByID)
+	assert.Equal(t, []int{1, 2, 3}, postIDs(posts))
+
+	sortPosts(posts, sortByIDDesc)
+	assert.Equal(t, []int{3, 2, 1}, postIDs(posts))
+
+	// Ties on the title fall back to the ID.
+	sortPosts(posts, sortByTitle)
+	assert.Equal(t, []int{3, 1, 2}, postIDs(posts))
+}
+
+// paginatePosts
+
+func TestPaginatePosts(t *testing.T) {
+	posts := make([]postSummary, 7)
+	for i := range posts {
+		posts[i].ID = i + 1
+	}
+
+	page, pageInfo := paginatePosts(posts, 2, 3)
+	assert.Equal(t, []int{4, 5, 6}, postIDs(page))
+	assert.Equal(t, &pagination{Page: 2, PageSize: 3, Total: 7, TotalPages: 3}, pageInfo)
+
+	page, _ = paginatePosts(posts, 3, 3)
+	assert.Equal(t, []int{7}, postIDs(page))
+
+	// Past the end is just an empty page.
+	page, pageInfo = paginatePosts(posts, 4, 3)
+	assert.Empty(t, page)
+	assert.Equal(t, 3, pageInfo.TotalPages)
+
+	// Even one far enough past the end that its start would overflow.
+	page, _ = paginatePosts(posts, math.MaxInt/3+2, 3)
+	assert.Empty(t, page)
+}
+
+func TestPaginatePostsEmpty(t *testing.T) {
+	page, pageInfo := paginatePosts([]postSummary{}, 1, 20)
+	assert.Empty(t, page)
+	assert.Equal(t, &pagination{Page: 1, PageSize: 20, Total: 0, TotalPages: 1}, pageInfo)
+}
+
+// setPaginationLinks
+
+func TestSetPaginationLinks(t *testing.T) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/v1/user-posts/1?include=comments&page=2&pageSize=3&sort=title", nil)
+	pageInfo := &pagination{Page: 2, PageSize: 3, Total: 7, TotalPages: 3}
+
+	setPaginationLinks(c, pageInfo)
+
+	assert.Equal(t, paginationLinks{
+		Self:  "/v1/user-posts/1?include=comments&page=2&pageSize=3&sort=title",
+		First: "/v1/user-posts/1?include=comments&page=1&pageSize=3&sort=title",
+		Prev:  "/v1/user-posts/1?include=comments&page=1&pageSize=3&sort=title",
+		Next:  "/v1/user-posts/1?include=comments&page=3&pageSize=3&sort=title",
+		Last:  "/v1/user-posts/1?include=comments&page=3&pageSize=3&sort=title",
+	}, pageInfo.Links)
+	assert.Equal(t, `</v1/user-posts/1?include=comments&page=1&pageSize=3&sort=title>; rel="first", `+
+		`</v1/user-posts/1?include=comments&page=1&pageSize=3&sort=title>; rel="prev", `+
+		`</v1/user-posts/1?include=comments&page=3&pageSize=3&sort=title>; rel="next", `+
+		`</v1/user-posts/1?include=comments&page=3&pageSize=3&sort=title>; rel="last"`, w.Header().Get("Link"))
+}
+
+func TestSetPaginationLinksPastTheEnd(t *testing.T) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/v1/user-posts/1?page=9", nil)
+	pageInfo := &pagination{Page: 9, PageSize: 20, Total: 10, TotalPages: 1}
+
+	setPaginationLinks(c, pageInfo)
+
+	// Prev should lead back to the actual last page rather than yet another empty one.
+	assert.Equal(t, "/v1/user-posts/1?page=1&pageSize=20", pageInfo.Links.Prev)
+	assert.Empty(t, pageInfo.Links.Next)
+}
+
+// Controller - getUserPostsByUserId pagination
+
+func TestGetUserPostsByUserIdPaginated(t *testing.T) {
+	initializeTestFakeVendor(t, nil)
+
+	w := httptest.NewRecorder()
+	setupRouter().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/user-posts/1?page=2&pageSize=3&sort=-id", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Link"), `</v1/user-posts/1?page=3&pageSize=3&sort=-id>; rel="next"`)
+	var userPostsResp userPosts
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(&userPostsResp))
+	assert.Equal(t, []int{7, 6, 5}, postIDs(userPostsResp.Posts))
+	assert.Equal(t, 10, userPostsResp.Pagination.Total)
+	assert.Equal(t, 4, userPostsResp.Pagination.TotalPages)
+	assert.Equal(t, "/v1/user-posts/1?page=1&pageSize=3&sort=-id", userPostsResp.Pagination.Links.Prev)
+}
+
+func TestGetUserPostsByUserIdNotPaginated(t *testing.T) {
+	initializeTestFakeVendor(t, nil)
+
+	w := httptest.NewRecorder()
+	setupRouter().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/user-posts/1", nil))
+
+	// Same as before pagination existed, i.e. every post and nothing about pages.
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Link"))
+	assert.NotContains(t, w.Body.String(), `"pagination"`)
+	var userPostsResp userPosts
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(&userPostsResp))
+	assert.Equal(t, []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, postIDs(userPostsResp.Posts))
+}
+
+func TestGetUserPostsByUserIdSortOnly(t *testing.T) {
+	initializeTestFakeVendor(t, nil)
+
+	w := httptest.NewRecorder()
+	setupRouter().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/user-posts/1?sort=-id", nil))
+
+	// Every post, just in the other order.
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Link"))
+	assert.NotContains(t, w.Body.String(), `"pagination"`)
+	var userPostsResp userPosts
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(&userPostsResp))
+	assert.Equal(t, []int{10, 9, 8, 7, 6, 5, 4, 3, 2, 1}, postIDs(userPostsResp.Posts))
+}
+
+func TestGetUserPostsByUserIdPageSizeTooLarge400(t *testing.T) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Params = []gin.Param{{Key: "userId", Value: "1"}}
+	c.Request = httptest.NewRequest(http.MethodGet, "/v1/user-posts/1?pageSize=1000", nil)
+
+	getUserPostsByUserId(c)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assertProblem(t, w, "invalid_input", "Expected 'pageSize' to be an integer between 1 and 100, but got '1000' instead")
+}
+
+func TestGetUserPostsByUserIdPageTooLarge400(t *testing.T) {
+	initializeTestFakeVendor(t, nil)
+
+	w := httptest.NewRecorder()
+	setupRouter().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/user-posts/1?page=922337203685477581&pageSize=20", nil))
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assertProblem(t, w, "invalid_input", "Expected 'page' to be an integer between 1 and 10000, but got '922337203685477581' instead")
+}
+
+// userPostService.getUserPostsByUserId pagination
+
+func TestUserPostServiceGetUserPostsByIdOnlyFetchesCommentsForPage(t *testing.T) {
+	fakeVendor := newTestFakeVendor(t, nil)
+	var commentFetches int32
+	mockHTTPClientDo = func(r *http.Request) (*http.Response, error) {
+		if strings.HasPrefix(r.URL.Path, "/comments") {
+			atomic.AddInt32(&commentFetches, 1)
+		}
+		w := httptest.NewRecorder()
+		fakeVendor.ServeHTTP(w, r)
+		return w.Result(), nil
+	}
+	userPostService := userPostService{
+		Source: typicodeClient{
+			Client:  &mockHTTPClient{},
+			BaseUrl: mockBaseURL,
+		},
+	}
+
+	resp, respErr := userPostService.getUserPostsByUserId(context.Background(), 1, userPostsOptions{
+		IncludeComments: true,
+		Page:            1,
+		PageSize:        4,
+	})
+	assert.Nil(t, respErr)
+	assert.Equal(t, []int{1, 2, 3, 4}, postIDs(resp.Posts))
+	assert.Equal(t, int32(4), atomic.LoadInt32(&commentFetches))
+}
+
+// Test Helpers
+
+func newTestPaginationContext(target string) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, target, nil)
+	return c
+}
+
+func postIDs(posts []postSummary) []int {
+	ids := []int{}
+	for _, post := range posts {
+		ids = append(ids, post.ID)
+	}
+	return ids
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Partial Responses
+//
+// A user's posts and comments are fetched separately from the user themselves, so one of them failing doesn't have
+// to mean throwing away everything else we got. By default, a response missing one of its sub-resources is still
+// returned, with that sub-resource set to null and a warning describing what went wrong in its place.
+//
+// Consumers that would rather have all or nothing can opt out per request with "?strict=true", or with the standard
+// "Prefer: handling=strict" header, in which case any failure fails the whole request like it used to.
+//
+// @see https://www.rfc-editor.org/rfc/rfc7240#section-4.4
+
+// Names of the sub-resources that can be left out of a partial response.
+const (
+	partialResourcePosts    = "posts"
+	partialResourceComments = "comments"
+	partialResourcePhotos   = "photos"
+)
+
+// A sub-resource that couldn't be fetched, in a response that was returned without it anyways.
+type partialWarning struct {
+	// Which part of the response is missing, e.g. "posts".
+	Resource string `json:"resource"`
+
+	// Same as the problem details the failure would have gotten on its own, see problem.go.
+	Status int    `json:"status"`
+	Code   string `json:"code"`
+	Detail string `json:"detail"`
+
+	// Why the sub-resource is missing. The controller layer turns this into the fields above, see
+	// describePartialResponse.
+	err error
+}
+
+// Whether a failed sub-resource can be left out of the response rather than failing the whole request. Only
+// cancellation always fails the whole request, since there's nobody left to respond to.
+func canRespondPartially(allowPartial bool, err error) bool {
+	return allowPartial && !errors.Is(err, context.Canceled)
+}
+
+// Build a response for a user whose posts couldn't be fetched at all.
+func newPartialUserPosts(userResp user, postsErr error) userPosts {
+	return userPosts{
+		ID: userResp.ID,
+		UserInfo: userInfo{
+			Name:     userResp.Name,
+			Username: userResp.Username,
+			Email:    userResp.Email,
+		},
+		Posts:    nil,
+		Warnings: []partialWarning{{Resource: partialResourcePosts, err: postsErr}},
+	}
+}
+
+// Work out whether the current request allows partial responses, which they do unless partial responses are
+// disabled entirely, or the consumer asked for strict handling.
+func parseAllowPartial(c *gin.Context) (bool, error) {
+	strict := false
+	if value, ok := c.GetQuery("strict"); ok {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return false, newInvalidInputError("Expected 'strict' to be true or false, but got '" + value + "' instead")
+		}
+		strict = parsed
+	}
+	for _, preference := range strings.Split(c.GetHeader("Prefer"), ",") {
+		if strings.EqualFold(strings.TrimSpace(preference), "handling=strict") {
+			strict = true
+		}
+	}
+	return partialResponsesEnabled && !strict, nil
+}
+
+// Describe every warning the same way its failure would have been described on its own, and pick the status code
+// for a response with them.
+func describePartialResponse(c *gin.Context, warnings []partialWarning) int {
+	if len(warnings) == 0 {
+		return http.StatusOK
+	}
+	for i := range warnings {
+		problem := problemForError(c, warnings[i].err)
+		warnings[i].Status = problem.Status
+		warnings[i].Code = problem.Code
+		warnings[i].Detail = problem.Detail
+	}
+	return partialResponseStatus
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// userPostService.getUserPostsByUserId
+
+func TestUserPostServiceGetUserPostsByUserIdPartialPosts(t *testing.T) {
+	userPostService := newTestFakeVendorService(t, fakeVendorFaults{"posts": {Status: http.StatusInternalServerError}})
+
+	userPostsResp, err := userPostService.getUserPostsByUserId(context.Background(), 1, userPostsOptions{AllowPartial: true})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, userPostsResp.ID)
+	assert.Equal(t, "Bret", userPostsResp.UserInfo.Username)
+	assert.Nil(t, userPostsResp.Posts)
+	assert.Len(t, userPostsResp.Warnings, 1)
+	assert.Equal(t, partialResourcePosts, userPostsResp.Warnings[0].Resource)
+	var unavailableErr *upstreamUnavailableError
+	assert.True(t, errors.As(userPostsResp.Warnings[0].err, &unavailableErr))
+}
+
+func TestUserPostServiceGetUserPostsByUserIdPartialPostsStrict(t *testing.T) {
+	userPostService := newTestFakeVendorService(t, fakeVendorFaults{"posts": {Status: http.StatusInternalServerError}})
+
+	_, err := userPostService.getUserPostsByUserId(context.Background(), 1, userPostsOptions{})
+
+	assert.EqualError(t, err, "Unexpected server error occurred trying to fetch posts for userId=1 from Cool Vendor: status=500")
+}
+
+func TestUserPostServiceGetUserPostsByUserIdPartialComments(t *testing.T) {
+	userPostService := newTestFakeVendorService(t, fakeVendorFaults{"comments": {Status: http.StatusInternalServerError}})
+
+	userPostsResp, err := userPostService.getUserPostsByUserId(context.Background(), 1, userPostsOptions{IncludeComments: true, AllowPartial: true})
+
+	assert.Nil(t, err)
+	assert.Len(t, userPostsResp.Posts, 10)
+	for _, post := range userPostsResp.Posts {
+		assert.Nil(t, post.Comments)
+	}
+	assert.Len(t, userPostsResp.Warnings, 1)
+	assert.Equal(t, partialResourceComments, userPostsResp.Warnings[0].Resource)
+}
+
+func TestUserPostServiceGetUserPostsByUserIdPartialUserNotFound(t *testing.T) {
+	userPostService := newTestFakeVendorService(t, fakeVendorFaults{"posts": {Status: http.StatusInternalServerError}})
+
+	_, err := userPostService.getUserPostsByUserId(context.Background(), 123456, userPostsOptions{AllowPartial: true})
+
+	// There's nothing to return without the user.
+	var notFoundErr *notFoundError
+	assert.True(t, errors.As(err, &notFoundErr))
+}
+
+// userPostService.getUserPostsByUserIds
+
+func TestUserPostServiceGetUserPostsByUserIdsInBulkPartialPosts(t *testing.T) {
+	userPostService := newTestFakeVendorService(t, fakeVendorFaults{"posts": {Status: http.StatusInternalServerError}})
+
+	items := userPostService.getUserPostsByUserIds(context.Background(), []int{1, 123456, 2}, userPostsOptions{AllowPartial: true}, batchOptions{Concurrency: 2, BulkThreshold: 1})
+
+	assert.Nil(t, items[0].Err)
+	assert.Nil(t, items[0].UserPosts.Posts)
+	assert.Equal(t, partialResourcePosts, items[0].UserPosts.Warnings[0].Resource)
+	assert.EqualError(t, items[1].Err, "Could not find userId=123456")
+	assert.Nil(t, items[2].Err)
+	assert.Equal(t, "Antonette", items[2].UserPosts.UserInfo.Username)
+	assert.Equal(t, partialResourcePosts, items[2].UserPosts.Warnings[0].Resource)
+}
+
+// parseAllowPartial
+
+func TestParseAllowPartial(t *testing.T) {
+	for target, expected := range map[string]bool{
+		"/v1/user-posts/1":              true,
+		"/v1/user-posts/1?strict=false": true,
+		"/v1/user-posts/1?strict=true":  false,
+		"/v1/user-posts/1?strict=1":     false,
+	} {
+		allowPartial, err := parseAllowPartial(newTestPaginationContext(target))
+		assert.Nil(t, err, target)
+		assert.Equal(t, expected, allowPartial, target)
+	}
+}
+
+func TestParseAllowPartialPreferHeader(t *testing.T) {
+	c := newTestPaginationContext("/v1/user-posts/1")
+	c.Request.Header.Set("Prefer", "respond-async, handling=strict")
+
+	allowPartial, err := parseAllowPartial(c)
+
+	assert.Nil(t, err)
+	assert.False(t, allowPartial)
+}
+
+func TestParseAllowPartialDisabled(t *testing.T) {
+	partialResponsesEnabled = false
+	t.Cleanup(func() { partialResponsesEnabled = defaultAppConfig.PartialResponses.Enabled })
+
+	allowPartial, err := parseAllowPartial(newTestPaginationContext("/v1/user-posts/1?strict=false"))
+
+	assert.Nil(t, err)
+	assert.False(t, allowPartial)
+}
+
+func TestParseAllowPartialInvalid(t *testing.T) {
+	_, err := parseAllowPartial(newTestPaginationContext("/v1/user-posts/1?strict=maybe"))
+
+	assert.EqualError(t, err, "Expected 'strict' to be true or false, but got 'maybe' instead")
+}
+
+// Controller - getUserPostsByUserId
+
+func TestGetUserPostsByUserIdPartialPosts(t *testing.T) {
+	initializeTestFakeVendor(t, fakeVendorFaults{"posts": {Status: http.StatusInternalServerError}})
+
+	w := httptest.NewRecorder()
+	setupRouter().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/user-posts/1", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var body map[string]interface{}
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(&body))
+	posts, ok := body["posts"]
+	assert.True(t, ok)
+	assert.Nil(t, posts)
+	assert.Equal(t, []interface{}{map[string]interface{}{
+		"resource": "posts",
+		"status":   float64(http.StatusServiceUnavailable),
+		"code":     "upstream_unavailable",
+		"detail":   "Unexpected server error occurred trying to fetch posts for userId=1 from Cool Vendor: status=500",
+	}}, body["warnings"])
+}
+
+func TestGetUserPostsByUserIdPartialPostsStatus(t *testing.T) {
+	initializeTestFakeVendor(t, fakeVendorFaults{"posts": {Status: http.StatusInternalServerError}})
+	partialResponseStatus = http.StatusPartialContent
+	t.Cleanup(func() { partialResponseStatus = defaultAppConfig.PartialResponses.Status })
+
+	w := httptest.NewRecorder()
+	setupRouter().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/user-posts/1", nil))
+
+	assert.Equal(t, http.StatusPartialContent, w.Code)
+}
+
+func TestGetUserPostsByUserIdPartialPostsStrict(t *testing.T) {
+	initializeTestFakeVendor(t, fakeVendorFaults{"posts": {Status: http.StatusInternalServerError}})
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/v1/user-posts/1", nil)
+	req.Header.Set("Prefer", "handling=strict")
+	setupRouter().ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assertProblem(t, w, "upstream_unavailable", "Unexpected server error occurred trying to fetch posts for userId=1 from Cool Vendor: status=500")
+}
+
+func TestGetUserPostsByUserIdInvalidStrict400(t *testing.T) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/v1/user-posts/1?strict=maybe", nil)
+	c.Params = gin.Params{{Key: "userId", Value: "1"}}
+
+	getUserPostsByUserId(c)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assertProblem(t, w, "invalid_input", "Expected 'strict' to be true or false, but got 'maybe' instead")
+}
+
+// Controller - getUserPostsByUserIds
+
+func TestGetUserPostsByUserIdsPartialPosts(t *testing.T) {
+	initializeTestFakeVendor(t, fakeVendorFaults{"posts": {Status: http.StatusInternalServerError}})
+
+	w := httptest.NewRecorder()
+	setupRouter().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/user-posts?ids=1,2", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var batchResp userPostsBatch
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(&batchResp))
+	assert.Equal(t, 0, batchResp.Succeeded)
+	assert.Equal(t, 2, batchResp.Partial)
+	assert.Equal(t, 0, batchResp.Failed)
+	for _, result := range batchResp.Results {
+		assert.Equal(t, http.StatusOK, result.Status)
+		assert.Nil(t, result.UserPosts.Posts)
+		assert.Equal(t, "upstream_unavailable", result.UserPosts.Warnings[0].Code)
+	}
+}
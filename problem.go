@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Controller - Problem Details
+//
+// Every error response is an RFC 7807 "application/problem+json" body, so that consumers get one consistent,
+// standard shape no matter what went wrong. Each one carries a correlation ID that also shows up in our logs, so
+// that a consumer's bug report can be traced back to exactly what Cool Vendor told us, without us ever having to
+// hand Cool Vendor's raw responses to our consumers.
+//
+// @see https://www.rfc-editor.org/rfc/rfc7807
+
+const problemContentType = "application/problem+json"
+
+// Header that carries the correlation ID. A consumer can send their own to correlate our logs with theirs, and we
+// always echo it back, generating one if needed.
+const correlationIDHeader = "X-Request-ID"
+
+// Key for the correlation ID in both the gin context and the request context.
+type correlationIDKey struct{}
+
+// Longest consumer-provided correlation ID that we're willing to echo back and log.
+const maxCorrelationIDLength = 128
+
+type problemDetails struct {
+	// URI reference identifying the kind of problem. Relative to this API, e.g. "/problems/not_found".
+	Type string `json:"type"`
+
+	// Short summary of the kind of problem, which is the same for every occurrence of it.
+	Title string `json:"title"`
+
+	Status int `json:"status"`
+
+	// Explanation specific to this occurrence of the problem.
+	Detail string `json:"detail"`
+
+	// The request that the problem occurred for.
+	Instance string `json:"instance"`
+
+	// Extension members.
+	Code          string `json:"code"`
+	CorrelationID string `json:"correlationId"`
+}
+
+// Middleware that assigns every request a correlation ID, which gets threaded down to the upstream requests to
+// Cool Vendor so that their logs can be tied back to the consumer's request.
+func correlationID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		correlationIDFor(c)
+		c.Next()
+	}
+}
+
+// Resolve the correlation ID for the current request, assigning one if it doesn't have one yet.
+func correlationIDFor(c *gin.Context) string {
+	if id, ok := c.Get(correlationIDHeader); ok {
+		return id.(string)
+	}
+
+	id := c.GetHeader(correlationIDHeader)
+	if !isValidCorrelationID(id) {
+		id = newCorrelationID()
+	}
+	c.Set(correlationIDHeader, id)
+	c.Header(correlationIDHeader, id)
+	if c.Request != nil {
+		c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), correlationIDKey{}, id))
+	}
+	return id
+}
+
+// Get the correlation ID assigned to the request that ctx belongs to, if any.
+func correlationIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(correlationIDKey{}).(string)
+	return id
+}
+
+// Consumer-provided IDs end up in our logs, so only accept reasonably sized, printable ASCII.
+func isValidCorrelationID(id string) bool {
+	if id == "" || len(id) > maxCorrelationIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < '!' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func newCorrelationID() string {
+	idBytes := make([]byte, 16)
+	if _, err := rand.Read(idBytes); err != nil {
+		// Not worth failing the request over, and still unique enough to find in the logs alongside a timestamp.
+		return "unknown"
+	}
+	return hex.EncodeToString(idBytes)
+}
+
+func newProblemDetails(c *gin.Context, status int, code string, detail string) problemDetails {
+	problem := problemDetails{
+		Type:          "/problems/" + code,
+		Title:         http.StatusText(status),
+		Status:        status,
+		Detail:        detail,
+		Code:          code,
+		CorrelationID: correlationIDFor(c),
+	}
+	if c.Request != nil {
+		problem.Instance = c.Request.URL.RequestURI()
+	}
+	return problem
+}
+
+func writeProblem(c *gin.Context, problem problemDetails) {
+	c.Header("Content-Type", problemContentType)
+	c.IndentedJSON(problem.Status, problem)
+}
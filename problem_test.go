@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// Controller - newProblemDetails
+
+func TestNewProblemDetails(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/v1/user-posts/1?include=comments", nil)
+
+	problem := newProblemDetails(c, http.StatusNotFound, "not_found", "Could not find userId=1")
+
+	assert.Equal(t, http.StatusNotFound, problem.Status)
+	assert.Equal(t, "not_found", problem.Code)
+	assert.Equal(t, "Could not find userId=1", problem.Detail)
+	assert.Equal(t, "/problems/not_found", problem.Type)
+	assert.Equal(t, "Not Found", problem.Title)
+	assert.Equal(t, "/v1/user-posts/1?include=comments", problem.Instance)
+	assert.Equal(t, correlationIDFor(c), problem.CorrelationID)
+}
+
+// Middleware - correlationID
+
+func TestCorrelationIDGenerated(t *testing.T) {
+	userPostServiceImpl = userPostService{
+		Source: typicodeClient{
+			Client:  &mockHTTPClient{},
+			BaseUrl: mockBaseURL,
+		},
+	}
+	var upstreamCorrelationIDs []string
+	mockHTTPClientDo = func(r *http.Request) (*http.Response, error) {
+		upstreamCorrelationIDs = append(upstreamCorrelationIDs, correlationIDFromContext(r.Context()))
+		return &http.Response{
+			StatusCode: 500,
+			Body:       http.NoBody,
+		}, nil
+	}
+
+	w := httptest.NewRecorder()
+	setupRouter().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/users/1", nil))
+
+	problem := assertProblem(t, w, "upstream_unavailable", "Unexpected server error occurred trying to fetch userId=1 from Cool Vendor: status=500")
+	assert.Len(t, problem.CorrelationID, 32)
+	assert.NotEmpty(t, upstreamCorrelationIDs)
+	for _, upstreamCorrelationID := range upstreamCorrelationIDs {
+		assert.Equal(t, problem.CorrelationID, upstreamCorrelationID)
+	}
+}
+
+func TestCorrelationIDFromConsumer(t *testing.T) {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/v1/users/abc", nil)
+	req.Header.Set(correlationIDHeader, "consumer-request-123")
+	setupRouter().ServeHTTP(w, req)
+
+	problem := assertProblem(t, w, "invalid_input", "Expected ID in integer format, but got 'abc' instead")
+	assert.Equal(t, "consumer-request-123", problem.CorrelationID)
+}
+
+func TestCorrelationIDFromConsumerInvalid(t *testing.T) {
+	for _, id := range []string{"has spaces", "new\nline", strings.Repeat("a", maxCorrelationIDLength+1)} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/v1/users/abc", nil)
+		req.Header.Set(correlationIDHeader, id)
+		setupRouter().ServeHTTP(w, req)
+
+		problem := assertProblem(t, w, "invalid_input", "Expected ID in integer format, but got 'abc' instead")
+		assert.NotEqual(t, id, problem.CorrelationID)
+		assert.Len(t, problem.CorrelationID, 32)
+	}
+}
+
+// Test Helpers
+
+// Assert that the response is a problem details body with the given code and detail, and return it for any
+// further assertions.
+func assertProblem(t *testing.T, w *httptest.ResponseRecorder, code string, detail string) problemDetails {
+	assert.Equal(t, problemContentType, w.Header().Get("Content-Type"))
+
+	var problem problemDetails
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(&problem))
+	assert.Equal(t, code, problem.Code)
+	assert.Equal(t, detail, problem.Detail)
+	assert.Equal(t, w.Code, problem.Status)
+	assert.Equal(t, http.StatusText(w.Code), problem.Title)
+	assert.NotEmpty(t, problem.Instance)
+	assert.NotEmpty(t, problem.CorrelationID)
+	assert.Equal(t, problem.CorrelationID, w.Header().Get(correlationIDHeader))
+	return problem
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Clients - Typicode Resources
+//
+// Every one of Cool Vendor's resources is fetched the same way: build a GET, execute it, check the status, decode the
+// JSON, and describe whatever went wrong along the way in terms of what we were trying to fetch. This does all of that
+// once for any model type, so that each typicodeClient method only has to say which resource it wants.
+//
+// Go doesn't allow type parameters on methods, so these are plain functions that take the typicodeClient instead.
+
+// Describes a single GET against one of Cool Vendor's resources, mostly so that every error can say exactly what we
+// were trying to fetch.
+type typicodeRequest struct {
+	// Path relative to the base URL, e.g. "/users/1", along with any query params.
+	Path  string
+	Query url.Values
+
+	// Name of the API in error messages, e.g. "Get Posts".
+	API string
+
+	// What's being fetched in error messages, e.g. "posts for userId=1".
+	Description string
+
+	// What the response body is parsed as in error messages, e.g. "'[]postSummary' JSON for Cool Vendor's Get Posts
+	// API".
+	DecodeTarget string
+
+	// Handlers for any non-200 statuses that mean something other than a failure, e.g. a 404 for a single user. Any
+	// other non-200 status is an upstream error.
+	StatusHandlers map[int]func() error
+}
+
+// Fetch a single resource by its ID, e.g. "/users/1". Returns a notFoundError if it doesn't exist.
+func getTypicodeResourceById[T any](ctx context.Context, typicodeClient typicodeClient, api string, resource string, id int) (T, error) {
+	description := fmt.Sprintf("%sId=%d", strings.TrimSuffix(resource, "s"), id)
+	return getTypicodeResource[T](ctx, typicodeClient, typicodeRequest{
+		Path:         fmt.Sprint("/", resource, "/", id),
+		API:          api,
+		Description:  description,
+		DecodeTarget: fmt.Sprintf("'%s' JSON for Cool Vendor's %s By ID API", typicodeModelName[T](), api),
+		StatusHandlers: map[int]func() error{
+			// Cool Vendor API returns a 404 when a resource could not found in their system, so we can rely on that
+			// status code rather than trying to check for an empty {} object response.
+			http.StatusNotFound: func() error {
+				return &notFoundError{domainErrorDetails{Message: "Could not find " + description}}
+			},
+		},
+	})
+}
+
+// Fetch one of Cool Vendor's list resources, e.g. "/posts", filtered by any given query params, e.g. "userId=1".
+//
+// Typicode's API contract will always return a 200 Ok for any valid request, including an empty array [] when
+// nothing matches the filters, so any other status is an error.
+func getTypicodeList[T any](ctx context.Context, typicodeClient typicodeClient, api string, resource string, query url.Values) ([]T, error) {
+	items, err := getTypicodeResource[[]T](ctx, typicodeClient, typicodeRequest{
+		Path:         "/" + resource,
+		Query:        query,
+		API:          api,
+		Description:  describeTypicodeList(resource, query),
+		DecodeTarget: fmt.Sprintf("'%s' JSON for Cool Vendor's %s API", typicodeModelName[[]T](), api),
+	})
+	if err != nil {
+		return []T{}, err
+	}
+	return items, nil
+}
+
+// Execute a request against Cool Vendor and decode a 200 Ok response as T.
+func getTypicodeResource[T any](ctx context.Context, typicodeClient typicodeClient, request typicodeRequest) (T, error) {
+	var zero T
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprint(typicodeClient.BaseUrl, request.Path), nil)
+	if err != nil {
+		return zero, fmt.Errorf("Unexpected error creating client request for Cool Vendor's %s API: error=%w", request.API, err)
+	}
+
+	// Attach query params.
+	q := req.URL.Query()
+	for field, values := range request.Query {
+		for _, value := range values {
+			q.Add(field, value)
+		}
+	}
+	req.URL.RawQuery = q.Encode()
+
+	// Execute request.
+	resp, err := typicodeClient.do(req)
+	if err != nil {
+		return zero, newUpstreamCommunicationError(err, fmt.Sprintf("Unexpected communication or client policy error occurred trying to fetch %s from Cool Vendor: %s", request.Description, err))
+	}
+	defer resp.Body.Close()
+
+	// We should just make sure we explicitly handle the JSON parsing of the response body just to be safe.
+	if resp.StatusCode == http.StatusOK {
+		var value T
+		if err := json.NewDecoder(resp.Body).Decode(&value); err != nil {
+			return zero, newUpstreamDecodeError(err, fmt.Sprintf("Unable to parse response body as %s: error=%s", request.DecodeTarget, err))
+		}
+		return value, nil
+	}
+	if handler, ok := request.StatusHandlers[resp.StatusCode]; ok {
+		return zero, handler()
+	}
+
+	// Any other status is considered a general error that we should at least log.
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return zero, newUpstreamCommunicationError(err, fmt.Sprintf("Unexpected error trying to read response body for server error trying to fetch %s from Cool Vendor: error=%s", request.Description, err))
+	}
+	typicodeClient.logUnexpectedResponse(req, resp, body)
+	return zero, newUpstreamStatusError(resp.StatusCode, fmt.Sprint("Unexpected server error occurred trying to fetch ", request.Description, " from Cool Vendor: status=", resp.StatusCode))
+}
+
+// Describe a list request in error messages, e.g. "posts for userId=1,2", or just "posts" without any filters.
+func describeTypicodeList(resource string, query url.Values) string {
+	if len(query) == 0 {
+		return resource
+	}
+	fields := make([]string, 0, len(query))
+	for field := range query {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	filters := make([]string, len(fields))
+	for i, field := range fields {
+		filters[i] = field + "=" + strings.Join(query[field], ",")
+	}
+	return resource + " for " + strings.Join(filters, "&")
+}
+
+// Name of a model type as it's written in Go, e.g. "[]postSummary", for error messages.
+func typicodeModelName[T any]() string {
+	modelType := reflect.TypeOf((*T)(nil)).Elem()
+	if modelType.Kind() == reflect.Slice {
+		return "[]" + modelType.Elem().Name()
+	}
+	return modelType.Name()
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// getTypicodeResource
+
+func TestGetTypicodeResourceStatusHandler(t *testing.T) {
+	mockHTTPClientDo = func(r *http.Request) (*http.Response, error) {
+		assert.Equal(t, mockBaseURL+"/posts/1?_embed=comments", r.URL.String())
+		return &http.Response{
+			StatusCode: http.StatusGone,
+			Body:       ioutil.NopCloser(strings.NewReader("{}")),
+		}, nil
+	}
+	errGone := errors.New("gone")
+
+	resp, err := getTypicodeResource[post](context.Background(), typicodeClient{Client: &mockHTTPClient{}, BaseUrl: mockBaseURL}, typicodeRequest{
+		Path:           "/posts/1",
+		Query:          url.Values{"_embed": {"comments"}},
+		API:            "Get Post",
+		Description:    "postId=1",
+		StatusHandlers: map[int]func() error{http.StatusGone: func() error { return errGone }},
+	})
+
+	assert.Equal(t, post{}, resp)
+	assert.Equal(t, errGone, err)
+}
+
+func TestGetTypicodeResourceUnhandledStatus(t *testing.T) {
+	mockHTTPClientDo = func(r *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusTooManyRequests,
+			Body:       ioutil.NopCloser(strings.NewReader(errMsg500)),
+		}, nil
+	}
+
+	_, err := getTypicodeResource[post](context.Background(), typicodeClient{Client: &mockHTTPClient{}, BaseUrl: mockBaseURL}, typicodeRequest{
+		Path:           "/posts/1",
+		API:            "Get Post",
+		Description:    "postId=1",
+		StatusHandlers: map[int]func() error{http.StatusGone: func() error { return errFoo }},
+	})
+
+	var unavailableErr *upstreamUnavailableError
+	assert.True(t, errors.As(err, &unavailableErr))
+	assert.EqualError(t, err, "Unexpected server error occurred trying to fetch postId=1 from Cool Vendor: status=429")
+}
+
+func TestGetTypicodeResourceCommunicationError(t *testing.T) {
+	mockHTTPClientDo = func(r *http.Request) (*http.Response, error) {
+		return nil, errFoo
+	}
+
+	_, err := getTypicodeResource[post](context.Background(), typicodeClient{Client: &mockHTTPClient{}, BaseUrl: mockBaseURL}, typicodeRequest{
+		Path:        "/posts/1",
+		API:         "Get Post",
+		Description: "postId=1",
+	})
+
+	assert.True(t, errors.Is(err, errFoo))
+	assert.EqualError(t, err, "Unexpected communication or client policy error occurred trying to fetch postId=1 from Cool Vendor: ooga-booga")
+}
+
+// getTypicodeResourceById
+
+func TestGetTypicodeResourceById(t *testing.T) {
+	mockHTTPClientDo = func(r *http.Request) (*http.Response, error) {
+		assert.Equal(t, mockBaseURL+"/posts/1", r.URL.String())
+		return &http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(strings.NewReader(`{"userId":1,"id":1,"title":"sunt aut facere","body":"quia et suscipit"}`)),
+		}, nil
+	}
+
+	resp, err := getTypicodeResourceById[post](context.Background(), typicodeClient{Client: &mockHTTPClient{}, BaseUrl: mockBaseURL}, "Get Post", "posts", 1)
+
+	assert.Nil(t, err)
+	assert.Equal(t, post{UserID: 1, postSummary: postSummary{ID: 1, Title: "sunt aut facere", Body: "quia et suscipit"}}, resp)
+}
+
+func TestGetTypicodeResourceByIdNotFound(t *testing.T) {
+	mockHTTPClientDo = func(r *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: 404,
+			Body:       ioutil.NopCloser(strings.NewReader("{}")),
+		}, nil
+	}
+
+	_, err := getTypicodeResourceById[post](context.Background(), typicodeClient{Client: &mockHTTPClient{}, BaseUrl: mockBaseURL}, "Get Post", "posts", 123456)
+
+	var notFoundErr *notFoundError
+	assert.True(t, errors.As(err, &notFoundErr))
+	assert.EqualError(t, err, "Could not find postId=123456")
+}
+
+func TestGetTypicodeResourceByIdBadJson(t *testing.T) {
+	mockHTTPClientDo = func(r *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(strings.NewReader(`{"id":"im-a-string"}`)),
+		}, nil
+	}
+
+	_, err := getTypicodeResourceById[post](context.Background(), typicodeClient{Client: &mockHTTPClient{}, BaseUrl: mockBaseURL}, "Get Post", "posts", 1)
+
+	var badResponseErr *upstreamBadResponseError
+	assert.True(t, errors.As(err, &badResponseErr))
+	assert.Contains(t, err.Error(), "Unable to parse response body as 'post' JSON for Cool Vendor's Get Post By ID API: error=")
+}
+
+// getTypicodeList
+
+func TestGetTypicodeList(t *testing.T) {
+	mockHTTPClientDo = func(r *http.Request) (*http.Response, error) {
+		assert.Equal(t, mockBaseURL+"/todos?completed=true&userId=1", r.URL.String())
+		return &http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(strings.NewReader(`[{"userId":1,"id":4,"title":"et porro tempora","completed":true}]`)),
+		}, nil
+	}
+
+	resp, err := getTypicodeList[todo](context.Background(), typicodeClient{Client: &mockHTTPClient{}, BaseUrl: mockBaseURL}, "Get Todos", "todos", url.Values{"userId": {"1"}, "completed": {"true"}})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []todo{{ID: 4, Title: "et porro tempora", Completed: true}}, resp)
+}
+
+func TestGetTypicodeList500(t *testing.T) {
+	mockHTTPClientDo = func(r *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: 500,
+			Body:       ioutil.NopCloser(strings.NewReader(errMsg500)),
+		}, nil
+	}
+
+	resp, err := getTypicodeList[todo](context.Background(), typicodeClient{Client: &mockHTTPClient{}, BaseUrl: mockBaseURL}, "Get Todos", "todos", url.Values{"userId": {"1"}, "completed": {"true"}})
+
+	// Always an empty list rather than nil, same as every other typicodeClient method.
+	assert.Equal(t, []todo{}, resp)
+	assert.EqualError(t, err, "Unexpected server error occurred trying to fetch todos for completed=true&userId=1 from Cool Vendor: status=500")
+}
+
+// describeTypicodeList
+
+func TestDescribeTypicodeList(t *testing.T) {
+	assert.Equal(t, "posts", describeTypicodeList("posts", nil))
+	assert.Equal(t, "posts for userId=1,2", describeTypicodeList("posts", url.Values{"userId": {"1", "2"}}))
+	assert.Equal(t, "todos for completed=true&userId=1", describeTypicodeList("todos", url.Values{"userId": {"1"}, "completed": {"true"}}))
+}
+
+// typicodeModelName
+
+func TestTypicodeModelName(t *testing.T) {
+	assert.Equal(t, "user", typicodeModelName[user]())
+	assert.Equal(t, "[]postSummary", typicodeModelName[[]postSummary]())
+}
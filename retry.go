@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Clients - retryingHTTPClient
+//
+// Retries idempotent requests to Cool Vendor that fail for transient reasons, such as a dropped connection, a 5xx,
+// or a 429 Too Many Requests, using exponential backoff with full jitter so that a fleet of our instances doesn't
+// retry in lockstep.
+//
+// Anything that is clearly permanent is returned right away, such as a 404 or any other 4xx. JSON decode failures
+// are also permanent, but those naturally never get here since they only happen after typicodeClient receives a
+// 200 Ok, and retrying the same request would just return the same malformed body.
+
+// Tuning knobs for retryingHTTPClient.
+type retryPolicy struct {
+	// Total number of attempts, including the first one. Anything less than 2 disables retries.
+	MaxAttempts int
+
+	// Backoff before the first retry. Doubles on every subsequent retry up to MaxDelay.
+	BaseDelay time.Duration
+
+	// Upper bound for any single backoff. This also caps how long we're willing to honor a "Retry-After" header;
+	// if Cool Vendor asks us to wait longer than this, then we return the response as-is rather than tie up the
+	// consumer's request.
+	MaxDelay time.Duration
+}
+
+type retryingHTTPClient struct {
+	Client httpClient
+	Policy retryPolicy
+
+	// Overridable for unit tests so that we don't need to actually wait between attempts.
+	sleep func(ctx context.Context, duration time.Duration) error
+}
+
+func (retryingHTTPClient *retryingHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	if !isIdempotent(req.Method) || retryingHTTPClient.Policy.MaxAttempts < 2 {
+		return retryingHTTPClient.Client.Do(req)
+	}
+
+	for attempt := 1; ; attempt++ {
+		resp, err := retryingHTTPClient.Client.Do(req)
+
+		// Never retry once the caller has given up on the request.
+		if req.Context().Err() != nil {
+			return resp, err
+		}
+
+		reason := describeAttempt(resp, err)
+		if !isTransient(resp, err) {
+			// Only log the first attempt when something actually went wrong, otherwise every request would be logged.
+			if attempt > 1 {
+				log.Printf("Attempt %d/%d for %s %s completed (%s)", attempt, retryingHTTPClient.Policy.MaxAttempts, req.Method, req.URL, reason)
+			}
+			return resp, err
+		}
+
+		if attempt >= retryingHTTPClient.Policy.MaxAttempts {
+			log.Printf("Attempt %d/%d for %s %s failed (%s), giving up", attempt, retryingHTTPClient.Policy.MaxAttempts, req.Method, req.URL, reason)
+			return resp, err
+		}
+
+		delay := retryingHTTPClient.backoff(attempt)
+		if retryAfter, ok := parseRetryAfter(resp); ok {
+			if retryAfter > retryingHTTPClient.Policy.MaxDelay {
+				log.Printf("Attempt %d/%d for %s %s failed (%s), giving up since Retry-After=%s exceeds the max delay", attempt, retryingHTTPClient.Policy.MaxAttempts, req.Method, req.URL, reason, retryAfter)
+				return resp, err
+			}
+			delay = retryAfter
+		}
+		log.Printf("Attempt %d/%d for %s %s failed (%s), retrying in %s", attempt, retryingHTTPClient.Policy.MaxAttempts, req.Method, req.URL, reason, delay)
+
+		// Drain and close the failed response so that its connection can be reused for the next attempt.
+		if resp != nil {
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		if err := retryingHTTPClient.doSleep(req.Context(), delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Exponential backoff with full jitter, i.e. a random duration in [0, min(MaxDelay, BaseDelay * 2^(attempt-1))).
+//
+// @see https://aws.amazon.com/blogs/architecture/exponential-backoff-and-jitter/
+func (retryingHTTPClient *retryingHTTPClient) backoff(attempt int) time.Duration {
+	ceiling := retryingHTTPClient.Policy.BaseDelay
+	for i := 1; i < attempt && ceiling < retryingHTTPClient.Policy.MaxDelay; i++ {
+		ceiling *= 2
+	}
+	if ceiling > retryingHTTPClient.Policy.MaxDelay {
+		ceiling = retryingHTTPClient.Policy.MaxDelay
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(ceiling)))
+}
+
+func (retryingHTTPClient *retryingHTTPClient) doSleep(ctx context.Context, duration time.Duration) error {
+	if retryingHTTPClient.sleep != nil {
+		return retryingHTTPClient.sleep(ctx, duration)
+	}
+	return sleepContext(ctx, duration)
+}
+
+// Sleep for the given duration, or until ctx is done, whichever comes first.
+func sleepContext(ctx context.Context, duration time.Duration) error {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func isIdempotent(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// Whether an attempt is worth retrying. Communication errors, 429s, and 5xx's are generally temporary, while
+// everything else, such as a 404, would just come back the same way again.
+func isTransient(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError
+}
+
+func describeAttempt(resp *http.Response, err error) string {
+	if err != nil {
+		return "error=" + err.Error()
+	}
+	return "status=" + strconv.Itoa(resp.StatusCode)
+}
+
+// Parse the "Retry-After" header on a 429 or 503, which can be either a number of seconds or an HTTP date.
+func parseRetryAfter(resp *http.Response) (time.Duration, bool) {
+	if resp == nil || (resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable) {
+		return 0, false
+	}
+
+	retryAfter := resp.Header.Get("Retry-After")
+	if retryAfter == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(retryAfter); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(retryAfter); err == nil {
+		delay := time.Until(date)
+		if delay < 0 {
+			delay = 0
+		}
+		return delay, true
+	}
+	return 0, false
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// retryingHTTPClient.Do
+
+func TestRetryingHTTPClientRecoversFrom500(t *testing.T) {
+	retryingClient, sleeps := newTestRetryingHTTPClient()
+	typicodeClient := typicodeClient{
+		Client:  retryingClient,
+		BaseUrl: mockBaseURL,
+	}
+	doCount := 0
+	mockHTTPClientDo = func(*http.Request) (*http.Response, error) {
+		doCount++
+		if doCount < 3 {
+			return &http.Response{
+				StatusCode: 500,
+				Body:       ioutil.NopCloser(strings.NewReader(errMsg500)),
+			}, nil
+		}
+		userBytes, userBytesErr := json.Marshal(testUser)
+		assert.Nil(t, userBytesErr)
+		return &http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(bytes.NewReader(userBytes)),
+		}, nil
+	}
+
+	resp, respErr := typicodeClient.getUserById(context.Background(), userId)
+	assert.Nil(t, respErr)
+	assert.Equal(t, testUser, resp)
+	assert.Equal(t, 3, doCount)
+	assert.Len(t, *sleeps, 2)
+	// Full jitter means each backoff is somewhere below its exponential ceiling.
+	assert.Less(t, int64((*sleeps)[0]), int64(testRetryPolicy.BaseDelay))
+	assert.Less(t, int64((*sleeps)[1]), int64(2*testRetryPolicy.BaseDelay))
+}
+
+func TestRetryingHTTPClientRecoversFromExecutionErr(t *testing.T) {
+	retryingClient, _ := newTestRetryingHTTPClient()
+	doCount := 0
+	mockHTTPClientDo = func(*http.Request) (*http.Response, error) {
+		doCount++
+		if doCount == 1 {
+			return nil, errFoo
+		}
+		return &http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(strings.NewReader("[]")),
+		}, nil
+	}
+
+	resp, respErr := retryingClient.Do(newTestGetRequest(t, "/posts?userId=1"))
+	assert.Nil(t, respErr)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, 2, doCount)
+}
+
+func TestRetryingHTTPClientGivesUp(t *testing.T) {
+	retryingClient, sleeps := newTestRetryingHTTPClient()
+	typicodeClient := typicodeClient{
+		Client:  retryingClient,
+		BaseUrl: mockBaseURL,
+	}
+	doCount := 0
+	mockHTTPClientDo = func(*http.Request) (*http.Response, error) {
+		doCount++
+		return &http.Response{
+			StatusCode: 502,
+			Body:       ioutil.NopCloser(strings.NewReader(errMsg500)),
+		}, nil
+	}
+
+	_, respErr := typicodeClient.getPostsByUserId(context.Background(), userId)
+	assert.NotNil(t, respErr)
+	// The last attempt's response should make it all the way back to the caller.
+	assert.Equal(t, fmt.Sprint("Unexpected server error occurred trying to fetch posts for userId=", userId, " from Cool Vendor: status=502"), respErr.Error())
+	assert.Equal(t, testRetryPolicy.MaxAttempts, doCount)
+	assert.Len(t, *sleeps, testRetryPolicy.MaxAttempts-1)
+}
+
+func TestRetryingHTTPClientDoesNotRetry404(t *testing.T) {
+	retryingClient, sleeps := newTestRetryingHTTPClient()
+	typicodeClient := typicodeClient{
+		Client:  retryingClient,
+		BaseUrl: mockBaseURL,
+	}
+	doCount := 0
+	mockHTTPClientDo = func(*http.Request) (*http.Response, error) {
+		doCount++
+		return &http.Response{
+			StatusCode: 404,
+			Body:       ioutil.NopCloser(strings.NewReader("{}")),
+		}, nil
+	}
+
+	resp, respErr := typicodeClient.getUserById(context.Background(), userId)
+	var notFoundErr *notFoundError
+	assert.True(t, errors.As(respErr, &notFoundErr))
+	assert.Equal(t, user{}, resp)
+	assert.Equal(t, 1, doCount)
+	assert.Empty(t, *sleeps)
+}
+
+func TestRetryingHTTPClientDoesNotRetryBadJson(t *testing.T) {
+	retryingClient, _ := newTestRetryingHTTPClient()
+	typicodeClient := typicodeClient{
+		Client:  retryingClient,
+		BaseUrl: mockBaseURL,
+	}
+	doCount := 0
+	mockHTTPClientDo = func(*http.Request) (*http.Response, error) {
+		doCount++
+		return &http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(strings.NewReader(`{"id":"im-a-string"}`)),
+		}, nil
+	}
+
+	_, respErr := typicodeClient.getUserById(context.Background(), userId)
+	assert.NotNil(t, respErr)
+	assert.Equal(t, 1, doCount)
+}
+
+func TestRetryingHTTPClientHonorsRetryAfter(t *testing.T) {
+	retryingClient, sleeps := newTestRetryingHTTPClient()
+	doCount := 0
+	mockHTTPClientDo = func(*http.Request) (*http.Response, error) {
+		doCount++
+		if doCount == 1 {
+			return &http.Response{
+				StatusCode: 429,
+				Header:     http.Header{"Retry-After": []string{"1"}},
+				Body:       ioutil.NopCloser(strings.NewReader("")),
+			}, nil
+		}
+		return &http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(strings.NewReader("{}")),
+		}, nil
+	}
+
+	resp, respErr := retryingClient.Do(newTestGetRequest(t, "/users/1"))
+	assert.Nil(t, respErr)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, []time.Duration{time.Second}, *sleeps)
+}
+
+func TestRetryingHTTPClientRetryAfterTooLong(t *testing.T) {
+	retryingClient, sleeps := newTestRetryingHTTPClient()
+	doCount := 0
+	mockHTTPClientDo = func(*http.Request) (*http.Response, error) {
+		doCount++
+		return &http.Response{
+			StatusCode: 503,
+			Header:     http.Header{"Retry-After": []string{"3600"}},
+			Body:       ioutil.NopCloser(strings.NewReader("")),
+		}, nil
+	}
+
+	resp, respErr := retryingClient.Do(newTestGetRequest(t, "/users/1"))
+	assert.Nil(t, respErr)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, 1, doCount)
+	assert.Empty(t, *sleeps)
+}
+
+func TestRetryingHTTPClientDoesNotRetryNonIdempotent(t *testing.T) {
+	retryingClient, _ := newTestRetryingHTTPClient()
+	doCount := 0
+	mockHTTPClientDo = func(*http.Request) (*http.Response, error) {
+		doCount++
+		return nil, errFoo
+	}
+
+	req, reqErr := http.NewRequest(http.MethodPost, mockBaseURL+"/posts", strings.NewReader("{}"))
+	assert.Nil(t, reqErr)
+	_, respErr := retryingClient.Do(req)
+	assert.Equal(t, errFoo, respErr)
+	assert.Equal(t, 1, doCount)
+}
+
+// parseRetryAfter
+
+func TestParseRetryAfterHTTPDate(t *testing.T) {
+	resp := &http.Response{
+		StatusCode: 503,
+		Header:     http.Header{"Retry-After": []string{time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)}},
+	}
+
+	delay, ok := parseRetryAfter(resp)
+	assert.True(t, ok)
+	assert.InDelta(t, float64(time.Minute), float64(delay), float64(2*time.Second))
+}
+
+func TestParseRetryAfterIgnoredFor500(t *testing.T) {
+	resp := &http.Response{
+		StatusCode: 500,
+		Header:     http.Header{"Retry-After": []string{"5"}},
+	}
+
+	_, ok := parseRetryAfter(resp)
+	assert.False(t, ok)
+}
+
+// Test Helpers
+
+// Build a retryingHTTPClient on top of mockHTTPClient that records every backoff instead of actually sleeping.
+func newTestRetryingHTTPClient() (*retryingHTTPClient, *[]time.Duration) {
+	sleeps := []time.Duration{}
+	return &retryingHTTPClient{
+		Client: &mockHTTPClient{},
+		Policy: testRetryPolicy,
+		sleep: func(ctx context.Context, duration time.Duration) error {
+			sleeps = append(sleeps, duration)
+			return nil
+		},
+	}, &sleeps
+}
+
+// Test Variables
+
+var testRetryPolicy = retryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   100 * time.Millisecond,
+	MaxDelay:    2 * time.Second,
+}
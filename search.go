@@ -0,0 +1,552 @@
+package main
+
+import (
+	"context"
+	"hash/fnv"
+	"html"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// Search
+//
+// Full-text search across every user's posts, backing "/v1/search/posts?q=". Cool Vendor has no search of its own,
+// so we pull every post and user, build an in-process inverted index over them, and rank matches with BM25.
+//
+// Titles and bodies are split into words on anything that isn't a letter or digit, lowercased, and stemmed so that
+// e.g. "running" and "runs" both match "run". Title matches count extra since a post's title says a lot more about
+// what it's about than a passing mention in its body.
+//
+// The index is rebuilt from scratch on a schedule, and also as soon as a request notices that Cool Vendor returned
+// posts that don't match what's indexed. Cool Vendor only has a few hundred posts, so rebuilding the whole thing is
+// cheap and far simpler than patching it in place.
+//
+// @see https://en.wikipedia.org/wiki/Okapi_BM25
+
+// Standard BM25 tuning. k1 controls how quickly repeating a term stops adding to the score, and b controls how much
+// longer posts are penalized for having more chances to mention a term.
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// How many times a word in a post's title counts, relative to the same word in its body.
+const searchTitleWeight = 2
+
+// Roughly how many words of a post's body to show around its first match.
+const searchSnippetWords = 30
+
+// Tuning knobs for searchIndexer.
+type searchIndexerOptions struct {
+	// How often the index is rebuilt even if nothing has noticed it's out of date.
+	RefreshInterval time.Duration
+
+	// Minimum time between rebuilds triggered by a request noticing new data, so that a burst of requests can't
+	// turn into a burst of rebuilds.
+	MinRefreshInterval time.Duration
+
+	// Upper bound on how long a single rebuild can spend waiting on Cool Vendor. Zero means no limit.
+	RefreshTimeout time.Duration
+}
+
+// Keeps an up to date searchIndex over every post from Cool Vendor.
+type searchIndexer struct {
+	// Should bypass any caching so that every rebuild gets the latest data.
+	Source  UserPostSource
+	Options searchIndexerOptions
+
+	// Overridable for unit tests.
+	now func() time.Time
+
+	mutex sync.RWMutex
+	index *searchIndex
+
+	// Signaled whenever the index is noticed to be out of date. Buffered so that any number of notices while a
+	// rebuild is already pending collapse into that one rebuild.
+	stale chan struct{}
+}
+
+func newSearchIndexer(source UserPostSource, options searchIndexerOptions) *searchIndexer {
+	return &searchIndexer{
+		Source:  source,
+		Options: options,
+		now:     time.Now,
+		stale:   make(chan struct{}, 1),
+	}
+}
+
+// The latest index, or nil if it hasn't been built yet.
+func (indexer *searchIndexer) Index() *searchIndex {
+	indexer.mutex.RLock()
+	defer indexer.mutex.RUnlock()
+	return indexer.index
+}
+
+// Fetch every post and user from Cool Vendor, and swap in a freshly built index. The previous index keeps serving
+// searches until the new one is ready, and is kept as is if anything goes wrong.
+func (indexer *searchIndexer) Refresh(ctx context.Context) error {
+	if indexer.Options.RefreshTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, indexer.Options.RefreshTimeout)
+		defer cancel()
+	}
+
+	var users []user
+	var usersErr error
+	var posts []post
+	var postsErr error
+
+	waitGroup := sync.WaitGroup{}
+	waitGroup.Add(1)
+	go func() {
+		users, usersErr = indexer.Source.getUsers(ctx)
+		waitGroup.Done()
+	}()
+	waitGroup.Add(1)
+	go func() {
+		posts, postsErr = indexer.Source.getPosts(ctx)
+		waitGroup.Done()
+	}()
+	waitGroup.Wait()
+
+	if usersErr != nil {
+		return usersErr
+	}
+	if postsErr != nil {
+		return postsErr
+	}
+
+	index := newSearchIndex(posts, users, indexer.now())
+	indexer.mutex.Lock()
+	indexer.index = index
+	indexer.mutex.Unlock()
+	return nil
+}
+
+// Build the index right away, then keep it up to date until ctx is done.
+func (indexer *searchIndexer) Run(ctx context.Context) {
+	refresh := func() {
+		if err := indexer.Refresh(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Unable to refresh the search index, still serving the previous one: error=%s", err.Error())
+		}
+	}
+	refresh()
+
+	ticker := time.NewTicker(indexer.Options.RefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			refresh()
+		case <-indexer.stale:
+			if index := indexer.Index(); index == nil || indexer.now().Sub(index.builtAt) >= indexer.Options.MinRefreshInterval {
+				refresh()
+			}
+		}
+	}
+}
+
+// Let the indexer know what Cool Vendor just returned for a user's posts, so that the index gets rebuilt in the
+// background if they don't match what's indexed.
+func (indexer *searchIndexer) notifyPosts(userId int, posts []postSummary) {
+	index := indexer.Index()
+	if index == nil || index.userFingerprints[userId] == fingerprintPosts(posts) {
+		return
+	}
+	select {
+	case indexer.stale <- struct{}{}:
+	default:
+	}
+}
+
+// An immutable inverted index over a snapshot of every post.
+type searchIndex struct {
+	documents []searchDocument
+
+	// Every post that each stemmed term appears in.
+	postings map[string][]searchPosting
+
+	// Average weighted length of every post, for BM25's length normalization.
+	averageLength float64
+
+	// Summary of each user's indexed posts, so that we can cheaply tell when Cool Vendor has something newer.
+	userFingerprints map[int]uint64
+
+	builtAt time.Time
+}
+
+type searchDocument struct {
+	post     post
+	userInfo userInfo
+
+	// Total weighted number of terms in the post, see searchTitleWeight.
+	length float64
+}
+
+type searchPosting struct {
+	document int
+
+	// Weighted number of times the term appears in the post, see searchTitleWeight.
+	frequency float64
+}
+
+func newSearchIndex(posts []post, users []user, builtAt time.Time) *searchIndex {
+	userInfos := map[int]userInfo{}
+	for _, user := range users {
+		userInfos[user.ID] = userInfo{
+			Name:     user.Name,
+			Username: user.Username,
+			Email:    user.Email,
+		}
+	}
+
+	index := &searchIndex{
+		postings: map[string][]searchPosting{},
+		builtAt:  builtAt,
+	}
+	postsByUser := map[int][]postSummary{}
+	totalLength := 0.0
+	for _, post := range posts {
+		frequencies := map[string]float64{}
+		for _, term := range tokenize(post.Title) {
+			frequencies[term] += searchTitleWeight
+		}
+		for _, term := range tokenize(post.Body) {
+			frequencies[term]++
+		}
+
+		document := searchDocument{post: post, userInfo: userInfos[post.UserID]}
+		for term, frequency := range frequencies {
+			index.postings[term] = append(index.postings[term], searchPosting{document: len(index.documents), frequency: frequency})
+			document.length += frequency
+		}
+		index.documents = append(index.documents, document)
+		totalLength += document.length
+		postsByUser[post.UserID] = append(postsByUser[post.UserID], post.postSummary)
+	}
+	if len(index.documents) > 0 {
+		index.averageLength = totalLength / float64(len(index.documents))
+	}
+
+	index.userFingerprints = map[int]uint64{}
+	for userId, userPosts := range postsByUser {
+		index.userFingerprints[userId] = fingerprintPosts(userPosts)
+	}
+	return index
+}
+
+// A single page of ranked search results.
+type searchResults struct {
+	Query      string      `json:"query"`
+	Hits       []searchHit `json:"hits"`
+	Pagination *pagination `json:"pagination"`
+
+	// When the index being searched was built, i.e. how fresh the results are.
+	IndexedAt time.Time `json:"indexedAt"`
+}
+
+// A single post matching a search, along with the user it belongs to.
+type searchHit struct {
+	Post     postSummary `json:"post"`
+	UserID   int         `json:"userId"`
+	UserInfo userInfo    `json:"userInfo"`
+
+	// BM25 relevance. Only meaningful relative to the other hits for the same query.
+	Score float64 `json:"score"`
+
+	Highlights searchHighlights `json:"highlights"`
+}
+
+// HTML-escaped text with every matching word wrapped in "<mark>" tags.
+type searchHighlights struct {
+	Title string `json:"title"`
+
+	// An excerpt of the body around its first match, with "…" marking where it was cut off.
+	Body string `json:"body"`
+}
+
+type searchMatch struct {
+	document int
+	score    float64
+}
+
+// Rank every post against the query, most relevant first, and return the given page. Posts only need to match one
+// of the query's terms, but the more they match, the higher they rank.
+func (index *searchIndex) search(query string, page int, pageSize int) searchResults {
+	terms := map[string]bool{}
+	for _, term := range tokenize(query) {
+		terms[term] = true
+	}
+
+	scores := map[int]float64{}
+	documentCount := float64(len(index.documents))
+	for term := range terms {
+		postings := index.postings[term]
+		if len(postings) == 0 {
+			continue
+		}
+		idf := math.Log(1 + (documentCount-float64(len(postings))+0.5)/(float64(len(postings))+0.5))
+		for _, posting := range postings {
+			lengthNorm := 1 - bm25B + bm25B*index.documents[posting.document].length/index.averageLength
+			scores[posting.document] += idf * posting.frequency * (bm25K1 + 1) / (posting.frequency + bm25K1*lengthNorm)
+		}
+	}
+
+	matches := make([]searchMatch, 0, len(scores))
+	for document, score := range scores {
+		matches = append(matches, searchMatch{document: document, score: score})
+	}
+	// Break ties on the post ID so that the order, and therefore every page, is stable.
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].score != matches[j].score {
+			return matches[i].score > matches[j].score
+		}
+		return index.documents[matches[i].document].post.ID < index.documents[matches[j].document].post.ID
+	})
+
+	start, end, pageInfo := paginate(len(matches), page, pageSize)
+	hits := []searchHit{}
+	for _, match := range matches[start:end] {
+		document := index.documents[match.document]
+		hits = append(hits, searchHit{
+			Post:     document.post.postSummary,
+			UserID:   document.post.UserID,
+			UserInfo: document.userInfo,
+			Score:    match.score,
+			Highlights: searchHighlights{
+				Title: highlightText(document.post.Title, terms, 0),
+				Body:  highlightText(document.post.Body, terms, searchSnippetWords),
+			},
+		})
+	}
+	return searchResults{
+		Query:      query,
+		Hits:       hits,
+		Pagination: pageInfo,
+		IndexedAt:  index.builtAt,
+	}
+}
+
+// Order-independent summary of a user's posts.
+func fingerprintPosts(posts []postSummary) uint64 {
+	if len(posts) == 0 {
+		return 0
+	}
+	sorted := append([]postSummary(nil), posts...)
+	sortPosts(sorted, sortByID)
+
+	hash := fnv.New64a()
+	for _, post := range sorted {
+		hash.Write([]byte(strconv.Itoa(post.ID) + "\x00" + post.Title + "\x00" + post.Body + "\x00"))
+	}
+	return hash.Sum64()
+}
+
+// Highlighting
+
+// Byte offsets of a single word within some text.
+type wordSpan struct {
+	start int
+	end   int
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func wordSpans(text string) []wordSpan {
+	spans := []wordSpan{}
+	start := -1
+	for i, r := range text {
+		if isWordRune(r) {
+			if start < 0 {
+				start = i
+			}
+		} else if start >= 0 {
+			spans = append(spans, wordSpan{start: start, end: i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		spans = append(spans, wordSpan{start: start, end: len(text)})
+	}
+	return spans
+}
+
+// HTML-escape the text and wrap every word matching one of the stemmed terms in "<mark>" tags. If maxWords is
+// positive and the text is longer than that, only an excerpt of about maxWords words around the first match is kept.
+func highlightText(text string, terms map[string]bool, maxWords int) string {
+	spans := wordSpans(text)
+	isMatch := func(span wordSpan) bool {
+		return terms[stem(strings.ToLower(text[span.start:span.end]))]
+	}
+
+	first, end := 0, len(spans)
+	if maxWords > 0 && len(spans) > maxWords {
+		// Show a bit of leading context before the first match, if there is one.
+		for i, span := range spans {
+			if isMatch(span) {
+				first = i - maxWords/3
+				break
+			}
+		}
+		if first < 0 {
+			first = 0
+		}
+		end = first + maxWords
+		if end > len(spans) {
+			end = len(spans)
+			first = end - maxWords
+		}
+	}
+
+	var highlighted strings.Builder
+	cursor := 0
+	if first > 0 {
+		highlighted.WriteString("…")
+		cursor = spans[first].start
+	}
+	stop := len(text)
+	if end < len(spans) {
+		stop = spans[end-1].end
+	}
+	for _, span := range spans[first:end] {
+		if !isMatch(span) {
+			continue
+		}
+		highlighted.WriteString(html.EscapeString(text[cursor:span.start]))
+		highlighted.WriteString("<mark>" + html.EscapeString(text[span.start:span.end]) + "</mark>")
+		cursor = span.end
+	}
+	highlighted.WriteString(html.EscapeString(text[cursor:stop]))
+	if end < len(spans) {
+		highlighted.WriteString("…")
+	}
+	return highlighted.String()
+}
+
+// Text Analysis
+
+// Split text into lowercased, stemmed terms.
+func tokenize(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool { return !isWordRune(r) })
+	for i, word := range words {
+		words[i] = stem(word)
+	}
+	return words
+}
+
+// Strip common English suffixes from a lowercased word, following step 1 of the Porter stemmer. That covers plurals
+// and "-ed"/"-ing", which is where most of the benefit is, without dragging in the full algorithm.
+//
+// @see https://tartarus.org/martin/PorterStemmer/def.txt
+func stem(word string) string {
+	if len(word) <= 2 {
+		return word
+	}
+	for _, r := range word {
+		// Leave numbers and non-English words alone.
+		if r < 'a' || r > 'z' {
+			return word
+		}
+	}
+
+	// Step 1a: plurals.
+	switch {
+	case strings.HasSuffix(word, "sses"), strings.HasSuffix(word, "ies"):
+		word = word[:len(word)-2]
+	case strings.HasSuffix(word, "ss"):
+	case strings.HasSuffix(word, "s"):
+		word = word[:len(word)-1]
+	}
+
+	// Step 1b: past tenses and gerunds.
+	trimmed := false
+	switch {
+	case strings.HasSuffix(word, "eed"):
+		if stemMeasure(word[:len(word)-3]) > 0 {
+			word = word[:len(word)-1]
+		}
+	case strings.HasSuffix(word, "ed") && stemHasVowel(word[:len(word)-2]):
+		word = word[:len(word)-2]
+		trimmed = true
+	case strings.HasSuffix(word, "ing") && stemHasVowel(word[:len(word)-3]):
+		word = word[:len(word)-3]
+		trimmed = true
+	}
+	if trimmed {
+		// Tidy up what's left so that e.g. "hoping" and "hope" still end up the same, as do "hopping" and "hop".
+		last := len(word) - 1
+		switch {
+		case strings.HasSuffix(word, "at"), strings.HasSuffix(word, "bl"), strings.HasSuffix(word, "iz"):
+			word += "e"
+		case last > 0 && word[last] == word[last-1] && isStemConsonant(word, last) && !strings.ContainsAny(word[last:], "lsz"):
+			word = word[:last]
+		case stemMeasure(word) == 1 && stemEndsCVC(word):
+			word += "e"
+		}
+	}
+
+	// Step 1c: "y" to "i", so that e.g. "copy" and "copies" end up the same.
+	if strings.HasSuffix(word, "y") && stemHasVowel(word[:len(word)-1]) {
+		word = word[:len(word)-1] + "i"
+	}
+	return word
+}
+
+// Whether the letter at i is a consonant. "y" counts as a consonant unless it follows one, e.g. "toy" vs "syzygy".
+func isStemConsonant(word string, i int) bool {
+	switch word[i] {
+	case 'a', 'e', 'i', 'o', 'u':
+		return false
+	case 'y':
+		return i == 0 || !isStemConsonant(word, i-1)
+	}
+	return true
+}
+
+func stemHasVowel(word string) bool {
+	for i := range word {
+		if !isStemConsonant(word, i) {
+			return true
+		}
+	}
+	return false
+}
+
+// Number of vowel-consonant sequences in the word, which is Porter's rough measure of how many syllables it has.
+func stemMeasure(word string) int {
+	measure := 0
+	i := 0
+	for i < len(word) && isStemConsonant(word, i) {
+		i++
+	}
+	for i < len(word) {
+		for i < len(word) && !isStemConsonant(word, i) {
+			i++
+		}
+		if i == len(word) {
+			break
+		}
+		for i < len(word) && isStemConsonant(word, i) {
+			i++
+		}
+		measure++
+	}
+	return measure
+}
+
+// Whether the word ends consonant-vowel-consonant, where the last consonant isn't "w", "x", or "y", e.g. "hop".
+func stemEndsCVC(word string) bool {
+	n := len(word)
+	return n >= 3 && isStemConsonant(word, n-3) && !isStemConsonant(word, n-2) && isStemConsonant(word, n-1) &&
+		!strings.ContainsAny(word[n-1:], "wxy")
+}
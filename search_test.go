@@ -0,0 +1,374 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// stem
+
+func TestStem(t *testing.T) {
+	tests := map[string]string{
+		"caresses":  "caress",
+		"ponies":    "poni",
+		"cats":      "cat",
+		"caress":    "caress",
+		"agreed":    "agree",
+		"feed":      "feed",
+		"plastered": "plaster",
+		"running":   "run",
+		"hopping":   "hop",
+		"hoping":    "hope",
+		"falling":   "fall",
+		"conflated": "conflate",
+		"sized":     "size",
+		"sing":      "sing",
+		"happy":     "happi",
+		"tries":     "tri",
+		"copies":    "copi",
+		"copy":      "copi",
+		"try":       "try",
+		"is":        "is",
+		"2022":      "2022",
+		"café":      "café",
+	}
+	for word, expected := range tests {
+		assert.Equal(t, expected, stem(word), word)
+	}
+}
+
+// tokenize
+
+func TestTokenize(t *testing.T) {
+	assert.Equal(t, []string{"run", "the", "test", "2", "time"}, tokenize("Running the TESTS, 2 times!"))
+	assert.Empty(t, tokenize(" -- "))
+}
+
+// searchIndex.search
+
+func TestSearchIndexSearch(t *testing.T) {
+	index := newTestSearchIndex()
+
+	results := index.search("golang", 1, 10)
+	assert.Equal(t, "golang", results.Query)
+	assert.Equal(t, testSearchBuiltAt, results.IndexedAt)
+	// A match in the title outranks a match in the body.
+	assert.Equal(t, []int{3, 1}, searchHitIDs(results.Hits))
+	assert.Equal(t, 2, results.Pagination.Total)
+	assert.Greater(t, results.Hits[0].Score, results.Hits[1].Score)
+
+	assert.Equal(t, 2, results.Hits[0].UserID)
+	assert.Equal(t, userInfo{Name: "Ervin Howell", Username: "Antonette", Email: "Shanna@melissa.tv"}, results.Hits[0].UserInfo)
+}
+
+func TestSearchIndexSearchStemmed(t *testing.T) {
+	index := newTestSearchIndex()
+
+	assert.Equal(t, []int{2}, searchHitIDs(index.search("cook", 1, 10).Hits))
+	assert.Equal(t, []int{2}, searchHitIDs(index.search("COOKS", 1, 10).Hits))
+}
+
+func TestSearchIndexSearchMoreTermsRankHigher(t *testing.T) {
+	index := newTestSearchIndex()
+
+	// Both posts mention tips, but only one of them also mentions generics.
+	assert.Equal(t, []int{1, 2}, searchHitIDs(index.search("tips generics", 1, 10).Hits))
+}
+
+func TestSearchIndexSearchNoMatches(t *testing.T) {
+	index := newTestSearchIndex()
+
+	results := index.search("rust", 1, 10)
+	assert.Empty(t, results.Hits)
+	assert.NotNil(t, results.Hits)
+	assert.Equal(t, 0, results.Pagination.Total)
+
+	assert.Empty(t, index.search("?!", 1, 10).Hits)
+	assert.Empty(t, newSearchIndex(nil, nil, testSearchBuiltAt).search("golang", 1, 10).Hits)
+}
+
+func TestSearchIndexSearchPastTheEnd(t *testing.T) {
+	index := newTestSearchIndex()
+
+	// Even a page far enough past the end that its start would overflow.
+	results := index.search("golang", math.MaxInt/10+2, 10)
+	assert.Empty(t, results.Hits)
+	assert.Equal(t, 2, results.Pagination.Total)
+}
+
+func TestSearchIndexSearchPaginated(t *testing.T) {
+	index := newTestSearchIndex()
+
+	results := index.search("tips golang", 2, 1)
+	assert.Equal(t, 3, results.Pagination.Total)
+	assert.Equal(t, 3, results.Pagination.TotalPages)
+	assert.Len(t, results.Hits, 1)
+
+	// Every page lines up with the full ranking.
+	all := searchHitIDs(index.search("tips golang", 1, 10).Hits)
+	assert.Equal(t, all[1:2], searchHitIDs(results.Hits))
+}
+
+func TestSearchIndexSearchHighlights(t *testing.T) {
+	index := newTestSearchIndex()
+
+	hit := index.search("generic", 1, 10).Hits[0]
+	assert.Equal(t, "Go tips", hit.Highlights.Title)
+	assert.Equal(t, "<mark>Generics</mark> are finally coming to Golang &amp; friends.", hit.Highlights.Body)
+}
+
+// highlightText
+
+func TestHighlightText(t *testing.T) {
+	terms := map[string]bool{"run": true}
+
+	assert.Equal(t, "<mark>Running</mark> &lt;fast&gt;, <mark>runs</mark>!", highlightText("Running <fast>, runs!", terms, 0))
+	assert.Equal(t, "nothing here", highlightText("nothing here", terms, 0))
+}
+
+func TestHighlightTextExcerpt(t *testing.T) {
+	terms := map[string]bool{"run": true}
+	text := "one two three four five six seven eight nine ten running eleven twelve thirteen fourteen fifteen"
+
+	// Starts a little before the first match, and ends after maxWords words.
+	assert.Equal(t, "…nine ten <mark>running</mark> eleven twelve thirteen…", highlightText(text, terms, 6))
+
+	// Never runs off either end of the text.
+	assert.Equal(t, "one two three…", highlightText(text, map[string]bool{}, 3))
+	assert.Equal(t, "…thirteen fourteen <mark>fifteen</mark>", highlightText(text, map[string]bool{"fifteen": true}, 3))
+}
+
+// searchIndexer
+
+func TestSearchIndexerRefresh(t *testing.T) {
+	indexer := newTestSearchIndexer(t, nil)
+	assert.Nil(t, indexer.Index())
+
+	assert.Nil(t, indexer.Refresh(context.Background()))
+
+	index := indexer.Index()
+	assert.NotNil(t, index)
+	assert.Len(t, index.documents, 100)
+	assert.Equal(t, testSearchBuiltAt, index.builtAt)
+	hits := index.search("voluptatem", 1, 100).Hits
+	assert.NotEmpty(t, hits)
+	for _, hit := range hits {
+		assert.NotEmpty(t, hit.UserInfo.Name)
+	}
+}
+
+func TestSearchIndexerRefreshKeepsPreviousIndexOnError(t *testing.T) {
+	indexer := newTestSearchIndexer(t, nil)
+	assert.Nil(t, indexer.Refresh(context.Background()))
+	previous := indexer.Index()
+
+	fakeVendor := newTestFakeVendor(t, fakeVendorFaults{"users": {Status: http.StatusServiceUnavailable}})
+	mockHTTPClientDo = func(r *http.Request) (*http.Response, error) {
+		w := httptest.NewRecorder()
+		fakeVendor.ServeHTTP(w, r)
+		return w.Result(), nil
+	}
+
+	err := indexer.Refresh(context.Background())
+	assert.EqualError(t, err, "Unexpected server error occurred trying to fetch users from Cool Vendor: status=503")
+	assert.Same(t, previous, indexer.Index())
+}
+
+func TestSearchIndexerNotifyPosts(t *testing.T) {
+	indexer := newTestSearchIndexer(t, nil)
+
+	// Nothing to compare against yet.
+	indexer.notifyPosts(1, []postSummary{{ID: 1}})
+	assert.Len(t, indexer.stale, 0)
+
+	assert.Nil(t, indexer.Refresh(context.Background()))
+	posts, err := indexer.Source.getPosts(context.Background())
+	assert.Nil(t, err)
+	userPosts := []postSummary{}
+	for _, post := range posts {
+		if post.UserID == 1 {
+			userPosts = append([]postSummary{post.postSummary}, userPosts...)
+		}
+	}
+
+	// Same posts in a different order are still up to date.
+	indexer.notifyPosts(1, userPosts)
+	assert.Len(t, indexer.stale, 0)
+	indexer.notifyPosts(123456, []postSummary{})
+	assert.Len(t, indexer.stale, 0)
+
+	userPosts[0].Title = "Edited"
+	indexer.notifyPosts(1, userPosts)
+	indexer.notifyPosts(1, userPosts)
+	assert.Len(t, indexer.stale, 1)
+}
+
+func TestSearchIndexerRun(t *testing.T) {
+	var postsFetches int32
+	indexer := newTestSearchIndexer(t, &postsFetches)
+	indexer.now = time.Now
+	indexer.Options = searchIndexerOptions{RefreshInterval: time.Hour}
+	runTestSearchIndexer(t, indexer)
+	waitForSearchIndexer(t, "the index to be built", func() bool { return indexer.Index() != nil })
+	assert.Equal(t, int32(1), atomic.LoadInt32(&postsFetches))
+
+	// New data from Cool Vendor triggers a rebuild well before the next scheduled one.
+	indexer.notifyPosts(1, []postSummary{{ID: 1, Title: "Edited"}})
+	waitForSearchIndexer(t, "the index to be rebuilt", func() bool { return atomic.LoadInt32(&postsFetches) == 2 })
+}
+
+func TestSearchIndexerRunMinRefreshInterval(t *testing.T) {
+	var postsFetches int32
+	indexer := newTestSearchIndexer(t, &postsFetches)
+	indexer.Options = searchIndexerOptions{RefreshInterval: time.Hour, MinRefreshInterval: time.Minute}
+	runTestSearchIndexer(t, indexer)
+	waitForSearchIndexer(t, "the index to be built", func() bool { return indexer.Index() != nil })
+
+	// The index was built too recently, so the notice is used up without another rebuild.
+	indexer.notifyPosts(1, []postSummary{{ID: 1, Title: "Edited"}})
+	waitForSearchIndexer(t, "the notice to be used up", func() bool { return len(indexer.stale) == 0 })
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&postsFetches))
+}
+
+// Controller - searchPosts
+
+func TestSearchPosts(t *testing.T) {
+	initializeTestFakeVendor(t, nil)
+	assert.Nil(t, postSearchIndexer.Refresh(context.Background()))
+
+	w := httptest.NewRecorder()
+	setupRouter().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/search/posts?q=voluptatem+iusto&pageSize=2", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Link"), `</v1/search/posts?page=2&pageSize=2&q=voluptatem+iusto>; rel="next"`)
+	var resultsResp searchResults
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(&resultsResp))
+	assert.Len(t, resultsResp.Hits, 2)
+	// Post 64 mentions both terms over and over in its body, while post 5 mentions both of them in its title.
+	assert.Equal(t, []int{64, 5}, searchHitIDs(resultsResp.Hits))
+	assert.Contains(t, resultsResp.Hits[0].Highlights.Body, "<mark>voluptatem</mark> <mark>voluptatem</mark>")
+	assert.Equal(t, "quia voluptas in <mark>voluptatem</mark> similique praesentium ducimus <mark>iusto</mark> corporis", resultsResp.Hits[1].Highlights.Title)
+	assert.NotEmpty(t, resultsResp.Hits[0].UserInfo.Name)
+	assert.Greater(t, resultsResp.Pagination.Total, 2)
+}
+
+func TestSearchPostsEmptyQuery400(t *testing.T) {
+	initializeTestFakeVendor(t, nil)
+
+	w := httptest.NewRecorder()
+	setupRouter().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/search/posts?q=+", nil))
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assertProblem(t, w, "invalid_input", "Expected a non-empty 'q' to search for")
+}
+
+func TestSearchPostsPageTooLarge400(t *testing.T) {
+	initializeTestFakeVendor(t, nil)
+	assert.Nil(t, postSearchIndexer.Refresh(context.Background()))
+
+	w := httptest.NewRecorder()
+	setupRouter().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/search/posts?q=voluptatem&page=922337203685477581&pageSize=20", nil))
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assertProblem(t, w, "invalid_input", "Expected 'page' to be an integer between 1 and 10000, but got '922337203685477581' instead")
+}
+
+func TestSearchPostsNotReady503(t *testing.T) {
+	initializeTestFakeVendor(t, nil)
+
+	w := httptest.NewRecorder()
+	setupRouter().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/search/posts?q=voluptatem", nil))
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assertProblem(t, w, "upstream_unavailable", "The search index hasn't been built from Cool Vendor's posts yet, please try again shortly")
+}
+
+func TestSearchPostsDisabled404(t *testing.T) {
+	initializeTestFakeVendor(t, nil)
+	postSearchIndexer = nil
+
+	w := httptest.NewRecorder()
+	setupRouter().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/search/posts?q=voluptatem", nil))
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assertProblem(t, w, "not_found", "Search is not enabled")
+}
+
+// Test Variables
+
+var testSearchBuiltAt = time.Date(2022, time.January, 11, 5, 56, 49, 0, time.UTC)
+
+// Test Helpers
+
+func newTestSearchIndex() *searchIndex {
+	posts := []post{
+		{UserID: 1, postSummary: postSummary{ID: 1, Title: "Go tips", Body: "Generics are finally coming to Golang & friends."}},
+		{UserID: 1, postSummary: postSummary{ID: 2, Title: "Cooking tips", Body: "Salt everything while cooking."}},
+		{UserID: 2, postSummary: postSummary{ID: 3, Title: "Why Golang", Body: "It's simple."}},
+		{UserID: 2, postSummary: postSummary{ID: 4, Title: "Unrelated", Body: "Nothing to see here."}},
+	}
+	users := []user{
+		{ID: 1, Name: "Leanne Graham", Username: "Bret", Email: "Sincere@april.biz"},
+		{ID: 2, Name: "Ervin Howell", Username: "Antonette", Email: "Shanna@melissa.tv"},
+	}
+	return newSearchIndex(posts, users, testSearchBuiltAt)
+}
+
+// Build an indexer against the fake vendor, optionally counting every request for posts.
+func newTestSearchIndexer(t *testing.T, postsFetches *int32) *searchIndexer {
+	fakeVendor := newTestFakeVendor(t, nil)
+	mockHTTPClientDo = func(r *http.Request) (*http.Response, error) {
+		if postsFetches != nil && strings.HasPrefix(r.URL.Path, "/posts") {
+			atomic.AddInt32(postsFetches, 1)
+		}
+		w := httptest.NewRecorder()
+		fakeVendor.ServeHTTP(w, r)
+		return w.Result(), nil
+	}
+	indexer := newSearchIndexer(typicodeClient{Client: &mockHTTPClient{}, BaseUrl: mockBaseURL}, searchIndexerOptions{RefreshInterval: time.Hour})
+	indexer.now = func() time.Time { return testSearchBuiltAt }
+	return indexer
+}
+
+// Run the indexer in the background until the end of the test.
+func runTestSearchIndexer(t *testing.T, indexer *searchIndexer) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		indexer.Run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+func waitForSearchIndexer(t *testing.T, description string, condition func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if condition() {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("Timed out waiting for %s", description)
+}
+
+func searchHitIDs(hits []searchHit) []int {
+	ids := []int{}
+	for _, hit := range hits {
+		ids = append(ids, hit.Post.ID)
+	}
+	return ids
+}
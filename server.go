@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Server
+//
+// Wraps the gin router in a plain http.Server so that we control how it listens and how it shuts down, rather
+// than relying on router.Run(), which can't be stopped gracefully.
+
+// Listener and lifecycle settings for the HTTP server.
+type serverConfig struct {
+	// Interface to listen on. Use "0.0.0.0" (or leave it empty) to be reachable from outside of a container.
+	Host string `yaml:"host"`
+	Port int    `yaml:"port"`
+
+	ReadTimeout  time.Duration `yaml:"readTimeout"`
+	WriteTimeout time.Duration `yaml:"writeTimeout"`
+	IdleTimeout  time.Duration `yaml:"idleTimeout"`
+
+	// How long in-flight requests get to finish after a SIGINT/SIGTERM before they're forcibly cut off.
+	ShutdownGracePeriod time.Duration `yaml:"shutdownGracePeriod"`
+
+	// Upper bound on how long any single API request can spend waiting on Cool Vendor, including retries, before
+	// we give up and return a 504 Gateway Timeout. Zero disables the deadline.
+	RequestTimeout time.Duration `yaml:"requestTimeout"`
+}
+
+var defaultServerConfig = serverConfig{
+	Host:         "localhost",
+	Port:         8080,
+	ReadTimeout:  5 * time.Second,
+	WriteTimeout: 15 * time.Second,
+	IdleTimeout:  60 * time.Second,
+
+	// Should comfortably cover RequestTimeout so that an in-flight request always gets to run to completion.
+	ShutdownGracePeriod: 15 * time.Second,
+	RequestTimeout:      10 * time.Second,
+}
+
+func (config serverConfig) address() string {
+	return net.JoinHostPort(config.Host, strconv.Itoa(config.Port))
+}
+
+func newHTTPServer(config serverConfig, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:         config.address(),
+		Handler:      handler,
+		ReadTimeout:  config.ReadTimeout,
+		WriteTimeout: config.WriteTimeout,
+		IdleTimeout:  config.IdleTimeout,
+	}
+}
+
+// Serve requests on the given listener until ctx is done, then stop accepting new connections and give any
+// in-flight requests up to gracePeriod to finish before forcibly closing them.
+func runServer(ctx context.Context, server *http.Server, listener net.Listener, gracePeriod time.Duration) error {
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.Serve(listener)
+	}()
+
+	select {
+	case err := <-serveErr:
+		// The server died on its own before we ever asked it to stop.
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Printf("Shutting down, waiting up to %s for in-flight requests to finish", gracePeriod)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), gracePeriod)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("In-flight requests did not finish within %s, forcibly closing them: error=%s", gracePeriod, err.Error())
+		server.Close()
+		return err
+	}
+
+	if err := <-serveErr; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	log.Printf("Shut down cleanly")
+	return nil
+}
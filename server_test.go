@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// runServer
+
+func TestRunServerDrainsInFlightRequests(t *testing.T) {
+	started := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		w.Write([]byte("finished"))
+	})
+	server, listener := newTestServer(t, handler)
+	ctx, cancel := context.WithCancel(context.Background())
+	serverErr := make(chan error)
+	go func() {
+		serverErr <- runServer(ctx, server, listener, time.Second)
+	}()
+
+	respBody := make(chan string)
+	go func() {
+		resp, err := http.Get("http://" + listener.Addr().String())
+		assert.Nil(t, err)
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		assert.Nil(t, err)
+		respBody <- string(body)
+	}()
+	<-started
+	cancel()
+
+	// The in-flight request should still complete even though shutdown already started.
+	assert.Equal(t, "finished", <-respBody)
+	assert.Nil(t, <-serverErr)
+
+	// No new connections should be accepted once the server is shut down.
+	_, err := http.Get("http://" + listener.Addr().String())
+	assert.NotNil(t, err)
+}
+
+func TestRunServerGracePeriodExceeded(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	})
+	server, listener := newTestServer(t, handler)
+	ctx, cancel := context.WithCancel(context.Background())
+	serverErr := make(chan error)
+	go func() {
+		serverErr <- runServer(ctx, server, listener, 10*time.Millisecond)
+	}()
+
+	go http.Get("http://" + listener.Addr().String())
+	<-started
+	cancel()
+
+	assert.Equal(t, context.DeadlineExceeded, <-serverErr)
+}
+
+// Test Helpers
+
+func newTestServer(t *testing.T, handler http.Handler) (*http.Server, net.Listener) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	return newHTTPServer(defaultServerConfig, handler), listener
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"sync"
+)
+
+// Clients - dedupingHTTPClient
+//
+// Collapses concurrent identical GETs into a single upstream request, so that a burst of traffic for a popular
+// user only costs one request to Cool Vendor no matter how many consumers are waiting on it. Every waiting caller
+// shares the result (or error) of that one request.
+//
+// This is independent of cachingHTTPClient. When both are used, this should sit in front of the cache so that
+// concurrent cache misses also get collapsed into a single upstream request.
+//
+// Since every caller has its own request context, a waiting caller stops waiting as soon as its own context is done.
+// Likewise, if the caller that owns the upstream request gets cancelled, then any waiting callers that still have
+// time left go upstream on their own rather than inherit someone else's cancellation.
+
+type dedupingHTTPClient struct {
+	Client httpClient
+
+	mutex sync.Mutex
+	calls map[string]*inflightCall
+}
+
+// A single in-flight upstream request that any number of callers can wait on.
+type inflightCall struct {
+	// Closed once resp/err are populated.
+	done chan struct{}
+
+	// Number of callers that joined this call after it was already in flight.
+	dups int
+
+	// Only written by the caller that owns the request, before done is closed.
+	resp *cacheEntry
+	err  error
+}
+
+func (dedupingHTTPClient *dedupingHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		return dedupingHTTPClient.Client.Do(req)
+	}
+
+	// Requests that bypass the cache must not get handed a response that may have come from it, so keep them apart.
+	key := req.URL.String()
+	if isNoCache(req.Header) {
+		key = "no-cache " + key
+	}
+
+	dedupingHTTPClient.mutex.Lock()
+	if dedupingHTTPClient.calls == nil {
+		dedupingHTTPClient.calls = map[string]*inflightCall{}
+	}
+	if call, ok := dedupingHTTPClient.calls[key]; ok {
+		call.dups++
+		dedupingHTTPClient.mutex.Unlock()
+		select {
+		case <-call.done:
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+		if isContextErr(call.err) && req.Context().Err() == nil {
+			return dedupingHTTPClient.Do(req)
+		}
+		return call.toResponse(req)
+	}
+	call := &inflightCall{done: make(chan struct{})}
+	dedupingHTTPClient.calls[key] = call
+	dedupingHTTPClient.mutex.Unlock()
+
+	call.resp, call.err = dedupingHTTPClient.do(req)
+
+	// Stop handing out this call before waking anyone up, so that a waiter falling back to its own request
+	// doesn't just pile right back onto this one.
+	dedupingHTTPClient.mutex.Lock()
+	delete(dedupingHTTPClient.calls, key)
+	dedupingHTTPClient.mutex.Unlock()
+	close(call.done)
+
+	return call.toResponse(req)
+}
+
+// Execute the request and fully buffer the response so that it can be handed out to every waiting caller.
+func (dedupingHTTPClient *dedupingHTTPClient) do(req *http.Request) (*cacheEntry, error) {
+	resp, err := dedupingHTTPClient.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return &cacheEntry{
+		statusCode: resp.StatusCode,
+		header:     resp.Header,
+		body:       body,
+	}, nil
+}
+
+func isContextErr(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+func (call *inflightCall) toResponse(req *http.Request) (*http.Response, error) {
+	if call.err != nil {
+		return nil, call.err
+	}
+	return call.resp.toResponse(req), nil
+}
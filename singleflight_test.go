@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// dedupingHTTPClient.Do
+
+func TestDedupingHTTPClientGetUserById(t *testing.T) {
+	deduper := &dedupingHTTPClient{Client: &mockHTTPClient{}}
+	typicodeClient := typicodeClient{
+		Client:  deduper,
+		BaseUrl: mockBaseURL,
+	}
+	doCount, release := blockingMockHTTPClientDo(t, func() *http.Response {
+		userBytes, userBytesErr := json.Marshal(testUser)
+		assert.Nil(t, userBytesErr)
+		return &http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(bytes.NewReader(userBytes)),
+		}
+	})
+
+	results := make([]user, dedupCallers)
+	errs := make([]error, dedupCallers)
+	waitGroup := sync.WaitGroup{}
+	for i := 0; i < dedupCallers; i++ {
+		waitGroup.Add(1)
+		go func(i int) {
+			defer waitGroup.Done()
+			results[i], errs[i] = typicodeClient.getUserById(context.Background(), userId)
+		}(i)
+	}
+	waitForDups(t, deduper, fmt.Sprint(mockBaseURL, "/users/", userId), dedupCallers-1)
+	close(release)
+	waitGroup.Wait()
+
+	assert.Equal(t, 1, *doCount)
+	for i := 0; i < dedupCallers; i++ {
+		assert.Nil(t, errs[i])
+		assert.Equal(t, testUser, results[i])
+	}
+}
+
+func TestDedupingHTTPClientGetPostsByUserIdSharedError(t *testing.T) {
+	deduper := &dedupingHTTPClient{Client: &mockHTTPClient{}}
+	typicodeClient := typicodeClient{
+		Client:  deduper,
+		BaseUrl: mockBaseURL,
+	}
+	doCount, release := blockingMockHTTPClientDo(t, func() *http.Response {
+		return &http.Response{
+			StatusCode: 500,
+			Body:       ioutil.NopCloser(strings.NewReader(errMsg500)),
+		}
+	})
+
+	errs := make([]error, dedupCallers)
+	waitGroup := sync.WaitGroup{}
+	for i := 0; i < dedupCallers; i++ {
+		waitGroup.Add(1)
+		go func(i int) {
+			defer waitGroup.Done()
+			_, errs[i] = typicodeClient.getPostsByUserId(context.Background(), userId)
+		}(i)
+	}
+	waitForDups(t, deduper, fmt.Sprint(mockBaseURL, "/posts?userId=", userId), dedupCallers-1)
+	close(release)
+	waitGroup.Wait()
+
+	assert.Equal(t, 1, *doCount)
+	for i := 0; i < dedupCallers; i++ {
+		assert.NotNil(t, errs[i])
+		assert.Equal(t, fmt.Sprint("Unexpected server error occurred trying to fetch posts for userId=", userId, " from Cool Vendor: status=500"), errs[i].Error())
+	}
+}
+
+func TestDedupingHTTPClientWithCache(t *testing.T) {
+	deduper := &dedupingHTTPClient{Client: newCachingHTTPClient(&mockHTTPClient{}, testCacheOptions)}
+	typicodeClient := typicodeClient{
+		Client:  deduper,
+		BaseUrl: mockBaseURL,
+	}
+	doCount, release := blockingMockHTTPClientDo(t, func() *http.Response {
+		userBytes, userBytesErr := json.Marshal(testUser)
+		assert.Nil(t, userBytesErr)
+		return &http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(bytes.NewReader(userBytes)),
+		}
+	})
+
+	waitGroup := sync.WaitGroup{}
+	for i := 0; i < dedupCallers; i++ {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			resp, respErr := typicodeClient.getUserById(context.Background(), userId)
+			assert.Nil(t, respErr)
+			assert.Equal(t, testUser, resp)
+		}()
+	}
+	waitForDups(t, deduper, fmt.Sprint(mockBaseURL, "/users/", userId), dedupCallers-1)
+	close(release)
+	waitGroup.Wait()
+
+	// Any later caller should be served straight from the cache.
+	resp, respErr := typicodeClient.getUserById(context.Background(), userId)
+	assert.Nil(t, respErr)
+	assert.Equal(t, testUser, resp)
+	assert.Equal(t, 1, *doCount)
+}
+
+func TestDedupingHTTPClientExecutionErr(t *testing.T) {
+	deduper := &dedupingHTTPClient{Client: &mockHTTPClient{}}
+	mockHTTPClientDo = func(*http.Request) (*http.Response, error) {
+		return nil, errFoo
+	}
+
+	resp, respErr := deduper.Do(newTestGetRequest(t, "/users/1"))
+	assert.Nil(t, resp)
+	assert.Equal(t, errFoo, respErr)
+	assert.Empty(t, deduper.calls)
+}
+
+func TestDedupingHTTPClientWaiterCancelled(t *testing.T) {
+	deduper := &dedupingHTTPClient{Client: &mockHTTPClient{}}
+	_, release := blockingMockHTTPClientDo(t, func() *http.Response {
+		return &http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(strings.NewReader("{}")),
+		}
+	})
+	ownerDone := make(chan struct{})
+	go func() {
+		deduper.Do(newTestGetRequest(t, "/users/1"))
+		close(ownerDone)
+	}()
+	waitForDups(t, deduper, mockBaseURL+"/users/1", 0)
+
+	// A waiter giving up shouldn't need to wait on the in-flight request to finish.
+	ctx, cancel := context.WithCancel(context.Background())
+	waiterErr := make(chan error)
+	go func() {
+		_, err := deduper.Do(newTestGetRequest(t, "/users/1").WithContext(ctx))
+		waiterErr <- err
+	}()
+	waitForDups(t, deduper, mockBaseURL+"/users/1", 1)
+	cancel()
+	assert.Equal(t, context.Canceled, <-waiterErr)
+
+	close(release)
+	<-ownerDone
+}
+
+func TestDedupingHTTPClientOwnerCancelled(t *testing.T) {
+	deduper := &dedupingHTTPClient{Client: &mockHTTPClient{}}
+	doCount := 0
+	doCountMutex := sync.Mutex{}
+	mockHTTPClientDo = func(r *http.Request) (*http.Response, error) {
+		doCountMutex.Lock()
+		doCount++
+		doCountMutex.Unlock()
+		if r.Context().Done() != nil {
+			<-r.Context().Done()
+			return nil, r.Context().Err()
+		}
+		return &http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(strings.NewReader("{}")),
+		}, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ownerDone := make(chan struct{})
+	go func() {
+		_, err := deduper.Do(newTestGetRequest(t, "/users/1").WithContext(ctx))
+		assert.Equal(t, context.Canceled, err)
+		close(ownerDone)
+	}()
+	waitForDups(t, deduper, mockBaseURL+"/users/1", 0)
+	waiterResp := make(chan *http.Response)
+	go func() {
+		resp, err := deduper.Do(newTestGetRequest(t, "/users/1"))
+		assert.Nil(t, err)
+		waiterResp <- resp
+	}()
+	waitForDups(t, deduper, mockBaseURL+"/users/1", 1)
+	cancel()
+
+	// The waiter still has time left, so it should go upstream on its own instead of inheriting the cancellation.
+	resp := <-waiterResp
+	<-ownerDone
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, 2, doCount)
+}
+
+// Test Helpers
+
+// Point mockHTTPClientDo at a handler that blocks until "release" is closed, and count how many times it gets called.
+func blockingMockHTTPClientDo(t *testing.T, newResponse func() *http.Response) (*int, chan struct{}) {
+	doCount := 0
+	doCountMutex := sync.Mutex{}
+	release := make(chan struct{})
+	mockHTTPClientDo = func(*http.Request) (*http.Response, error) {
+		doCountMutex.Lock()
+		doCount++
+		doCountMutex.Unlock()
+		<-release
+		return newResponse(), nil
+	}
+	return &doCount, release
+}
+
+// Wait until the given number of callers have piled onto the in-flight request for the given URL.
+func waitForDups(t *testing.T, deduper *dedupingHTTPClient, url string, dups int) {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		deduper.mutex.Lock()
+		call, ok := deduper.calls[url]
+		found := ok && call.dups == dups
+		deduper.mutex.Unlock()
+		if found {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("Timed out waiting for %d duplicate callers on %s", dups, url)
+}
+
+// Test Variables
+
+var dedupCallers = 10
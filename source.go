@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+)
+
+// Sources
+//
+// userPostService doesn't care where users and their posts come from, so it depends on UserPostSource rather than
+// on Cool Vendor directly. Which source backs the service is picked through configuration, see sourceConfig:
+//
+//   - typicode: Cool Vendor's API through typicodeClient, along with every client policy in front of it, such as
+//     retries, circuit breakers, and caching.
+//   - jsonFile: A local JSON file in the same shape as the bundled seed data, loaded once at startup. Handy for
+//     demos, or for data that was exported from somewhere else entirely.
+//   - mirror: A local mirror of Cool Vendor's users and posts that's kept in sync on a schedule, with everything
+//     else still coming from Cool Vendor. See mirror.go.
+
+// Everything userPostService needs to know about users. Every implementation has to behave like Cool Vendor does,
+// i.e. a user that doesn't exist is a notFoundError, while lists are just empty when nothing matches.
+type UserPostSource interface {
+	getUserById(ctx context.Context, userId int) (user, error)
+	getUsers(ctx context.Context) ([]user, error)
+	getUsersByIds(ctx context.Context, userIds []int) (map[int]user, error)
+
+	getPosts(ctx context.Context) ([]post, error)
+	getPostsByUserId(ctx context.Context, userId int) ([]postSummary, error)
+	getPostsByUserIds(ctx context.Context, userIds []int) (map[int][]postSummary, error)
+	getCommentsByPostId(ctx context.Context, postId int) ([]comment, error)
+
+	getAlbumsByUserId(ctx context.Context, userId int) ([]album, error)
+	getPhotosByAlbumId(ctx context.Context, albumId int) ([]photo, error)
+	getTodosByUserId(ctx context.Context, userId int) ([]todo, error)
+}
+
+// Optionally implemented by sources with a cache in front of them, so that a consumer sending
+// "Cache-Control: no-cache" gets fresh data.
+type cacheBypassingSource interface {
+	// Copy of the source that skips its cache for every request.
+	bypassingCache() UserPostSource
+}
+
+var _ UserPostSource = typicodeClient{}
+var _ cacheBypassingSource = typicodeClient{}
+var _ UserPostSource = (*jsonFileSource)(nil)
+
+type sourceBackend string
+
+const (
+	// Cool Vendor's API, see typicodeClient.
+	sourceTypicode sourceBackend = "typicode"
+
+	// A local JSON file, see jsonFileSource.
+	sourceJSONFile sourceBackend = "jsonFile"
+
+	// A local mirror of Cool Vendor, see mirrorSource.
+	sourceMirror sourceBackend = "mirror"
+)
+
+var sourceBackends = []sourceBackend{sourceTypicode, sourceJSONFile, sourceMirror}
+
+func isSourceBackend(backend sourceBackend) bool {
+	for _, sourceBackend := range sourceBackends {
+		if backend == sourceBackend {
+			return true
+		}
+	}
+	return false
+}
+
+// Sources - jsonFileSource
+//
+// Serves everything from a JSON file in the same format as the bundled seed data, i.e.
+// {"users": [...], "posts": [...], "comments": [...], "albums": [...], "photos": [...], "todos": [...]}, with every
+// item in the same shape as Cool Vendor's. Any missing resource is just empty.
+//
+// The file is only read once, so everything is read-only afterwards and safe to share across requests. Every method
+// still hands out its own copies, since the service fills in things like comments in place.
+type jsonFileSource struct {
+	users    []user
+	posts    []post
+	comments []jsonFileComment
+	albums   []jsonFileAlbum
+	photos   []jsonFilePhoto
+	todos    []jsonFileTodo
+}
+
+// Our models drop the ID of whatever they're nested under, so the file's items need to hold onto it.
+type jsonFileComment struct {
+	PostID int `json:"postId"`
+	comment
+}
+
+type jsonFileAlbum struct {
+	UserID int `json:"userId"`
+	album
+}
+
+type jsonFilePhoto struct {
+	AlbumID int `json:"albumId"`
+	photo
+}
+
+type jsonFileTodo struct {
+	UserID int `json:"userId"`
+	todo
+}
+
+func newJSONFileSource(path string) (*jsonFileSource, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read source file '%s': error=%w", path, err)
+	}
+
+	var file struct {
+		Users    []user            `json:"users"`
+		Posts    []post            `json:"posts"`
+		Comments []jsonFileComment `json:"comments"`
+		Albums   []jsonFileAlbum   `json:"albums"`
+		Photos   []jsonFilePhoto   `json:"photos"`
+		Todos    []jsonFileTodo    `json:"todos"`
+	}
+	if err := json.Unmarshal(contents, &file); err != nil {
+		return nil, fmt.Errorf("Unable to parse source file '%s': error=%w", path, err)
+	}
+	return &jsonFileSource{
+		users:    file.Users,
+		posts:    file.Posts,
+		comments: file.Comments,
+		albums:   file.Albums,
+		photos:   file.Photos,
+		todos:    file.Todos,
+	}, nil
+}
+
+func (source *jsonFileSource) getUserById(ctx context.Context, userId int) (user, error) {
+	for _, user := range source.users {
+		if user.ID == userId {
+			return user, nil
+		}
+	}
+	return user{}, &notFoundError{domainErrorDetails{Message: fmt.Sprint("Could not find userId=", userId)}}
+}
+
+func (source *jsonFileSource) getUsers(ctx context.Context) ([]user, error) {
+	return append([]user{}, source.users...), nil
+}
+
+// Users that don't exist are just missing from the result, same as typicodeClient.getUsersByIds.
+func (source *jsonFileSource) getUsersByIds(ctx context.Context, userIds []int) (map[int]user, error) {
+	wanted := map[int]bool{}
+	for _, userId := range userIds {
+		wanted[userId] = true
+	}
+	usersById := map[int]user{}
+	for _, user := range source.users {
+		if wanted[user.ID] {
+			usersById[user.ID] = user
+		}
+	}
+	return usersById, nil
+}
+
+func (source *jsonFileSource) getPosts(ctx context.Context) ([]post, error) {
+	return append([]post{}, source.posts...), nil
+}
+
+func (source *jsonFileSource) getPostsByUserId(ctx context.Context, userId int) ([]postSummary, error) {
+	posts := []postSummary{}
+	for _, post := range source.posts {
+		if post.UserID == userId {
+			posts = append(posts, post.postSummary)
+		}
+	}
+	return posts, nil
+}
+
+// Every given user has an entry, even if they have no posts, same as typicodeClient.getPostsByUserIds.
+func (source *jsonFileSource) getPostsByUserIds(ctx context.Context, userIds []int) (map[int][]postSummary, error) {
+	postsByUserId := map[int][]postSummary{}
+	for _, userId := range userIds {
+		postsByUserId[userId] = []postSummary{}
+	}
+	for _, post := range source.posts {
+		if posts, ok := postsByUserId[post.UserID]; ok {
+			postsByUserId[post.UserID] = append(posts, post.postSummary)
+		}
+	}
+	return postsByUserId, nil
+}
+
+func (source *jsonFileSource) getCommentsByPostId(ctx context.Context, postId int) ([]comment, error) {
+	comments := []comment{}
+	for _, comment := range source.comments {
+		if comment.PostID == postId {
+			comments = append(comments, comment.comment)
+		}
+	}
+	return comments, nil
+}
+
+func (source *jsonFileSource) getAlbumsByUserId(ctx context.Context, userId int) ([]album, error) {
+	albums := []album{}
+	for _, album := range source.albums {
+		if album.UserID == userId {
+			albums = append(albums, album.album)
+		}
+	}
+	return albums, nil
+}
+
+func (source *jsonFileSource) getPhotosByAlbumId(ctx context.Context, albumId int) ([]photo, error) {
+	photos := []photo{}
+	for _, photo := range source.photos {
+		if photo.AlbumID == albumId {
+			photos = append(photos, photo.photo)
+		}
+	}
+	return photos, nil
+}
+
+func (source *jsonFileSource) getTodosByUserId(ctx context.Context, userId int) ([]todo, error) {
+	todos := []todo{}
+	for _, todo := range source.todos {
+		if todo.UserID == userId {
+			todos = append(todos, todo.todo)
+		}
+	}
+	return todos, nil
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// jsonFileSource
+
+func TestNewJSONFileSource(t *testing.T) {
+	source := newTestJSONFileSource(t)
+	ctx := context.Background()
+
+	userResp, err := source.getUserById(ctx, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, "Leanne Graham", userResp.Name)
+
+	users, err := source.getUsers(ctx)
+	assert.Nil(t, err)
+	assert.Len(t, users, 10)
+
+	posts, err := source.getPosts(ctx)
+	assert.Nil(t, err)
+	assert.Len(t, posts, 100)
+
+	postsResp, err := source.getPostsByUserId(ctx, 1)
+	assert.Nil(t, err)
+	assert.Len(t, postsResp, 10)
+
+	comments, err := source.getCommentsByPostId(ctx, 1)
+	assert.Nil(t, err)
+	assert.Len(t, comments, 5)
+
+	albums, err := source.getAlbumsByUserId(ctx, 1)
+	assert.Nil(t, err)
+	assert.Len(t, albums, 10)
+
+	photos, err := source.getPhotosByAlbumId(ctx, 1)
+	assert.Nil(t, err)
+	assert.Len(t, photos, 10)
+
+	todos, err := source.getTodosByUserId(ctx, 1)
+	assert.Nil(t, err)
+	assert.Len(t, todos, 20)
+}
+
+// Anything the service can ask for should look exactly like it would coming from Cool Vendor.
+func TestJSONFileSourceMatchesTypicodeClient(t *testing.T) {
+	jsonFileSource := newTestJSONFileSource(t)
+	typicodeSource := newTestFakeVendorService(t, nil).Source
+	ctx := context.Background()
+
+	for _, source := range []UserPostSource{jsonFileSource, typicodeSource} {
+		_, err := source.getUserById(ctx, 123456)
+		var notFoundErr *notFoundError
+		assert.True(t, errors.As(err, &notFoundErr))
+		assert.EqualError(t, err, "Could not find userId=123456")
+	}
+
+	assertSameResult := func(get func(source UserPostSource) (interface{}, error)) {
+		expected, err := get(typicodeSource)
+		assert.Nil(t, err)
+		actual, err := get(jsonFileSource)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, actual)
+	}
+	assertSameResult(func(source UserPostSource) (interface{}, error) { return source.getUserById(ctx, 1) })
+	assertSameResult(func(source UserPostSource) (interface{}, error) { return source.getUsers(ctx) })
+	assertSameResult(func(source UserPostSource) (interface{}, error) {
+		return source.getUsersByIds(ctx, []int{1, 2, 123456})
+	})
+	assertSameResult(func(source UserPostSource) (interface{}, error) { return source.getPosts(ctx) })
+	assertSameResult(func(source UserPostSource) (interface{}, error) { return source.getPostsByUserId(ctx, 1) })
+	assertSameResult(func(source UserPostSource) (interface{}, error) { return source.getPostsByUserId(ctx, 123456) })
+	assertSameResult(func(source UserPostSource) (interface{}, error) {
+		return source.getPostsByUserIds(ctx, []int{1, 2, 123456})
+	})
+	assertSameResult(func(source UserPostSource) (interface{}, error) { return source.getCommentsByPostId(ctx, 1) })
+	assertSameResult(func(source UserPostSource) (interface{}, error) { return source.getAlbumsByUserId(ctx, 1) })
+	assertSameResult(func(source UserPostSource) (interface{}, error) { return source.getPhotosByAlbumId(ctx, 1) })
+	assertSameResult(func(source UserPostSource) (interface{}, error) { return source.getTodosByUserId(ctx, 1) })
+}
+
+func TestJSONFileSourceReturnsCopies(t *testing.T) {
+	source := newTestJSONFileSource(t)
+	ctx := context.Background()
+
+	posts, _ := source.getPostsByUserId(ctx, 1)
+	posts[0].Title = "Overwritten"
+	users, _ := source.getUsers(ctx)
+	users[0].Name = "Overwritten"
+
+	posts, _ = source.getPostsByUserId(ctx, 1)
+	assert.NotEqual(t, "Overwritten", posts[0].Title)
+	userResp, _ := source.getUserById(ctx, users[0].ID)
+	assert.NotEqual(t, "Overwritten", userResp.Name)
+}
+
+func TestJSONFileSourceMissingResources(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "source.json")
+	assert.Nil(t, ioutil.WriteFile(path, []byte(`{"users": [{"id": 1, "name": "Leanne Graham"}]}`), 0644))
+
+	source, err := newJSONFileSource(path)
+	assert.Nil(t, err)
+
+	posts, err := source.getPostsByUserId(context.Background(), 1)
+	assert.Nil(t, err)
+	assert.Equal(t, []postSummary{}, posts)
+}
+
+func TestNewJSONFileSourceMissingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing.json")
+
+	_, err := newJSONFileSource(path)
+
+	assert.Contains(t, err.Error(), "Unable to read source file '"+path+"'")
+}
+
+func TestNewJSONFileSourceInvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "source.json")
+	assert.Nil(t, ioutil.WriteFile(path, []byte(`{"users": "nope"}`), 0644))
+
+	_, err := newJSONFileSource(path)
+
+	assert.Contains(t, err.Error(), "Unable to parse source file '"+path+"'")
+}
+
+// userPostService
+
+func TestUserPostServiceWithJSONFileSource(t *testing.T) {
+	userPostService := userPostService{Source: newTestJSONFileSource(t)}
+
+	userPostsResp, err := userPostService.getUserPostsByUserId(context.Background(), 1, userPostsOptions{IncludeComments: true})
+
+	assert.Nil(t, err)
+	assert.Equal(t, userInfo{Name: "Leanne Graham", Username: "Bret", Email: "Sincere@april.biz"}, userPostsResp.UserInfo)
+	assert.Len(t, userPostsResp.Posts, 10)
+	for _, post := range userPostsResp.Posts {
+		assert.Len(t, *post.Comments, 5)
+	}
+}
+
+// initialize
+
+func TestInitializeJSONFileSource(t *testing.T) {
+	config := defaultAppConfig
+	config.Source = sourceConfig{Backend: sourceJSONFile, File: testJSONFileSourcePath}
+	assert.Nil(t, initialize(config))
+	t.Cleanup(func() {
+		postSearchIndexer = nil
+		userPostServiceImpl = userPostService{}
+	})
+
+	// Nothing is cached or guarded by a breaker, since nothing goes over the network.
+	assert.Nil(t, typicodeCache)
+	assert.Nil(t, typicodeBreaker)
+
+	w := httptest.NewRecorder()
+	setupRouter().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/user-posts/1", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var userPostsResp userPosts
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(&userPostsResp))
+	assert.Len(t, userPostsResp.Posts, 10)
+
+	w = httptest.NewRecorder()
+	setupRouter().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/user-posts/123456", nil))
+
+	assertProblem(t, w, "not_found", "Could not find userId=123456")
+}
+
+func TestInitializeJSONFileSourceMissingFile(t *testing.T) {
+	config := defaultAppConfig
+	config.Source = sourceConfig{Backend: sourceJSONFile, File: filepath.Join(t.TempDir(), "missing.json")}
+
+	err := initialize(config)
+
+	assert.Contains(t, err.Error(), "Unable to read source file")
+}
+
+// Test Helpers
+
+// Same seed data that the fake vendor serves, so that both sources can be compared against each other.
+const testJSONFileSourcePath = "seed/typicode.json"
+
+func newTestJSONFileSource(t *testing.T) *jsonFileSource {
+	source, err := newJSONFileSource(testJSONFileSourcePath)
+	assert.Nil(t, err)
+	return source
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Todos
+//
+// A user's todos, along with a summary of how many of them they've completed. The summary always covers every one of
+// the user's todos, so that filtering the list down with "?completed=" doesn't change the numbers next to it.
+
+// Optional behaviors for getTodosByUserId. The zero value returns every todo.
+type userTodosOptions struct {
+	// Only return the todos that are (or aren't) completed. Nil returns every todo.
+	Completed *bool
+}
+
+// Represents the todos for a single user.
+type userTodos struct {
+	UserID  int         `json:"userId"`
+	Todos   []todo      `json:"todos"`
+	Summary todoSummary `json:"summary"`
+}
+
+// Counts across every one of a user's todos, regardless of any filter.
+type todoSummary struct {
+	Total     int `json:"total"`
+	Completed int `json:"completed"`
+	Pending   int `json:"pending"`
+
+	// Share of todos that are completed, from 0 to 100, rounded to two decimal places. 0 when there are no todos.
+	CompletionPercentage float64 `json:"completionPercentage"`
+}
+
+// Fetch every todo for a given user, along with a summary of how many of them are completed.
+//
+// Returns a notFoundError if the user doesn't exist, since Cool Vendor just returns an empty list of todos for them.
+func (userPostService userPostService) getTodosByUserId(ctx context.Context, userId int, options userTodosOptions) (userTodos, error) {
+	var userErr error
+	var todos []todo
+	var todosErr error
+
+	// Same as a user's posts, the user and their todos don't depend on each other.
+	waitGroup := sync.WaitGroup{}
+	waitGroup.Add(1)
+	go func() {
+		_, userErr = userPostService.getUserById(ctx, userId)
+		waitGroup.Done()
+	}()
+	waitGroup.Add(1)
+	go func() {
+		todos, todosErr = userPostService.Source.getTodosByUserId(ctx, userId)
+		waitGroup.Done()
+	}()
+	waitGroup.Wait()
+
+	if userErr != nil {
+		return userTodos{}, userErr
+	}
+	if todosErr != nil {
+		return userTodos{}, todosErr
+	}
+	return userTodos{
+		UserID:  userId,
+		Todos:   filterTodos(todos, options.Completed),
+		Summary: summarizeTodos(todos),
+	}, nil
+}
+
+// Keep only the todos whose completion matches, or every todo when completed is nil.
+func filterTodos(todos []todo, completed *bool) []todo {
+	if completed == nil {
+		return todos
+	}
+	filtered := []todo{}
+	for _, todo := range todos {
+		if todo.Completed == *completed {
+			filtered = append(filtered, todo)
+		}
+	}
+	return filtered
+}
+
+func summarizeTodos(todos []todo) todoSummary {
+	summary := todoSummary{Total: len(todos)}
+	for _, todo := range todos {
+		if todo.Completed {
+			summary.Completed++
+		}
+	}
+	summary.Pending = summary.Total - summary.Completed
+	if summary.Total > 0 {
+		summary.CompletionPercentage = math.Round(float64(summary.Completed)/float64(summary.Total)*100*100) / 100
+	}
+	return summary
+}
+
+// Parse the optional "completed" query parameter, e.g. "?completed=true". Returns nil when it isn't given.
+func parseCompletedFilter(c *gin.Context) (*bool, error) {
+	value, ok := c.GetQuery("completed")
+	if !ok {
+		return nil, nil
+	}
+	completed, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, newInvalidInputError("Expected 'completed' to be true or false, but got '" + value + "' instead")
+	}
+	return &completed, nil
+}
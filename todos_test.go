@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// userPostService.getTodosByUserId
+
+func TestUserPostServiceGetTodosByUserId(t *testing.T) {
+	userPostService := newTestFakeVendorService(t, nil)
+
+	userTodosResp, err := userPostService.getTodosByUserId(context.Background(), 1, userTodosOptions{})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, userTodosResp.UserID)
+	assert.Len(t, userTodosResp.Todos, 20)
+	assert.Equal(t, todo{ID: 1, Title: "ea sit esse quidem quis ex adipisci autem", Completed: false}, userTodosResp.Todos[0])
+	assert.Equal(t, todoSummary{Total: 20, Completed: 6, Pending: 14, CompletionPercentage: 30}, userTodosResp.Summary)
+}
+
+func TestUserPostServiceGetTodosByUserIdCompleted(t *testing.T) {
+	userPostService := newTestFakeVendorService(t, nil)
+
+	for _, completed := range []bool{true, false} {
+		userTodosResp, err := userPostService.getTodosByUserId(context.Background(), 1, userTodosOptions{Completed: &completed})
+
+		assert.Nil(t, err)
+		for _, todo := range userTodosResp.Todos {
+			assert.Equal(t, completed, todo.Completed)
+		}
+
+		// The summary still covers every todo.
+		assert.Equal(t, todoSummary{Total: 20, Completed: 6, Pending: 14, CompletionPercentage: 30}, userTodosResp.Summary)
+	}
+}
+
+func TestUserPostServiceGetTodosByUserIdNotFound(t *testing.T) {
+	userPostService := newTestFakeVendorService(t, nil)
+
+	_, err := userPostService.getTodosByUserId(context.Background(), 123456, userTodosOptions{})
+
+	var notFoundErr *notFoundError
+	assert.True(t, errors.As(err, &notFoundErr))
+	assert.EqualError(t, err, "Could not find userId=123456")
+}
+
+func TestUserPostServiceGetTodosByUserIdUpstreamFailure(t *testing.T) {
+	userPostService := newTestFakeVendorService(t, fakeVendorFaults{"todos": {Status: http.StatusInternalServerError}})
+
+	_, err := userPostService.getTodosByUserId(context.Background(), 1, userTodosOptions{})
+
+	assert.EqualError(t, err, "Unexpected server error occurred trying to fetch todos for userId=1 from Cool Vendor: status=500")
+}
+
+// summarizeTodos
+
+func TestSummarizeTodos(t *testing.T) {
+	assert.Equal(t, todoSummary{}, summarizeTodos([]todo{}))
+	assert.Equal(t, todoSummary{Total: 3, Completed: 1, Pending: 2, CompletionPercentage: 33.33}, summarizeTodos([]todo{
+		{ID: 1, Completed: true},
+		{ID: 2},
+		{ID: 3},
+	}))
+	assert.Equal(t, todoSummary{Total: 3, Completed: 2, Pending: 1, CompletionPercentage: 66.67}, summarizeTodos([]todo{
+		{ID: 1, Completed: true},
+		{ID: 2, Completed: true},
+		{ID: 3},
+	}))
+}
+
+// typicodeClient.getTodosByUserId
+
+func TestTypicodeClientGetTodosByUserIdSuccess(t *testing.T) {
+	typicodeClient := typicodeClient{
+		Client:  &mockHTTPClient{},
+		BaseUrl: mockBaseURL,
+	}
+	mockHTTPClientDo = func(r *http.Request) (*http.Response, error) {
+		assert.Equal(t, mockBaseURL+"/todos?userId=1", r.URL.String())
+		return &http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(strings.NewReader(`[{"userId":1,"id":1,"title":"delectus aut autem","completed":true}]`)),
+		}, nil
+	}
+
+	resp, respErr := typicodeClient.getTodosByUserId(context.Background(), 1)
+	assert.Nil(t, respErr)
+	assert.Equal(t, []todo{{ID: 1, Title: "delectus aut autem", Completed: true}}, resp)
+}
+
+func TestTypicodeClientGetTodosByUserIdBadJson(t *testing.T) {
+	typicodeClient := typicodeClient{
+		Client:  &mockHTTPClient{},
+		BaseUrl: mockBaseURL,
+	}
+	mockHTTPClientDo = func(*http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(strings.NewReader(`{"id":"im-a-string"}`)),
+		}, nil
+	}
+
+	resp, respErr := typicodeClient.getTodosByUserId(context.Background(), 1)
+	assert.Equal(t, []todo{}, resp)
+	assert.NotNil(t, respErr)
+	assert.Contains(t, respErr.Error(), "Unable to parse response body as '[]todo' JSON for Cool Vendor's Get Todos API: error=")
+}
+
+func TestTypicodeClientGetTodosByUserId500(t *testing.T) {
+	typicodeClient := typicodeClient{
+		Client:  &mockHTTPClient{},
+		BaseUrl: mockBaseURL,
+	}
+	mockHTTPClientDo = func(*http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: 500,
+			Body:       ioutil.NopCloser(strings.NewReader(errMsg500)),
+		}, nil
+	}
+
+	resp, respErr := typicodeClient.getTodosByUserId(context.Background(), 1)
+	assert.Equal(t, []todo{}, resp)
+	assert.EqualError(t, respErr, "Unexpected server error occurred trying to fetch todos for userId=1 from Cool Vendor: status=500")
+}
+
+// Controller - getTodosByUserId
+
+func TestGetTodosByUserId(t *testing.T) {
+	initializeTestFakeVendor(t, nil)
+
+	w := httptest.NewRecorder()
+	setupRouter().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/users/1/todos?completed=true", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var userTodosResp userTodos
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(&userTodosResp))
+	assert.Equal(t, 1, userTodosResp.UserID)
+	assert.Len(t, userTodosResp.Todos, 6)
+	assert.Equal(t, todoSummary{Total: 20, Completed: 6, Pending: 14, CompletionPercentage: 30}, userTodosResp.Summary)
+}
+
+func TestGetTodosByUserIdNotFound404(t *testing.T) {
+	initializeTestFakeVendor(t, nil)
+
+	w := httptest.NewRecorder()
+	setupRouter().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/users/123456/todos", nil))
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assertProblem(t, w, "not_found", "Could not find userId=123456")
+}
+
+func TestGetTodosByUserIdInvalidId400(t *testing.T) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/v1/users/abc/todos", nil)
+	c.Params = gin.Params{{Key: "userId", Value: "abc"}}
+
+	getTodosByUserId(c)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assertProblem(t, w, "invalid_input", "Expected ID in integer format, but got 'abc' instead")
+}
+
+func TestGetTodosByUserIdInvalidCompleted400(t *testing.T) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/v1/users/1/todos?completed=maybe", nil)
+	c.Params = gin.Params{{Key: "userId", Value: "1"}}
+
+	getTodosByUserId(c)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assertProblem(t, w, "invalid_input", "Expected 'completed' to be true or false, but got 'maybe' instead")
+}